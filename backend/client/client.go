@@ -0,0 +1,114 @@
+// Package client is a typed Go client for the TODO app REST API.
+//
+// The request, response and method definitions in client.gen.go are
+// generated from the OpenAPI document; regenerate them after changing it:
+//
+//	go generate ./client
+package client
+
+//go:generate go run ../cmd/openapi-client -o client.gen.go -package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client calls the API at BaseURL, e.g. "http://localhost:8081".
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Token is sent as a bearer token when set. Operations that log in
+	// return a token but do not store it here.
+	Token string
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Error is returned for every non-2xx response. Problem holds the decoded
+// application/problem+json body; its Code is stable and safe to switch on.
+type Error struct {
+	StatusCode int
+	Problem    Problem
+}
+
+func (e *Error) Error() string {
+	if e.Problem.Code != "" {
+		return fmt.Sprintf("todo api: %d %s: %s", e.StatusCode, e.Problem.Code, e.Problem.Detail)
+	}
+	return fmt.Sprintf("todo api: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// optionalBody keeps a nil pointer from being sent as the JSON literal null.
+func optionalBody[T any](body *T) any {
+	if body == nil {
+		return nil
+	}
+	return body
+}
+
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body, out any) error {
+	data, err := c.doRaw(ctx, method, path, header, body)
+	if err != nil || out == nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("todo api: decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) doRaw(ctx context.Context, method, path string, header http.Header, body any) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("todo api: encode %s %s request: %w", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		// A body that is not a problem document still yields an Error with
+		// the status code.
+		_ = json.Unmarshal(data, &apiErr.Problem)
+		return nil, apiErr
+	}
+	return data, nil
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/openapi"
+	"todo-app/backend/internal/openapi/codegen"
+)
+
+func TestGeneratedCodeIsUpToDate(t *testing.T) {
+	want, err := codegen.Generate(openapi.Document, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("client.gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("client.gen.go is stale; run go generate ./client")
+	}
+}
+
+func TestClientSendsRequestsAndDecodesResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		if r.Method != http.MethodPut || r.URL.Path != "/api/todos/7" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		var req TodoRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Title != "Buy milk" {
+			t.Errorf("body = %+v, %v", req, err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": 7, "user_id": 1, "title": req.Title, "description": "", "completed": true,
+			"created_at": "2024-01-02T03:04:05Z", "updated_at": "2024-01-02T03:04:05Z",
+		})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.Token = "secret"
+	todo, err := c.UpdateTodo(context.Background(), 7, &TodoRequest{Title: "Buy milk", Completed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.ID != 7 || !todo.Completed || todo.CreatedAt.Year() != 2024 {
+		t.Errorf("todo = %+v", todo)
+	}
+}
+
+func TestClientEncodesQueryParameters(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	for _, params := range []*ListAuditLogParams{nil, {}, {UserID: 3, Limit: 10}} {
+		if _, err := c.ListAuditLog(context.Background(), params); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"", "", "limit=10&user_id=3"}
+	if strings.Join(queries, "|") != strings.Join(want, "|") {
+		t.Errorf("queries = %q, want %q", queries, want)
+	}
+}
+
+func TestClientSendsHeaderParameters(t *testing.T) {
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("X-Confirmation-Token"))
+		w.Write([]byte(`{"id":2,"email":"b@example.com"}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	for _, token := range []string{"", "tok"} {
+		if _, err := c.UpdateUserRole(context.Background(), 2, token, &UpdateRoleRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if strings.Join(tokens, "|") != "|tok" {
+		t.Errorf("tokens = %q, want [\"\" \"tok\"]", tokens)
+	}
+}
+
+func TestClientReturnsProblemErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"about:blank","title":"Not Found","status":404,"code":"todo_not_found","detail":"Todo not found"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).GetTodo(context.Background(), 1)
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Problem.Code != "todo_not_found" {
+		t.Errorf("err = %+v", apiErr)
+	}
+}
+
+func TestLoginResultHoldsEitherVariant(t *testing.T) {
+	var session, challenge LoginResult
+	json.Unmarshal([]byte(`{"token":"t","user":{"id":1,"email":"a@example.com"}}`), &session)
+	json.Unmarshal([]byte(`{"two_factor_required":true,"challenge_token":"c"}`), &challenge)
+
+	if session.Token != "t" || session.User.ID != 1 || session.TwoFactorRequired {
+		t.Errorf("session = %+v", session)
+	}
+	if challenge.Token != "" || !challenge.TwoFactorRequired || challenge.ChallengeToken != "c" {
+		t.Errorf("challenge = %+v", challenge)
+	}
+}
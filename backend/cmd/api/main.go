@@ -1,97 +1,340 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"todo-app/backend/internal/blob"
+	"todo-app/backend/internal/config"
+	"todo-app/backend/internal/cors"
 	"todo-app/backend/internal/database"
 	"todo-app/backend/internal/handlers"
+	"todo-app/backend/internal/health"
+	"todo-app/backend/internal/lifecycle"
+	"todo-app/backend/internal/logging"
+	"todo-app/backend/internal/metrics"
 	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/notify"
+	"todo-app/backend/internal/oidc"
+	"todo-app/backend/internal/ratelimit"
+	"todo-app/backend/internal/tracing"
+
+	// User time zones are validated with time.LoadLocation; the runtime
+	// image has no zoneinfo of its own.
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
+	flags := flag.NewFlagSet("api", flag.ExitOnError)
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flags.Parse(os.Args[1:])
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal("Invalid configuration", err)
+	}
+	logger, err := logging.New(os.Stdout, logging.Config{
+		Level:  cfg.Log.Level,
+		Format: cfg.Log.Format,
+	})
+	if err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+	// Everything logged through slog or the standard log package, including
+	// third-party libraries, goes through the structured handler.
+	slog.SetDefault(logger)
+
+	if cfg.IsDevelopment() {
+		slog.Warn("Running in development mode; insecure default secrets are allowed")
+	}
+
+	middleware.SetJWTSecret(cfg.Auth.JWTSecret)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+
 	dbConfig := database.Config{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnv("DB_PORT", "5432"),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: getEnv("DB_PASSWORD", "postgres"),
-		DBName:   getEnv("DB_NAME", "todoapp"),
-		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.Name,
+		SSLMode:  cfg.Database.SSLMode,
 	}
 
 	db, err := database.Connect(dbConfig)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
-	defer db.Close()
 
 	if err := database.RunMigrations(db); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		fatal("Failed to run migrations", err)
 	}
 
-	if err := middleware.CreateDefaultAdmin(db); err != nil {
-		log.Printf("Warning: Failed to create default admin: %v", err)
+	if cfg.Auth.SeedDefaultAdmin {
+		if err := middleware.CreateDefaultAdmin(db, cfg.Auth.DefaultAdminEmail, cfg.Auth.DefaultAdminPassword); err != nil {
+			slog.Warn("Failed to create default admin", "error", err)
+		}
 	}
 
-	authHandler := handlers.NewAuthHandler(db)
-	todoHandler := handlers.NewTodoHandler(db)
-	adminHandler := handlers.NewAdminHandler(db)
+	passwordPolicy := &middleware.PasswordPolicy{
+		MinLength: cfg.Password.MinLength,
+		MinScore:  cfg.Password.MinScore,
+	}
+	if cfg.Password.BreachedList != "" {
+		breached, err := middleware.LoadBreachedPasswords(cfg.Password.BreachedList)
+		if err != nil {
+			fatal("Failed to load breached password list", err)
+		}
+		passwordPolicy.Breached = breached
+	}
 
-	r := gin.Default()
+	var rateLimitStore ratelimit.Store
+	switch cfg.RateLimit.Store {
+	case "postgres":
+		rateLimitStore = ratelimit.NewPostgresStore(db)
+	default:
+		rateLimitStore = ratelimit.NewMemoryStore()
+	}
+	limiter := &ratelimit.Limiter{
+		Store: rateLimitStore,
+		IP: ratelimit.Rate{
+			PerMinute: cfg.RateLimit.IPPerMinute,
+			Burst:     cfg.RateLimit.IPBurst,
+		},
+		Account: ratelimit.Rate{
+			PerMinute: cfg.RateLimit.AccountPerMinute,
+			Burst:     cfg.RateLimit.AccountBurst,
+		},
+		Lockout: ratelimit.LockoutPolicy{
+			Threshold: cfg.RateLimit.LockoutThreshold,
+			Base:      cfg.RateLimit.LockoutBaseDuration.Std(),
+			Max:       cfg.RateLimit.LockoutMaxDuration.Std(),
+		},
+	}
 
-	// CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+	authHandler := handlers.NewAuthHandler(db, passwordPolicy, limiter)
+	twoFactorHandler := handlers.NewTwoFactorHandler(db, cfg.TOTP.Issuer, limiter)
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+	})
+	if err != nil {
+		fatal("Failed to configure WebAuthn", err)
+	}
+	passkeyHandler := handlers.NewPasskeyHandler(db, wa)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	var oidcHandler *handlers.OIDCHandler
+	if cfg.OIDC.IssuerURL != "" {
+		provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+			GroupsClaim:  cfg.OIDC.GroupsClaim,
+			AdminGroups:  cfg.OIDC.AdminGroups,
+		}, nil)
+		if err != nil {
+			fatal("Failed to configure OpenID Connect", err)
 		}
+		oidcHandler = handlers.NewOIDCHandler(db, provider, cfg.OIDC.PostLoginRedirect)
+	}
+	todoHandler := handlers.NewTodoHandler(db)
+	boardHandler := handlers.NewBoardHandler(db)
+	commentHandler := handlers.NewCommentHandler(db)
+	blobStore, err := newBlobStore(cfg.Attachments)
+	if err != nil {
+		fatal("Failed to configure attachment storage", err)
+	}
+	attachmentHandler := handlers.NewAttachmentHandler(db, blobStore,
+		int64(cfg.Attachments.MaxFileBytes), int64(cfg.Attachments.UserQuotaBytes),
+		cfg.Attachments.AllowedTypes, cfg.Attachments.URLTTL.Std(), cfg.Attachments.PublicURL)
+	notificationHandler := handlers.NewNotificationHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, limiter,
+		cfg.Auth.InvitationURL, cfg.Auth.InvitationTTL.Std(), cfg.Auth.ImpersonationTTL.Std(), cfg.Stats.CacheTTL.Std())
 
-		c.Next()
+	if cfg.Metrics.Enabled {
+		metrics.RegisterDB(db, dbConfig.DBName)
+	}
+
+	checker := health.NewChecker(cfg.Health.CheckTimeout.Std())
+	checker.Register("database", health.Ping(db))
+	checker.Register("migrations", func(ctx context.Context) error {
+		return database.CheckSchemaVersion(ctx, db)
 	})
+	if hasuraURL := cfg.Health.HasuraHealthURL(); hasuraURL != "" {
+		checker.Register("hasura", health.HTTP(http.DefaultClient, hasuraURL))
+	}
 
-	api := r.Group("/api")
-	{
-		api.POST("/register", authHandler.Register)
-		api.POST("/login", authHandler.Login)
-
-		protected := api.Group("")
-		protected.Use(middleware.GinAuthMiddleware())
-		{
-			protected.GET("/me", authHandler.GetCurrentUser)
-			protected.GET("/todos", todoHandler.GetTodos)
-			protected.POST("/todos", todoHandler.CreateTodo)
-			protected.GET("/todos/:id", todoHandler.GetTodo)
-			protected.PUT("/todos/:id", todoHandler.UpdateTodo)
-			protected.DELETE("/todos/:id", todoHandler.DeleteTodo)
-		}
+	r := gin.New()
+	r.Use(
+		tracing.GinMiddleware(),
+		logging.RequestID(logger),
+		logging.AccessLog(),
+		logging.Recovery(),
+		// Uploads get room for the multipart framing around the file.
+		middleware.BodyLimit(int64(cfg.Server.MaxBodyBytes), map[string]int64{
+			"/api/todos/:id/attachments": int64(cfg.Attachments.MaxFileBytes) + 64<<10,
+		}),
+	)
+	// Without trusted proxies ClientIP ignores X-Forwarded-For, which clients
+	// could otherwise spoof to dodge per-IP rate limits.
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
 
-		admin := api.Group("/admin")
-		admin.Use(middleware.GinAuthMiddleware())
-		admin.Use(middleware.GinAdminMiddleware())
-		{
-			admin.GET("/users", adminHandler.GetAllUsers)
-			admin.GET("/users/:id", adminHandler.GetUser)
-			admin.DELETE("/users/:id", adminHandler.DeleteUser)
-			admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
-			admin.GET("/users/:id/todos", adminHandler.GetUserTodos)
-		}
+	if cfg.Metrics.Enabled {
+		r.Use(metrics.GinMiddleware())
+	}
+
+	corsPolicy, err := cors.New(cors.Config{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge.Std(),
+	})
+	if err != nil {
+		fatal("Invalid CORS configuration", err)
 	}
+	r.Use(corsPolicy.GinMiddleware())
 
-	port := getEnv("PORT", "8080")
-	log.Printf("Server starting on port %s", port)
-	log.Printf("Default admin credentials - Email: admin@example.com, Password: admin123")
-	r.Run(":" + port)
+	registerRoutes(r, routes{
+		DB:              db,
+		Limiter:         limiter,
+		Checker:         checker,
+		ServeMetrics:    cfg.Metrics.Enabled && cfg.Metrics.Port == "",
+		AdminRequire2FA: cfg.Auth.AdminRequire2FA,
+		Auth:            authHandler,
+		TwoFactor:       twoFactorHandler,
+		Passkey:         passkeyHandler,
+		OIDC:            oidcHandler,
+		Todo:            todoHandler,
+		Board:           boardHandler,
+		Comment:         commentHandler,
+		Attachment:      attachmentHandler,
+		Notification:    notificationHandler,
+		Admin:           adminHandler,
+	})
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Std(),
+		ReadTimeout:       cfg.Server.ReadTimeout.Std(),
+		WriteTimeout:      cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:       cfg.Server.IdleTimeout.Std(),
+	}
+
+	// Components stop in reverse order: the HTTP server drains first, then
+	// background workers, then the database pool, and buffered spans are
+	// flushed last.
+	lc := lifecycle.New()
+	lc.Append(lifecycle.Hook{
+		Name:   "tracing",
+		OnStop: shutdownTracing,
+	})
+	lc.Append(lifecycle.Hook{
+		Name: "database",
+		OnStop: func(context.Context) error {
+			return db.Close()
+		},
+	})
+	lc.Append(lifecycle.Worker("rate limit pruner", 10*time.Minute, func(ctx context.Context) error {
+		return rateLimitStore.Prune(ctx, 24*time.Hour)
+	}))
+	lc.Append(lifecycle.Worker("attachment cleaner", time.Minute, func(ctx context.Context) error {
+		return blob.Purge(ctx, db, blobStore, 100)
+	}))
+	lc.Append(lifecycle.Worker("due soon notifier", cfg.Notifications.DueSoonInterval.Std(), func(ctx context.Context) error {
+		_, err := notify.DueSoon(ctx, db)
+		return err
+	}))
+	lc.Append(lifecycle.Worker("notification pruner", time.Hour, func(ctx context.Context) error {
+		return notify.Prune(ctx, db, cfg.Notifications.Retention.Std())
+	}))
+	lc.Append(lifecycle.Worker("position rebalancer", time.Hour, func(ctx context.Context) error {
+		return handlers.RebalancePositions(ctx, db, 100)
+	}))
+	lc.Append(lifecycle.Server(lc, "HTTP server", srv))
+	if cfg.Metrics.Enabled && cfg.Metrics.Port != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		lc.Append(lifecycle.Server(lc, "metrics server", &http.Server{
+			Addr:              ":" + cfg.Metrics.Port,
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Std(),
+		}))
+	}
+	// Appended last so it stops first: readiness fails before the server
+	// stops accepting connections.
+	lc.Append(lifecycle.Hook{
+		Name: "readiness",
+		OnStart: func(context.Context) error {
+			checker.SetReady(true)
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			checker.SetReady(false)
+			select {
+			case <-time.After(cfg.Server.ShutdownDelay.Std()):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := lc.Run(ctx, cfg.Server.ShutdownTimeout.Std()); err != nil {
+		fatal("Server stopped with error", err)
+	}
+	slog.Info("Server stopped")
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// newBlobStore returns the attachment store cfg selects.
+func newBlobStore(cfg config.AttachmentConfig) (blob.Store, error) {
+	if cfg.Store == "s3" {
+		return blob.NewS3(blob.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		}, nil)
 	}
-	return defaultValue
+	return blob.NewLocal(cfg.LocalDir)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"database/sql"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/handlers"
+	"todo-app/backend/internal/health"
+	"todo-app/backend/internal/metrics"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/openapi"
+	"todo-app/backend/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routes is everything registerRoutes needs. OIDC is nil when single sign-on
+// is not configured.
+type routes struct {
+	DB              *sql.DB
+	Limiter         *ratelimit.Limiter
+	Checker         *health.Checker
+	ServeMetrics    bool
+	AdminRequire2FA bool
+
+	Auth         *handlers.AuthHandler
+	TwoFactor    *handlers.TwoFactorHandler
+	Passkey      *handlers.PasskeyHandler
+	OIDC         *handlers.OIDCHandler
+	Todo         *handlers.TodoHandler
+	Board        *handlers.BoardHandler
+	Comment      *handlers.CommentHandler
+	Attachment   *handlers.AttachmentHandler
+	Notification *handlers.NotificationHandler
+	Admin        *handlers.AdminHandler
+}
+
+// registerRoutes mounts every endpoint. Each route must also be described in
+// internal/openapi/openapi.json; TestRoutesMatchOpenAPISpec enforces this.
+func registerRoutes(r *gin.Engine, h routes) {
+	if h.ServeMetrics {
+		r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+	r.GET("/healthz", h.Checker.Liveness)
+	r.GET("/readyz", h.Checker.Readiness)
+	r.NoRoute(func(c *gin.Context) {
+		apperror.Respond(c, apperror.ErrNotFound)
+	})
+
+	api := r.Group("/api")
+	{
+		api.GET("/openapi.json", openapi.Spec)
+		api.GET("/docs", openapi.Docs)
+
+		rateLimited := middleware.GinRateLimitMiddleware(h.Limiter)
+		api.POST("/register", rateLimited, h.Auth.Register)
+		api.POST("/login", rateLimited, h.Auth.Login)
+		api.POST("/login/2fa", rateLimited, h.TwoFactor.Login)
+		api.POST("/passkeys/login/begin", rateLimited, h.Passkey.BeginLogin)
+		api.POST("/passkeys/login/finish", rateLimited, h.Passkey.FinishLogin)
+		api.POST("/invitations/accept", rateLimited, h.Auth.AcceptInvitation)
+		// Download links carry their own token instead of a session.
+		api.GET("/attachments/:attachmentId/download", h.Attachment.DownloadAttachment)
+		if h.OIDC != nil {
+			api.GET("/oidc/login", h.OIDC.Login)
+			api.GET("/oidc/callback", h.OIDC.Callback)
+		}
+
+		// Impersonation tokens cannot manage the user's credentials.
+		noImpersonation := middleware.GinDenyImpersonationMiddleware()
+
+		account := api.Group("")
+		account.Use(middleware.GinAuthMiddleware(h.DB))
+		{
+			account.GET("/me", h.Auth.GetCurrentUser)
+			account.PUT("/me/password", noImpersonation, h.Auth.ChangePassword)
+		}
+
+		protected := api.Group("")
+		protected.Use(middleware.GinAuthMiddleware(h.DB))
+		protected.Use(middleware.GinPasswordRotationMiddleware())
+		{
+			protected.POST("/me/2fa/setup", noImpersonation, h.TwoFactor.Setup)
+			protected.GET("/me/2fa/qr.png", noImpersonation, h.TwoFactor.QRCode)
+			protected.POST("/me/2fa/enable", noImpersonation, h.TwoFactor.Enable)
+			protected.POST("/me/2fa/recovery-codes", noImpersonation, h.TwoFactor.RegenerateRecoveryCodes)
+			protected.DELETE("/me/2fa", noImpersonation, h.TwoFactor.Disable)
+			protected.GET("/me/settings", h.Auth.GetSettings)
+			protected.PUT("/me/settings", h.Auth.UpdateSettings)
+			protected.GET("/me/stats", h.Todo.GetStats)
+			protected.GET("/me/mentions", h.Comment.ListMentions)
+			protected.GET("/me/storage", h.Attachment.GetStorageUsage)
+			protected.GET("/me/notifications", h.Notification.ListNotifications)
+			protected.POST("/me/notifications/read", h.Notification.MarkAllNotificationsRead)
+			protected.POST("/me/notifications/:id/read", h.Notification.MarkNotificationRead)
+			protected.GET("/me/notification-preferences", h.Notification.GetPreferences)
+			protected.PUT("/me/notification-preferences", h.Notification.UpdatePreferences)
+			protected.GET("/me/passkeys", h.Passkey.ListPasskeys)
+			protected.POST("/me/passkeys/register/begin", noImpersonation, h.Passkey.BeginRegistration)
+			protected.POST("/me/passkeys/register/finish", noImpersonation, h.Passkey.FinishRegistration)
+			protected.DELETE("/me/passkeys/:id", noImpersonation, h.Passkey.DeletePasskey)
+			protected.GET("/todos", h.Todo.GetTodos)
+			protected.POST("/todos", h.Todo.CreateTodo)
+			protected.GET("/todos/:id", h.Todo.GetTodo)
+			protected.PUT("/todos/:id", h.Todo.UpdateTodo)
+			protected.DELETE("/todos/:id", h.Todo.DeleteTodo)
+			protected.GET("/todos/:id/history", h.Todo.GetTodoHistory)
+			protected.POST("/todos/:id/move", h.Todo.MoveTodo)
+			protected.GET("/todos/:id/comments", h.Comment.ListComments)
+			protected.POST("/todos/:id/comments", h.Comment.CreateComment)
+			protected.PUT("/todos/:id/comments/:commentId", h.Comment.UpdateComment)
+			protected.DELETE("/todos/:id/comments/:commentId", h.Comment.DeleteComment)
+			protected.GET("/todos/:id/comments/:commentId/revisions", h.Comment.ListRevisions)
+			protected.GET("/todos/:id/collaborators", h.Comment.ListCollaborators)
+			protected.POST("/todos/:id/collaborators", h.Comment.AddCollaborator)
+			protected.DELETE("/todos/:id/collaborators/:userId", h.Comment.RemoveCollaborator)
+			protected.GET("/todos/:id/attachments", h.Attachment.ListAttachments)
+			protected.POST("/todos/:id/attachments", h.Attachment.UploadAttachment)
+			protected.DELETE("/todos/:id/attachments/:attachmentId", h.Attachment.DeleteAttachment)
+			protected.GET("/boards", h.Board.ListBoards)
+			protected.POST("/boards", h.Board.CreateBoard)
+			protected.GET("/boards/:id", h.Board.GetBoard)
+			protected.PUT("/boards/:id", h.Board.UpdateBoard)
+			protected.DELETE("/boards/:id", h.Board.DeleteBoard)
+			protected.POST("/boards/:id/columns", h.Board.CreateColumn)
+			protected.PUT("/boards/:id/columns/:columnId", h.Board.UpdateColumn)
+			protected.DELETE("/boards/:id/columns/:columnId", h.Board.DeleteColumn)
+			protected.POST("/boards/:id/cards/:todoId/move", h.Board.MoveCard)
+		}
+
+		admin := api.Group("/admin")
+		admin.Use(middleware.GinAuthMiddleware(h.DB))
+		admin.Use(middleware.GinPasswordRotationMiddleware())
+		admin.Use(middleware.GinAdminMiddleware())
+		if h.AdminRequire2FA {
+			admin.Use(middleware.GinRequireMFAMiddleware())
+		}
+		{
+			admin.GET("/users", h.Admin.GetAllUsers)
+			admin.POST("/users", h.Admin.CreateUser)
+			admin.GET("/users/:id", h.Admin.GetUser)
+			admin.DELETE("/users/:id", h.Admin.DeleteUser)
+			admin.PUT("/users/:id/email", h.Admin.UpdateUserEmail)
+			admin.PUT("/users/:id/role", h.Admin.UpdateUserRole)
+			admin.POST("/users/:id/suspend", h.Admin.SuspendUser)
+			admin.POST("/users/:id/reactivate", h.Admin.ReactivateUser)
+			admin.POST("/users/:id/password-reset", h.Admin.ForcePasswordReset)
+			admin.POST("/users/:id/impersonate", h.Admin.Impersonate)
+			admin.GET("/users/:id/todos", h.Admin.GetUserTodos)
+			admin.DELETE("/users/:id/2fa", h.Admin.ResetUserTwoFactor)
+			admin.GET("/lockouts", h.Admin.GetLockouts)
+			admin.DELETE("/lockouts/:key", h.Admin.ClearLockout)
+			admin.GET("/audit-log", h.Admin.GetAuditLog)
+			admin.POST("/confirmations", h.Admin.RequestConfirmation)
+			admin.GET("/stats", h.Admin.GetStats)
+		}
+	}
+}
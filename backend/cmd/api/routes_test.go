@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"todo-app/backend/internal/handlers"
+	"todo-app/backend/internal/health"
+	"todo-app/backend/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+// TestRoutesMatchOpenAPISpec fails when a route is added, removed or renamed
+// without updating internal/openapi/openapi.json, or the other way round.
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// Enable every optional route so the whole surface is compared.
+	registerRoutes(r, routes{
+		Checker:         health.NewChecker(time.Second),
+		ServeMetrics:    true,
+		AdminRequire2FA: true,
+		Auth:            &handlers.AuthHandler{},
+		TwoFactor:       &handlers.TwoFactorHandler{},
+		Passkey:         &handlers.PasskeyHandler{},
+		OIDC:            &handlers.OIDCHandler{},
+		Todo:            &handlers.TodoHandler{},
+		Board:           &handlers.BoardHandler{},
+		Comment:         &handlers.CommentHandler{},
+		Attachment:      &handlers.AttachmentHandler{},
+		Notification:    &handlers.NotificationHandler{},
+		Admin:           &handlers.AdminHandler{},
+	})
+
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+ginParam.ReplaceAllString(route.Path, "{$1}")] = true
+	}
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Document, &doc); err != nil {
+		t.Fatal(err)
+	}
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	for _, route := range sortedKeys(registered) {
+		if !documented[route] {
+			t.Errorf("%s is registered but missing from openapi.json", route)
+		}
+	}
+	for _, route := range sortedKeys(documented) {
+		if !registered[route] {
+			t.Errorf("%s is in openapi.json but not registered", route)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
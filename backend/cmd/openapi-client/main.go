@@ -0,0 +1,27 @@
+// Command openapi-client generates the typed Go client in package client
+// from the embedded OpenAPI document. Run it through go generate:
+//
+//	go generate ./client
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"todo-app/backend/internal/openapi"
+	"todo-app/backend/internal/openapi/codegen"
+)
+
+func main() {
+	out := flag.String("o", "client.gen.go", "output file")
+	pkg := flag.String("package", "client", "package name")
+	flag.Parse()
+
+	src, err := codegen.Generate(openapi.Document, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
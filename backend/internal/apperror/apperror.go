@@ -0,0 +1,161 @@
+package apperror
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Error is an error that knows how it should be presented to API clients.
+// Code is a stable, machine-readable identifier that clients may switch on;
+// Message is human-readable and may change.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Err is the underlying cause. It is logged but never sent to clients.
+	Err error
+}
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches on Code, so errors.Is(err, ErrNotFound) holds for a copy of
+// ErrNotFound with a cause attached.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithCause returns a copy of e with err as its cause.
+func (e *Error) WithCause(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// WithMessage returns a copy of e with a different message.
+func (e *Error) WithMessage(message string) *Error {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+// Generic errors shared across handlers and middleware.
+var (
+	ErrInvalidBody     = New(http.StatusBadRequest, "invalid_body", "Invalid request body")
+	ErrValidation      = New(http.StatusBadRequest, "validation_failed", "Request validation failed")
+	ErrUnauthorized    = New(http.StatusUnauthorized, "unauthorized", "Unauthorized")
+	ErrForbidden       = New(http.StatusForbidden, "forbidden", "Forbidden")
+	ErrNotFound        = New(http.StatusNotFound, "not_found", "Not found")
+	ErrConflict        = New(http.StatusConflict, "conflict", "Resource already exists")
+	ErrInvalidRef      = New(http.StatusUnprocessableEntity, "invalid_reference", "Referenced resource does not exist")
+	ErrConstraint      = New(http.StatusUnprocessableEntity, "constraint_violation", "Request violates a data constraint")
+	ErrBodyTooLarge    = New(http.StatusRequestEntityTooLarge, "body_too_large", "Request body is too large")
+	ErrTooManyRequests = New(http.StatusTooManyRequests, "too_many_requests", "Too many attempts, please try again later")
+	ErrInternal        = New(http.StatusInternalServerError, "internal_error", "Internal server error")
+	ErrUnavailable     = New(http.StatusServiceUnavailable, "service_unavailable", "Service temporarily unavailable")
+)
+
+// Validation returns a validation error listing every invalid field.
+func Validation(fields ...FieldError) *Error {
+	e := *ErrValidation
+	e.Fields = fields
+	return &e
+}
+
+func Field(field, code, message string) FieldError {
+	return FieldError{Field: field, Code: code, Message: message}
+}
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgTooManyConnections  = "53300"
+)
+
+// Wrap converts err into an *Error. Errors that already are one are
+// returned unchanged. Constraint violations from Postgres map to 409 or 422,
+// and lost or refused database connections to 503. Anything else is a 500
+// with message as the detail; the cause is kept for logging only.
+func Wrap(err error, message string) *Error {
+	if err == nil {
+		return ErrInternal.WithMessage(message)
+	}
+
+	var ae *Error
+	if errors.As(err, &ae) {
+		return ae
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == pgUniqueViolation:
+			return ErrConflict.WithCause(err)
+		case pqErr.Code == pgForeignKeyViolation:
+			return ErrInvalidRef.WithCause(err)
+		case pqErr.Code == pgNotNullViolation, pqErr.Code == pgCheckViolation:
+			return ErrConstraint.WithCause(err)
+		case pqErr.Code == pgTooManyConnections,
+			pqErr.Code.Class() == "08", // connection exception
+			pqErr.Code.Class() == "57": // operator intervention, e.g. shutdown
+			return ErrUnavailable.WithCause(err)
+		}
+	}
+
+	if isConnectionError(err) {
+		return ErrUnavailable.WithCause(err)
+	}
+
+	return ErrInternal.WithMessage(message).WithCause(err)
+}
+
+// IsUniqueViolation reports whether err is a Postgres unique constraint
+// violation, for handlers that want a more specific conflict error.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation
+}
+
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// lib/pq reports some connection failures as plain errors.
+	return strings.Contains(err.Error(), "connection refused")
+}
@@ -0,0 +1,142 @@
+package apperror
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+func TestWrapMapsPostgresErrors(t *testing.T) {
+	tests := []struct {
+		code   pq.ErrorCode
+		status int
+		want   *Error
+	}{
+		{"23505", http.StatusConflict, ErrConflict},
+		{"23503", http.StatusUnprocessableEntity, ErrInvalidRef},
+		{"23502", http.StatusUnprocessableEntity, ErrConstraint},
+		{"23514", http.StatusUnprocessableEntity, ErrConstraint},
+		{"08000", http.StatusServiceUnavailable, ErrUnavailable},
+		{"08006", http.StatusServiceUnavailable, ErrUnavailable},
+		{"53300", http.StatusServiceUnavailable, ErrUnavailable},
+		{"57P01", http.StatusServiceUnavailable, ErrUnavailable},
+		{"42P01", http.StatusInternalServerError, ErrInternal},
+	}
+	for _, tt := range tests {
+		cause := &pq.Error{Code: tt.code, Message: "from postgres"}
+		// Handlers usually see the driver error wrapped.
+		got := Wrap(fmt.Errorf("query: %w", cause), "Failed to fetch todos")
+		if got.Status != tt.status || !errors.Is(got, tt.want) {
+			t.Errorf("Wrap(%s) = %d %s, want %d %s", tt.code, got.Status, got.Code, tt.status, tt.want.Code)
+		}
+		if !errors.Is(got, cause) {
+			t.Errorf("Wrap(%s) dropped the cause", tt.code)
+		}
+	}
+}
+
+func TestWrapConnectionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"conn done", sql.ErrConnDone},
+		{"deadline", context.DeadlineExceeded},
+		{"net", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("i/o timeout")}},
+		{"refused", errors.New("dial tcp 127.0.0.1:5432: connect: connection refused")},
+	}
+	for _, tt := range tests {
+		if got := Wrap(tt.err, "Failed"); got.Status != http.StatusServiceUnavailable {
+			t.Errorf("%s: status = %d, want 503", tt.name, got.Status)
+		}
+	}
+}
+
+func TestWrapOtherErrors(t *testing.T) {
+	cause := errors.New("boom")
+	got := Wrap(cause, "Failed to fetch todos")
+	if got.Status != http.StatusInternalServerError || got.Code != ErrInternal.Code {
+		t.Errorf("Wrap = %d %s, want 500 %s", got.Status, got.Code, ErrInternal.Code)
+	}
+	if got.Message != "Failed to fetch todos" || !errors.Is(got, cause) {
+		t.Errorf("Wrap = %+v, want the message and cause kept", got)
+	}
+	if ErrInternal.Message != "Internal server error" || ErrInternal.Err != nil {
+		t.Error("Wrap modified ErrInternal")
+	}
+
+	if got := Wrap(nil, "Failed"); got.Status != http.StatusInternalServerError || got.Err != nil {
+		t.Errorf("Wrap(nil) = %+v", got)
+	}
+}
+
+func TestWrapPreservesError(t *testing.T) {
+	notFound := New(http.StatusNotFound, "todo_not_found", "Todo not found")
+	if got := Wrap(notFound, "Failed"); got != notFound {
+		t.Errorf("Wrap(*Error) = %+v, want it unchanged", got)
+	}
+	if got := Wrap(fmt.Errorf("lookup: %w", notFound), "Failed"); got != notFound {
+		t.Errorf("Wrap(wrapped *Error) = %+v, want the *Error", got)
+	}
+}
+
+func TestErrorIsMatchesCode(t *testing.T) {
+	withCause := ErrNotFound.WithCause(sql.ErrNoRows).WithMessage("Todo not found")
+	if !errors.Is(withCause, ErrNotFound) {
+		t.Error("copy with cause and message does not match its original")
+	}
+	if errors.Is(withCause, ErrConflict) {
+		t.Error("different codes match")
+	}
+	if !errors.Is(withCause, sql.ErrNoRows) {
+		t.Error("cause not reachable through Unwrap")
+	}
+	if ErrNotFound.Err != nil || ErrNotFound.Message != "Not found" {
+		t.Error("WithCause or WithMessage modified the original")
+	}
+}
+
+func TestRespondWritesProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/todos", func(c *gin.Context) {
+		c.Header("X-Request-ID", "req-1")
+		Respond(c, Validation(Field("title", "required", "Title is required")))
+	})
+	r.GET("/api/fail", func(c *gin.Context) {
+		Respond(c, &pq.Error{Code: "08006", Message: "host db.internal:5432 unreachable"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/todos", nil))
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != ContentType {
+		t.Errorf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code != "validation_failed" || p.Instance != "/api/todos" || p.RequestID != "req-1" ||
+		len(p.Errors) != 1 || p.Errors[0].Field != "title" {
+		t.Errorf("problem = %+v", p)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/fail", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "db.internal") {
+		t.Errorf("cause leaked to the client: %s", body)
+	}
+}
@@ -0,0 +1,48 @@
+package apperror
+
+import (
+	"net/http"
+	"todo-app/backend/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document. Code, RequestID, TraceID
+// and Errors are extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Respond writes err as application/problem+json and aborts the request.
+// Errors that are not an *Error are treated as internal errors. The cause of
+// a server error is attached to the gin context so the access log records
+// it alongside the request id.
+func Respond(c *gin.Context, err error) {
+	ae := Wrap(err, ErrInternal.Message)
+	if ae.Status >= http.StatusInternalServerError && ae.Err != nil {
+		c.Error(ae.Err)
+	}
+
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(ae.Status, Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(ae.Status),
+		Status:    ae.Status,
+		Detail:    ae.Message,
+		Instance:  c.Request.URL.Path,
+		Code:      ae.Code,
+		RequestID: c.Writer.Header().Get("X-Request-ID"),
+		TraceID:   tracing.TraceID(c.Request.Context()),
+		Errors:    ae.Fields,
+	})
+}
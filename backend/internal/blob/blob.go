@@ -0,0 +1,65 @@
+// Package blob stores attachment contents outside the database. Store has a
+// local-filesystem implementation for single-server deployments and an
+// S3-compatible one for everything else; the database keeps only the key.
+package blob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Open for a key that holds no blob.
+var ErrNotFound = errors.New("blob not found")
+
+// Store holds blobs by key. Keys are slash-separated segments of letters,
+// digits, dots, dashes and underscores, such as those made by NewKey.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any blob
+	// already there. A failed Put leaves no partial blob behind.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the blob under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob succeeds.
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by stores that can hand out their own
+// time-limited download URLs, so that downloads bypass the API server.
+type Presigner interface {
+	// PresignGet returns a URL that downloads key until expires. When
+	// filename is not empty, the download is served as an attachment with
+	// that name.
+	PresignGet(key, filename string, expires time.Time) (string, error)
+}
+
+// NewKey returns a new random key under prefix, such as "42/3f9c…".
+func NewKey(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + "/" + hex.EncodeToString(b), nil
+}
+
+// validKey rejects keys that could escape the store's directory or need
+// escaping in a URL.
+func validKey(key string) error {
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+		for i := 0; i < len(segment); i++ {
+			c := segment[i]
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '.' || c == '-' || c == '_') {
+				return fmt.Errorf("invalid blob key %q", key)
+			}
+		}
+	}
+	return nil
+}
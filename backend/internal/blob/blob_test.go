@@ -0,0 +1,191 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore runs the behaviour every Store must share.
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	key, err := NewKey("7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open before Put = %v, want ErrNotFound", err)
+	}
+
+	data := []byte("hello, attachment")
+	if err := store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+		t.Fatalf("Put = %v", err)
+	}
+	rc, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open = %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Open read %q, %v; want %q", got, err, data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete = %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open after Delete = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("second Delete = %v, want nil", err)
+	}
+
+	if err := store.Put(ctx, "../escape", strings.NewReader("x"), 1, ""); err == nil {
+		t.Error("Put accepted a key with a path traversal")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+}
+
+func TestLocalPutRejectsShortContent(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := store.Put(ctx, "1/abc", strings.NewReader("abc"), 10, ""); err == nil {
+		t.Fatal("Put succeeded with fewer bytes than size")
+	}
+	if _, err := store.Open(ctx, "1/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after failed Put = %v, want ErrNotFound", err)
+	}
+}
+
+// fakeS3 is a local stand-in for an S3 bucket served path-style. It checks
+// that requests are signed but does not verify the signatures; the known
+// answer tests in s3_test.go cover those.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	signed := strings.HasPrefix(r.Header.Get("Authorization"), sigAlgorithm+" Credential=AKID/") ||
+		r.URL.Query().Get("X-Amz-Signature") != ""
+	if !signed {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/bucket/")
+	if !ok {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = data
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		if cd := r.URL.Query().Get("response-content-disposition"); cd != "" {
+			w.Header().Set("Content-Disposition", cd)
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newFakeS3(t *testing.T) (*S3, *fakeS3) {
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	store, err := NewS3(S3Config{
+		Endpoint:        srv.URL,
+		Region:          "us-east-1",
+		Bucket:          "bucket",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, fake
+}
+
+func TestS3(t *testing.T) {
+	store, _ := newFakeS3(t)
+	testStore(t, store)
+}
+
+func TestS3PresignedDownload(t *testing.T) {
+	store, fake := newFakeS3(t)
+	fake.objects["7/abc"] = []byte("pdf bytes")
+
+	u, err := store.PresignGet("7/abc", "report.pdf", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "pdf bytes" {
+		t.Fatalf("GET presigned URL = %d %q", resp.StatusCode, body)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename=report.pdf` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	if _, err := store.PresignGet("7/abc", "", time.Now().Add(8*24*time.Hour)); err == nil {
+		t.Error("PresignGet accepted an expiry beyond seven days")
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":    `attachment; filename=report.pdf`,
+		"my report.pdf": `attachment; filename="my report.pdf"`,
+		`a"b.txt`:       `attachment; filename="a\"b.txt"`,
+		"スクリーンショット.png": `attachment; filename*=utf-8''%E3%82%B9%E3%82%AF%E3%83%AA%E3%83%BC%E3%83%B3%E3%82%B7%E3%83%A7%E3%83%83%E3%83%88.png`,
+	}
+	for name, want := range tests {
+		if got := ContentDisposition(name); got != want {
+			t.Errorf("ContentDisposition(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package blob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Purge deletes up to limit of the blobs queued in the blob_deletions
+// table, oldest first. A trigger queues the key of every deleted attachment
+// row, so blobs are cleaned up however the attachment went: deleted on its
+// own, with its todo or with its owner's account. Keys whose blob could not
+// be deleted stay queued for the next run.
+func Purge(ctx context.Context, db *sql.DB, store Store, limit int) error {
+	rows, err := db.QueryContext(ctx,
+		"SELECT storage_key FROM blob_deletions ORDER BY queued_at LIMIT $1",
+		limit,
+	)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "DELETE FROM blob_deletions WHERE storage_key = $1", key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
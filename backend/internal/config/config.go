@@ -0,0 +1,391 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Insecure defaults that are convenient for local development. Validate
+// refuses to start outside development while any of them are in use.
+const (
+	insecureDBPassword    = "postgres"
+	insecureJWTSecret     = "your-secret-key-change-in-production"
+	insecureAdminPassword = "admin123"
+)
+
+// Config is the complete backend configuration. Values come from defaults,
+// then an optional YAML or TOML file, then environment variables. Every
+// field tagged env can also be read from a file named by the same variable
+// with a _FILE suffix, which is how Docker and Kubernetes mount secrets.
+// Fields tagged secret are masked by Redacted.
+type Config struct {
+	Env       string          `yaml:"env" toml:"env" env:"APP_ENV"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Password  PasswordConfig  `yaml:"password" toml:"password"`
+	TOTP      TOTPConfig      `yaml:"totp" toml:"totp"`
+	WebAuthn  WebAuthnConfig  `yaml:"webauthn" toml:"webauthn"`
+	OIDC      OIDCConfig      `yaml:"oidc" toml:"oidc"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Health    HealthConfig    `yaml:"health" toml:"health"`
+	Metrics   MetricsConfig   `yaml:"metrics" toml:"metrics"`
+	Stats     StatsConfig     `yaml:"stats" toml:"stats"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	// Attachments configures file uploads on todos.
+	Attachments AttachmentConfig `yaml:"attachments" toml:"attachments"`
+	// Notifications configures the in-app notification center.
+	Notifications NotificationConfig `yaml:"notifications" toml:"notifications"`
+}
+
+type ServerConfig struct {
+	Port              string   `yaml:"port" toml:"port" env:"PORT"`
+	TrustedProxies    []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	// ShutdownTimeout bounds how long in-flight requests and background
+	// workers get to finish after SIGTERM or SIGINT.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+	// ShutdownDelay keeps serving with readiness failing before draining, so
+	// load balancers have time to stop routing new requests here.
+	ShutdownDelay Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"SERVER_SHUTDOWN_DELAY"`
+	// MaxBodyBytes caps the size of request bodies; larger requests get 413.
+	MaxBodyBytes int `yaml:"max_body_bytes" toml:"max_body_bytes" env:"SERVER_MAX_BODY_BYTES"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins, wildcard subdomains such as
+	// https://*.example.com, or "*" (not allowed with AllowCredentials).
+	AllowedOrigins   []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string `yaml:"allowed_methods" toml:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string `yaml:"allowed_headers" toml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string `yaml:"exposed_headers" toml:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           Duration `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"DB_HOST"`
+	Port     string `yaml:"port" toml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER"`
+	Password string `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `yaml:"name" toml:"name" env:"DB_NAME"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE"`
+}
+
+type AuthConfig struct {
+	JWTSecret       string `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	AdminRequire2FA bool   `yaml:"admin_require_2fa" toml:"admin_require_2fa" env:"ADMIN_REQUIRE_2FA"`
+	// SeedDefaultAdmin creates the default admin account when no admin exists.
+	SeedDefaultAdmin     bool   `yaml:"seed_default_admin" toml:"seed_default_admin" env:"SEED_DEFAULT_ADMIN"`
+	DefaultAdminEmail    string `yaml:"default_admin_email" toml:"default_admin_email" env:"DEFAULT_ADMIN_EMAIL"`
+	DefaultAdminPassword string `yaml:"default_admin_password" toml:"default_admin_password" env:"DEFAULT_ADMIN_PASSWORD" secret:"true"`
+	// InvitationURL is the frontend page that accepts an invitation; the
+	// token is appended as a query parameter.
+	InvitationURL    string   `yaml:"invitation_url" toml:"invitation_url" env:"INVITATION_URL"`
+	InvitationTTL    Duration `yaml:"invitation_ttl" toml:"invitation_ttl" env:"INVITATION_TTL"`
+	ImpersonationTTL Duration `yaml:"impersonation_ttl" toml:"impersonation_ttl" env:"ADMIN_IMPERSONATION_TTL"`
+}
+
+type PasswordConfig struct {
+	MinLength    int    `yaml:"min_length" toml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	MinScore     int    `yaml:"min_score" toml:"min_score" env:"PASSWORD_MIN_SCORE"`
+	BreachedList string `yaml:"breached_list" toml:"breached_list" env:"PASSWORD_BREACHED_LIST"`
+}
+
+type TOTPConfig struct {
+	Issuer string `yaml:"issuer" toml:"issuer" env:"TOTP_ISSUER"`
+}
+
+type WebAuthnConfig struct {
+	RPID      string   `yaml:"rp_id" toml:"rp_id" env:"WEBAUTHN_RP_ID"`
+	RPName    string   `yaml:"rp_name" toml:"rp_name" env:"WEBAUTHN_RP_NAME"`
+	RPOrigins []string `yaml:"rp_origins" toml:"rp_origins" env:"WEBAUTHN_RP_ORIGINS"`
+}
+
+type OIDCConfig struct {
+	IssuerURL         string   `yaml:"issuer_url" toml:"issuer_url" env:"OIDC_ISSUER_URL"`
+	ClientID          string   `yaml:"client_id" toml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret      string   `yaml:"client_secret" toml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL       string   `yaml:"redirect_url" toml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes            []string `yaml:"scopes" toml:"scopes" env:"OIDC_SCOPES"`
+	GroupsClaim       string   `yaml:"groups_claim" toml:"groups_claim" env:"OIDC_GROUPS_CLAIM"`
+	AdminGroups       []string `yaml:"admin_groups" toml:"admin_groups" env:"OIDC_ADMIN_GROUPS"`
+	PostLoginRedirect string   `yaml:"post_login_redirect" toml:"post_login_redirect" env:"OIDC_POST_LOGIN_REDIRECT"`
+}
+
+type RateLimitConfig struct {
+	Store               string   `yaml:"store" toml:"store" env:"RATE_LIMIT_STORE"`
+	IPPerMinute         float64  `yaml:"ip_per_minute" toml:"ip_per_minute" env:"RATE_LIMIT_IP_PER_MINUTE"`
+	IPBurst             int      `yaml:"ip_burst" toml:"ip_burst" env:"RATE_LIMIT_IP_BURST"`
+	AccountPerMinute    float64  `yaml:"account_per_minute" toml:"account_per_minute" env:"RATE_LIMIT_ACCOUNT_PER_MINUTE"`
+	AccountBurst        int      `yaml:"account_burst" toml:"account_burst" env:"RATE_LIMIT_ACCOUNT_BURST"`
+	LockoutThreshold    int      `yaml:"lockout_threshold" toml:"lockout_threshold" env:"LOCKOUT_THRESHOLD"`
+	LockoutBaseDuration Duration `yaml:"lockout_base_duration" toml:"lockout_base_duration" env:"LOCKOUT_BASE_DURATION"`
+	LockoutMaxDuration  Duration `yaml:"lockout_max_duration" toml:"lockout_max_duration" env:"LOCKOUT_MAX_DURATION"`
+}
+
+type HealthConfig struct {
+	CheckTimeout Duration `yaml:"check_timeout" toml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// HasuraEndpoint is the GraphQL endpoint; when set, readiness also checks
+	// Hasura's /healthz on the same host.
+	HasuraEndpoint string `yaml:"hasura_endpoint" toml:"hasura_endpoint" env:"HASURA_GRAPHQL_ENDPOINT"`
+}
+
+// HasuraHealthURL returns Hasura's health endpoint, or "" when no endpoint is
+// configured.
+func (h HealthConfig) HasuraHealthURL() string {
+	u, err := url.Parse(h.HasuraEndpoint)
+	if h.HasuraEndpoint == "" || err != nil {
+		return ""
+	}
+	u.Path = "/healthz"
+	u.RawQuery = ""
+	return u.String()
+}
+
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"METRICS_ENABLED"`
+	// Port serves /metrics on a separate internal listener. When empty,
+	// /metrics is served on the main port.
+	Port string `yaml:"port" toml:"port" env:"METRICS_PORT"`
+}
+
+type StatsConfig struct {
+	// CacheTTL is how long computed statistics are served before they are
+	// recomputed; zero disables the cache.
+	CacheTTL Duration `yaml:"cache_ttl" toml:"cache_ttl" env:"STATS_CACHE_TTL"`
+}
+
+type AttachmentConfig struct {
+	// Store is "local" or "s3".
+	Store    string `yaml:"store" toml:"store" env:"ATTACHMENT_STORE"`
+	LocalDir string `yaml:"local_dir" toml:"local_dir" env:"ATTACHMENT_LOCAL_DIR"`
+	// S3Endpoint is the URL of an S3-compatible service. S3PathStyle puts
+	// the bucket in the path rather than the host name, as MinIO needs.
+	S3Endpoint        string `yaml:"s3_endpoint" toml:"s3_endpoint" env:"ATTACHMENT_S3_ENDPOINT"`
+	S3Region          string `yaml:"s3_region" toml:"s3_region" env:"ATTACHMENT_S3_REGION"`
+	S3Bucket          string `yaml:"s3_bucket" toml:"s3_bucket" env:"ATTACHMENT_S3_BUCKET"`
+	S3AccessKeyID     string `yaml:"s3_access_key_id" toml:"s3_access_key_id" env:"ATTACHMENT_S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `yaml:"s3_secret_access_key" toml:"s3_secret_access_key" env:"ATTACHMENT_S3_SECRET_ACCESS_KEY" secret:"true"`
+	S3PathStyle       bool   `yaml:"s3_path_style" toml:"s3_path_style" env:"ATTACHMENT_S3_PATH_STYLE"`
+	// MaxFileBytes caps one upload; UserQuotaBytes caps the total size of a
+	// user's attachments.
+	MaxFileBytes   int `yaml:"max_file_bytes" toml:"max_file_bytes" env:"ATTACHMENT_MAX_FILE_BYTES"`
+	UserQuotaBytes int `yaml:"user_quota_bytes" toml:"user_quota_bytes" env:"ATTACHMENT_USER_QUOTA_BYTES"`
+	// AllowedTypes are the accepted media types, as detected from the
+	// content rather than declared by the client.
+	AllowedTypes []string `yaml:"allowed_types" toml:"allowed_types" env:"ATTACHMENT_ALLOWED_TYPES"`
+	// URLTTL is how long a download URL stays valid.
+	URLTTL Duration `yaml:"url_ttl" toml:"url_ttl" env:"ATTACHMENT_URL_TTL"`
+	// PublicURL is this server's address as browsers reach it. Download
+	// URLs point here when the store cannot presign its own.
+	PublicURL string `yaml:"public_url" toml:"public_url" env:"ATTACHMENT_PUBLIC_URL"`
+}
+
+type NotificationConfig struct {
+	// DueSoonInterval is how often todos are checked for approaching due
+	// dates.
+	DueSoonInterval Duration `yaml:"due_soon_interval" toml:"due_soon_interval" env:"NOTIFICATION_DUE_SOON_INTERVAL"`
+	// Retention is how long notifications are kept, read or not.
+	Retention Duration `yaml:"retention" toml:"retention" env:"NOTIFICATION_RETENTION"`
+}
+
+type TracingConfig struct {
+	// Exporter is "none" or "otlp".
+	Exporter    string  `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"`
+	ServiceName string  `yaml:"service_name" toml:"service_name" env:"OTEL_SERVICE_NAME"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	// Format is json or text.
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+}
+
+// Duration is a time.Duration written as a string such as "90s" or "1h" in
+// configuration files.
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func Default() *Config {
+	return &Config{
+		Env: EnvProduction,
+		Server: ServerConfig{
+			Port:              "8080",
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			ReadTimeout:       Duration(15 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(60 * time.Second),
+			ShutdownTimeout:   Duration(20 * time.Second),
+			MaxBodyBytes:      1 << 20,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:3000"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-ID", "X-Confirmation-Token"},
+			ExposedHeaders: []string{"X-Request-ID", "X-Trace-Id", "Retry-After"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     "5432",
+			User:     "postgres",
+			Password: insecureDBPassword,
+			Name:     "todoapp",
+			SSLMode:  "disable",
+		},
+		Auth: AuthConfig{
+			JWTSecret:            insecureJWTSecret,
+			AdminRequire2FA:      true,
+			SeedDefaultAdmin:     true,
+			DefaultAdminEmail:    "admin@example.com",
+			DefaultAdminPassword: insecureAdminPassword,
+			InvitationURL:        "http://localhost:3000/invitation",
+			InvitationTTL:        Duration(72 * time.Hour),
+			ImpersonationTTL:     Duration(15 * time.Minute),
+		},
+		Password: PasswordConfig{
+			MinLength: 8,
+			MinScore:  2,
+		},
+		TOTP: TOTPConfig{
+			Issuer: "TODO App",
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:      "localhost",
+			RPName:    "TODO App",
+			RPOrigins: []string{"http://localhost:3000"},
+		},
+		OIDC: OIDCConfig{
+			RedirectURL: "http://localhost:8081/api/oidc/callback",
+			GroupsClaim: "groups",
+		},
+		RateLimit: RateLimitConfig{
+			Store:               "memory",
+			IPPerMinute:         20,
+			IPBurst:             10,
+			AccountPerMinute:    5,
+			AccountBurst:        5,
+			LockoutThreshold:    5,
+			LockoutBaseDuration: Duration(time.Minute),
+			LockoutMaxDuration:  Duration(time.Hour),
+		},
+		Health: HealthConfig{
+			CheckTimeout: Duration(2 * time.Second),
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+		},
+		Stats: StatsConfig{
+			CacheTTL: Duration(time.Minute),
+		},
+		Attachments: AttachmentConfig{
+			Store:          "local",
+			LocalDir:       "data/attachments",
+			S3Region:       "us-east-1",
+			MaxFileBytes:   10 << 20,
+			UserQuotaBytes: 100 << 20,
+			AllowedTypes:   []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"},
+			URLTTL:         Duration(15 * time.Minute),
+			PublicURL:      "http://localhost:8080",
+		},
+		Notifications: NotificationConfig{
+			DueSoonInterval: Duration(15 * time.Minute),
+			Retention:       Duration(90 * 24 * time.Hour),
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "todo-backend",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the optional file at path and
+// the environment. It does not validate the result.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file type %q (want .yaml, .yml or .toml)", ext)
+	}
+
+	return nil
+}
+
+func (c *Config) IsDevelopment() bool {
+	return c.Env == EnvDevelopment
+}
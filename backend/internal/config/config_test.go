@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every variable Load reads, so that the test environment
+// cannot leak into the results.
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	walkFields(reflect.ValueOf(Default()).Elem(), func(field reflect.StructField, v reflect.Value) error {
+		if name := field.Tag.Get("env"); name != "" {
+			t.Setenv(name, "")
+			t.Setenv(name+"_FILE", "")
+		}
+		return nil
+	})
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := `server:
+  port: "9000"
+  read_timeout: 20s
+database:
+  host: db.internal
+  user: file-user
+`
+	secretFile := writeFile(t, "db_password", "from-secret-file\n")
+
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		check func(*Config) (got, want any)
+	}{
+		{"default", "", nil, func(c *Config) (any, any) { return c.Server.Port, "8080" }},
+		{"file over default", yamlFile, nil, func(c *Config) (any, any) { return c.Server.Port, "9000" }},
+		{"default kept when the file omits it", yamlFile, nil, func(c *Config) (any, any) { return c.Database.Port, "5432" }},
+		{"env over file", yamlFile, map[string]string{"PORT": "9100"}, func(c *Config) (any, any) { return c.Server.Port, "9100" }},
+		{"empty env ignored", yamlFile, map[string]string{"DB_USER": ""}, func(c *Config) (any, any) { return c.Database.User, "file-user" }},
+		{"env duration", yamlFile, map[string]string{"SERVER_READ_TIMEOUT": "45s"}, func(c *Config) (any, any) { return c.Server.ReadTimeout.Std(), 45 * time.Second }},
+		{"env list", "", map[string]string{"CORS_ALLOWED_ORIGINS": "https://a.example.com, https://b.example.com,"}, func(c *Config) (any, any) {
+			return strings.Join(c.CORS.AllowedOrigins, " "), "https://a.example.com https://b.example.com"
+		}},
+		{"_FILE over file", yamlFile, map[string]string{"DB_PASSWORD_FILE": secretFile}, func(c *Config) (any, any) { return c.Database.Password, "from-secret-file" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, "config.yaml", tt.file)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := tt.check(cfg); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.toml", "[server]\nport = \"9200\"\n")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != "9200" {
+		t.Errorf("port = %s, want 9200", cfg.Server.Port)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+	}{
+		{"env and _FILE both set", "", map[string]string{"JWT_SECRET": "a", "JWT_SECRET_FILE": "/dev/null"}},
+		{"missing _FILE", "", map[string]string{"JWT_SECRET_FILE": "/nonexistent/jwt"}},
+		{"invalid env value", "", map[string]string{"SERVER_MAX_BODY_BYTES": "lots"}},
+		{"unknown file key", "server:\n  prot: \"9000\"\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, "config.yaml", tt.file)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Load succeeded")
+			}
+		})
+	}
+
+	if _, err := Load(writeFile(t, "config.json", "{}")); err == nil {
+		t.Error("Load accepted a .json file")
+	}
+}
+
+// secureConfig returns defaults with every insecure value replaced.
+func secureConfig() *Config {
+	cfg := Default()
+	cfg.Database.Password = "a-real-database-password"
+	cfg.Auth.JWTSecret = strings.Repeat("s", minJWTSecretLength)
+	cfg.Auth.DefaultAdminPassword = "a-real-admin-password"
+	return cfg
+}
+
+func TestValidateInsecureDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"secure production", EnvProduction, func(*Config) {}, ""},
+		{"default DB password", EnvProduction, func(c *Config) { c.Database.Password = insecureDBPassword }, "DB_PASSWORD"},
+		{"default JWT secret", EnvProduction, func(c *Config) { c.Auth.JWTSecret = insecureJWTSecret }, "JWT_SECRET"},
+		{"short JWT secret", EnvProduction, func(c *Config) { c.Auth.JWTSecret = "short" }, "JWT_SECRET must be at least"},
+		{"default admin password", EnvProduction, func(c *Config) { c.Auth.DefaultAdminPassword = insecureAdminPassword }, "DEFAULT_ADMIN_PASSWORD"},
+		{"default admin password unused", EnvProduction, func(c *Config) {
+			c.Auth.DefaultAdminPassword = insecureAdminPassword
+			c.Auth.SeedDefaultAdmin = false
+		}, ""},
+		{"all defaults in development", EnvDevelopment, func(c *Config) { *c = *Default() }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := secureConfig()
+			tt.modify(cfg)
+			cfg.Env = tt.env
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want an error mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultsOutsideDevelopment(t *testing.T) {
+	err := Default().Validate()
+	if err == nil {
+		t.Fatal("Validate accepted the insecure defaults in production")
+	}
+	for _, name := range []string{"DB_PASSWORD", "JWT_SECRET", "DEFAULT_ADMIN_PASSWORD"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error does not mention %s: %v", name, err)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := secureConfig()
+	cfg.OIDC.ClientSecret = "oidc-client-secret"
+	cfg.Attachments.S3SecretAccessKey = "s3-secret-access-key"
+
+	// Every field tagged secret must be covered.
+	var secrets []string
+	walkFields(reflect.ValueOf(cfg).Elem(), func(field reflect.StructField, v reflect.Value) error {
+		if field.Tag.Get("secret") == "true" {
+			if v.String() == "" {
+				t.Errorf("%s is empty in the test config", field.Name)
+			}
+			secrets = append(secrets, v.String())
+		}
+		return nil
+	})
+	if len(secrets) != 5 {
+		t.Errorf("found %d secret fields, want 5; update this test", len(secrets))
+	}
+
+	redacted := cfg.Redacted()
+	out := fmt.Sprintf("%+v", *redacted)
+	for _, secret := range secrets {
+		if strings.Contains(out, secret) {
+			t.Errorf("Redacted leaks %q", secret)
+		}
+	}
+	if redacted.Database.Password != redactedValue {
+		t.Errorf("DB password = %q, want %q", redacted.Database.Password, redactedValue)
+	}
+	if redacted.Database.User != cfg.Database.User {
+		t.Error("Redacted masked a field that is not secret")
+	}
+	if cfg.Auth.JWTSecret != strings.Repeat("s", minJWTSecretLength) {
+		t.Error("Redacted modified the original")
+	}
+
+	// Empty secrets stay empty, so that they still read as unset.
+	cfg.OIDC.ClientSecret = ""
+	if got := cfg.Redacted().OIDC.ClientSecret; got != "" {
+		t.Errorf("empty secret redacted to %q", got)
+	}
+}
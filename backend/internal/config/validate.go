@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minJWTSecretLength = 32
+
+// Validate reports every problem with the configuration at once. Outside
+// development it also rejects the built-in insecure secrets.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		add("APP_ENV must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env)
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		add("PORT must be a valid port number, got %q", c.Server.Port)
+	}
+	if c.Server.ReadHeaderTimeout <= 0 || c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 ||
+		c.Server.IdleTimeout <= 0 || c.Server.ShutdownTimeout <= 0 {
+		add("server timeouts must be positive")
+	}
+	if c.Server.ShutdownDelay < 0 || c.Server.ShutdownDelay >= c.Server.ShutdownTimeout {
+		add("SERVER_SHUTDOWN_DELAY must be non-negative and shorter than SERVER_SHUTDOWN_TIMEOUT")
+	}
+	if c.Server.MaxBodyBytes <= 0 {
+		add("SERVER_MAX_BODY_BYTES must be positive")
+	}
+	if len(c.CORS.AllowedOrigins) == 0 {
+		add("CORS_ALLOWED_ORIGINS is required")
+	}
+	if c.CORS.MaxAge < 0 {
+		add("CORS_MAX_AGE must not be negative")
+	}
+	if c.Metrics.Enabled && c.Metrics.Port != "" && c.Metrics.Port == c.Server.Port {
+		add("METRICS_PORT must differ from PORT")
+	}
+	if c.Stats.CacheTTL < 0 || c.Stats.CacheTTL > Duration(time.Hour) {
+		add("STATS_CACHE_TTL must be between 0 and 1h")
+	}
+	switch c.Attachments.Store {
+	case "local":
+		if c.Attachments.LocalDir == "" {
+			add("ATTACHMENT_LOCAL_DIR is required when ATTACHMENT_STORE is local")
+		}
+	case "s3":
+		if u, err := url.Parse(c.Attachments.S3Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			add("ATTACHMENT_S3_ENDPOINT must be an absolute URL")
+		}
+		if c.Attachments.S3Region == "" || c.Attachments.S3Bucket == "" ||
+			c.Attachments.S3AccessKeyID == "" || c.Attachments.S3SecretAccessKey == "" {
+			add("ATTACHMENT_S3_REGION, ATTACHMENT_S3_BUCKET, ATTACHMENT_S3_ACCESS_KEY_ID and ATTACHMENT_S3_SECRET_ACCESS_KEY are required when ATTACHMENT_STORE is s3")
+		}
+	default:
+		add("ATTACHMENT_STORE must be local or s3, got %q", c.Attachments.Store)
+	}
+	if c.Attachments.MaxFileBytes <= 0 || c.Attachments.UserQuotaBytes < c.Attachments.MaxFileBytes {
+		add("ATTACHMENT_MAX_FILE_BYTES must be positive and no greater than ATTACHMENT_USER_QUOTA_BYTES")
+	}
+	if len(c.Attachments.AllowedTypes) == 0 {
+		add("ATTACHMENT_ALLOWED_TYPES is required")
+	}
+	// S3 rejects presigned URLs valid for longer than seven days.
+	if c.Attachments.URLTTL < Duration(time.Second) || c.Attachments.URLTTL > Duration(7*24*time.Hour) {
+		add("ATTACHMENT_URL_TTL must be between 1s and 168h")
+	}
+	if u, err := url.Parse(c.Attachments.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		add("ATTACHMENT_PUBLIC_URL must be an absolute URL")
+	}
+	if c.Notifications.DueSoonInterval < Duration(time.Minute) || c.Notifications.DueSoonInterval > Duration(24*time.Hour) {
+		add("NOTIFICATION_DUE_SOON_INTERVAL must be between 1m and 24h")
+	}
+	if c.Notifications.Retention < Duration(24*time.Hour) {
+		add("NOTIFICATION_RETENTION must be at least 24h")
+	}
+	if c.Tracing.Exporter != "none" && c.Tracing.Exporter != "otlp" {
+		add("TRACING_EXPORTER must be none or otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		add("LOG_FORMAT must be json or text, got %q", c.Log.Format)
+	}
+	if c.Health.CheckTimeout <= 0 {
+		add("HEALTH_CHECK_TIMEOUT must be positive")
+	}
+	if c.Health.HasuraEndpoint != "" {
+		if u, err := url.Parse(c.Health.HasuraEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			add("HASURA_GRAPHQL_ENDPOINT must be an absolute URL")
+		}
+	}
+	if c.Database.Host == "" || c.Database.User == "" || c.Database.Name == "" {
+		add("DB_HOST, DB_USER and DB_NAME are required")
+	}
+
+	if c.Auth.JWTSecret == "" {
+		add("JWT_SECRET is required")
+	}
+	if c.Auth.SeedDefaultAdmin && (c.Auth.DefaultAdminEmail == "" || c.Auth.DefaultAdminPassword == "") {
+		add("DEFAULT_ADMIN_EMAIL and DEFAULT_ADMIN_PASSWORD are required when SEED_DEFAULT_ADMIN is enabled")
+	}
+	if u, err := url.Parse(c.Auth.InvitationURL); err != nil || !u.IsAbs() {
+		add("INVITATION_URL must be an absolute URL")
+	}
+	if c.Auth.InvitationTTL <= 0 {
+		add("INVITATION_TTL must be positive")
+	}
+	if c.Auth.ImpersonationTTL <= 0 || c.Auth.ImpersonationTTL > Duration(24*time.Hour) {
+		add("ADMIN_IMPERSONATION_TTL must be positive and at most 24h")
+	}
+
+	if c.Password.MinLength < 1 {
+		add("PASSWORD_MIN_LENGTH must be at least 1")
+	}
+	if c.Password.MinScore < 0 || c.Password.MinScore > 4 {
+		add("PASSWORD_MIN_SCORE must be between 0 and 4")
+	}
+
+	if c.WebAuthn.RPID == "" || len(c.WebAuthn.RPOrigins) == 0 {
+		add("WEBAUTHN_RP_ID and WEBAUTHN_RP_ORIGINS are required")
+	}
+
+	if c.OIDC.IssuerURL != "" {
+		if u, err := url.Parse(c.OIDC.IssuerURL); err != nil || u.Scheme == "" || u.Host == "" {
+			add("OIDC_ISSUER_URL must be an absolute URL")
+		}
+		if c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "" {
+			add("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+		}
+	}
+
+	switch c.RateLimit.Store {
+	case "memory", "postgres":
+	default:
+		add("RATE_LIMIT_STORE must be \"memory\" or \"postgres\", got %q", c.RateLimit.Store)
+	}
+	if c.RateLimit.IPBurst < 1 || c.RateLimit.AccountBurst < 1 {
+		add("RATE_LIMIT_IP_BURST and RATE_LIMIT_ACCOUNT_BURST must be at least 1")
+	}
+	if c.RateLimit.LockoutBaseDuration <= 0 || c.RateLimit.LockoutMaxDuration < c.RateLimit.LockoutBaseDuration {
+		add("LOCKOUT_BASE_DURATION must be positive and no greater than LOCKOUT_MAX_DURATION")
+	}
+
+	if !c.IsDevelopment() {
+		if c.Database.Password == insecureDBPassword {
+			add("DB_PASSWORD is set to the insecure default; set a real password or APP_ENV=development")
+		}
+		if c.Auth.JWTSecret == insecureJWTSecret {
+			add("JWT_SECRET is set to the insecure default; set a real secret or APP_ENV=development")
+		} else if len(c.Auth.JWTSecret) < minJWTSecretLength {
+			add("JWT_SECRET must be at least %d bytes", minJWTSecretLength)
+		}
+		if c.Auth.SeedDefaultAdmin && c.Auth.DefaultAdminPassword == insecureAdminPassword {
+			add("DEFAULT_ADMIN_PASSWORD is set to the insecure default; set a real password, SEED_DEFAULT_ADMIN=false or APP_ENV=development")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+const redactedValue = "********"
+
+// Redacted returns a copy with every secret field masked, suitable for
+// printing or logging.
+func (c *Config) Redacted() *Config {
+	copied := *c
+	walkFields(reflect.ValueOf(&copied).Elem(), func(field reflect.StructField, v reflect.Value) error {
+		if field.Tag.Get("secret") == "true" && v.Kind() == reflect.String && v.String() != "" {
+			v.SetString(redactedValue)
+		}
+		return nil
+	})
+	return &copied
+}
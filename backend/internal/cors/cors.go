@@ -0,0 +1,220 @@
+// Package cors implements a Cross-Origin Resource Sharing policy with an
+// origin allow-list.
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"todo-app/backend/internal/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errOriginNotAllowed = apperror.New(http.StatusForbidden, "cors_origin_not_allowed", "Origin is not allowed")
+
+type Config struct {
+	// AllowedOrigins lists exact origins such as https://app.example.com,
+	// wildcard subdomains such as https://*.example.com, or "*" for any
+	// origin.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts may read besides the
+	// CORS-safelisted ones.
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight result; zero leaves
+	// it to the browser.
+	MaxAge time.Duration
+}
+
+// Policy decides which cross-origin requests browsers may make.
+type Policy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []wildcard
+	methods     string
+	headers     string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// wildcard matches origins of the form prefix + subdomain(s) + suffix, e.g.
+// "https://" + "eu.app" + ".example.com".
+type wildcard struct {
+	prefix, suffix string
+}
+
+// New validates cfg and builds a Policy. Allowing any origin together with
+// credentials is rejected: browsers refuse it, and echoing arbitrary origins
+// instead would expose authenticated responses to every site.
+func New(cfg Config) (*Policy, error) {
+	p := &Policy{
+		origins:     make(map[string]bool),
+		methods:     strings.Join(upper(cfg.AllowedMethods), ", "),
+		headers:     strings.Join(cfg.AllowedHeaders, ", "),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			w, err := parseWildcard(origin)
+			if err != nil {
+				return nil, err
+			}
+			p.wildcards = append(p.wildcards, w)
+		default:
+			if err := checkOrigin(origin); err != nil {
+				return nil, err
+			}
+			p.origins[origin] = true
+		}
+	}
+
+	if p.anyOrigin && p.credentials {
+		return nil, errors.New(`cors: allowed origin "*" cannot be combined with credentials`)
+	}
+	return p, nil
+}
+
+func parseWildcard(pattern string) (wildcard, error) {
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	if !strings.HasSuffix(prefix, "://") || !strings.HasPrefix(suffix, ".") || strings.Contains(suffix, "*") {
+		return wildcard{}, fmt.Errorf("cors: invalid wildcard origin %q, want e.g. https://*.example.com", pattern)
+	}
+	if err := checkOrigin(prefix + "x" + suffix); err != nil {
+		return wildcard{}, err
+	}
+	return wildcard{prefix: prefix, suffix: suffix}, nil
+}
+
+// checkOrigin rejects entries that are not a bare scheme://host[:port], such
+// as URLs with a path or a trailing slash, which would never match.
+func checkOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("cors: invalid origin %q, want scheme://host[:port]", origin)
+	}
+	return nil
+}
+
+// AllowOrigin reports whether origin may make cross-origin requests.
+func (p *Policy) AllowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if w.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w wildcard) match(origin string) bool {
+	if len(origin) <= len(w.prefix)+len(w.suffix) ||
+		!strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+		return false
+	}
+	sub := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+	if sub[0] == '.' || sub[len(sub)-1] == '.' {
+		return false
+	}
+	for i := 0; i < len(sub); i++ {
+		c := sub[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// GinMiddleware applies the policy. It must run before routing-dependent
+// middleware so that preflight requests, which have no matching route, are
+// answered here.
+func (p *Policy) GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		// Responses differ by Origin, so shared caches must key on it.
+		h.Add("Vary", "Origin")
+
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions &&
+			c.GetHeader("Access-Control-Request-Method") != ""
+
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !p.AllowOrigin(origin) {
+			if preflight {
+				apperror.Respond(c, errOriginNotAllowed)
+				return
+			}
+			// Without CORS headers the browser hides the response from the
+			// calling script; same-origin and non-browser clients are
+			// unaffected.
+			c.Next()
+			return
+		}
+
+		if p.anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if p.methods != "" {
+				h.Set("Access-Control-Allow-Methods", p.methods)
+			}
+			if p.headers != "" {
+				h.Set("Access-Control-Allow-Headers", p.headers)
+			}
+			if p.maxAge != "" {
+				h.Set("Access-Control-Max-Age", p.maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if p.exposed != "" {
+			h.Set("Access-Control-Expose-Headers", p.exposed)
+		}
+		c.Next()
+	}
+}
+
+func upper(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(strings.TrimSpace(v))
+	}
+	return out
+}
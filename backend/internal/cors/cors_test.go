@@ -0,0 +1,208 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T, cfg Config) *gin.Engine {
+	t.Helper()
+
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(p.GinMiddleware())
+	r.GET("/api/todos", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"todos": []string{}})
+	})
+	return r
+}
+
+func testConfig() Config {
+	return Config{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com"},
+		AllowedMethods: []string{"GET", "POST", "PATCH"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+func serve(r http.Handler, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/todos", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func preflight(r http.Handler, origin string) *httptest.ResponseRecorder {
+	return serve(r, http.MethodOptions, origin, map[string]string{
+		"Access-Control-Request-Method":  "PATCH",
+		"Access-Control-Request-Headers": "authorization, content-type",
+	})
+}
+
+func hasVary(h http.Header, value string) bool {
+	for _, v := range h.Values("Vary") {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPreflightAllowedOrigin(t *testing.T) {
+	r := newTestRouter(t, testConfig())
+
+	w := preflight(r, "https://app.example.com")
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST, PATCH",
+		"Access-Control-Allow-Headers": "Authorization, Content-Type",
+		"Access-Control-Max-Age":       "600",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q without credentials enabled", got)
+	}
+	for _, v := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+		if !hasVary(w.Header(), v) {
+			t.Errorf("Vary does not include %s: %v", v, w.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestPreflightWildcardSubdomain(t *testing.T) {
+	r := newTestRouter(t, testConfig())
+
+	for _, origin := range []string{"https://pr-42.preview.example.com", "https://a.b.preview.example.com"} {
+		w := preflight(r, origin)
+		if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != origin {
+			t.Errorf("%s: status %d, allow-origin %q", origin, w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+}
+
+func TestPreflightRejectedOrigin(t *testing.T) {
+	r := newTestRouter(t, testConfig())
+
+	for _, origin := range []string{
+		"https://evil.com",
+		"http://app.example.com",             // scheme differs
+		"https://app.example.com:8443",       // port differs
+		"https://preview.example.com",        // wildcard needs a subdomain
+		"https://evilpreview.example.com",    // not a subdomain
+		"https://x.preview.example.com.evil", // suffix spoof
+	} {
+		w := preflight(r, origin)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403", origin, w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q", origin, got)
+		}
+	}
+}
+
+func TestSimpleRequestAllowedOrigin(t *testing.T) {
+	cfg := testConfig()
+	cfg.AllowCredentials = true
+	r := newTestRouter(t, cfg)
+
+	w := serve(r, http.MethodGet, "https://APP.example.com", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://APP.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID" {
+		t.Errorf("Access-Control-Expose-Headers = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("Access-Control-Allow-Methods = %q on a simple request", got)
+	}
+	if !hasVary(w.Header(), "Origin") {
+		t.Errorf("Vary does not include Origin: %v", w.Header().Values("Vary"))
+	}
+}
+
+func TestSimpleRequestRejectedOriginStillServed(t *testing.T) {
+	r := newTestRouter(t, testConfig())
+
+	w := serve(r, http.MethodGet, "https://evil.com", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if !hasVary(w.Header(), "Origin") {
+		t.Errorf("Vary does not include Origin: %v", w.Header().Values("Vary"))
+	}
+}
+
+func TestSameOriginRequestHasNoCORSHeaders(t *testing.T) {
+	r := newTestRouter(t, testConfig())
+
+	w := serve(r, http.MethodGet, "", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	for k := range w.Header() {
+		if strings.HasPrefix(k, "Access-Control-") {
+			t.Errorf("unexpected header %s", k)
+		}
+	}
+}
+
+func TestAnyOrigin(t *testing.T) {
+	r := newTestRouter(t, Config{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}})
+
+	w := serve(r, http.MethodGet, "https://anywhere.test", nil)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"any origin with credentials": {AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		"origin with path":            {AllowedOrigins: []string{"https://app.example.com/"}},
+		"missing scheme":              {AllowedOrigins: []string{"app.example.com"}},
+		"wildcard in host middle":     {AllowedOrigins: []string{"https://app*.example.com"}},
+		"bare wildcard domain":        {AllowedOrigins: []string{"https://*example.com"}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: New succeeded", name)
+		}
+	}
+}
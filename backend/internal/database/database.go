@@ -1,11 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"todo-app/backend/internal/tracing"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Config struct {
@@ -23,47 +25,383 @@ func Connect(cfg Config) (*sql.DB, error) {
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
 
-	db, err := sql.Open("postgres", connStr)
+	connector, err := pq.NewConnector(connStr)
 	if err != nil {
 		return nil, err
 	}
+	db := sql.OpenDB(tracing.WrapConnector(connector))
 
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
 
-	log.Println("Successfully connected to database")
+	slog.Info("Successfully connected to database")
 	return db, nil
 }
 
-func RunMigrations(db *sql.DB) error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			password VARCHAR(255) NOT NULL,
-			is_admin BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS todos (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			title VARCHAR(255) NOT NULL,
-			description TEXT,
-			completed BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_todos_user_id ON todos(user_id)`,
-	}
+// migrations are applied in order on every start and must be idempotent.
+// Append new statements to the end; the schema version is their count.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		email VARCHAR(255) UNIQUE NOT NULL,
+		password VARCHAR(255) NOT NULL,
+		is_admin BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS todos (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		completed BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_todos_user_id ON todos(user_id)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS user_recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes(user_id)`,
+	`CREATE TABLE IF NOT EXISTS webauthn_credentials (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		credential_id BYTEA UNIQUE NOT NULL,
+		public_key BYTEA NOT NULL,
+		attestation_type VARCHAR(32) NOT NULL DEFAULT '',
+		transports VARCHAR(255) NOT NULL DEFAULT '',
+		aaguid BYTEA,
+		sign_count BIGINT NOT NULL DEFAULT 0,
+		backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+		backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+		name VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_used_at TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id)`,
+	`CREATE TABLE IF NOT EXISTS webauthn_sessions (
+		id VARCHAR(64) PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		ceremony VARCHAR(16) NOT NULL,
+		data JSONB NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_issuer VARCHAR(255)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR(255)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_identity ON users(oidc_issuer, oidc_subject)`,
+	`CREATE TABLE IF NOT EXISTS oidc_states (
+		state VARCHAR(64) PRIMARY KEY,
+		nonce VARCHAR(64) NOT NULL,
+		code_verifier VARCHAR(128) NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+		key VARCHAR(255) PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS auth_lockouts (
+		key VARCHAR(255) PRIMARY KEY,
+		failures INTEGER NOT NULL,
+		locked_until TIMESTAMP,
+		last_failure_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS schema_version (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL
+	)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP`,
+	`CREATE TABLE IF NOT EXISTS user_invitations (
+		token_hash VARCHAR(64) PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_user_invitations_user_id ON user_invitations(user_id)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		actor_id INTEGER,
+		impersonated_by INTEGER,
+		action VARCHAR(64) NOT NULL,
+		target_user_id INTEGER,
+		method VARCHAR(16) NOT NULL DEFAULT '',
+		route VARCHAR(255) NOT NULL DEFAULT '',
+		status INTEGER NOT NULL DEFAULT 0,
+		request_id VARCHAR(128) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_target_user_id ON audit_log(target_user_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_impersonated_by ON audit_log(impersonated_by)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP`,
+	// Todos completed before completed_at existed count as completed at
+	// their last update. The triggers below set completed_at on every
+	// completion, so later runs normally find nothing left to backfill.
+	`UPDATE todos SET completed_at = updated_at WHERE completed AND completed_at IS NULL`,
+	// A trigger rather than the handlers maintains completed_at, so that
+	// todos changed through Hasura are covered too.
+	`CREATE OR REPLACE FUNCTION todos_set_completed_at() RETURNS trigger AS $$
+	BEGIN
+		IF NEW.completed IS NOT TRUE THEN
+			NEW.completed_at := NULL;
+		ELSIF TG_OP = 'INSERT' OR OLD.completed IS NOT TRUE THEN
+			NEW.completed_at := CURRENT_TIMESTAMP;
+		END IF;
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`,
+	// Superseded by todos_sync_status below, so only created where the
+	// trigger does not exist yet; replacing it on every start would detach
+	// the status sync until the later statement runs.
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'todos_completed_at' AND tgrelid = 'todos'::regclass) THEN
+			CREATE TRIGGER todos_completed_at
+				BEFORE INSERT OR UPDATE OF completed ON todos
+				FOR EACH ROW EXECUTE FUNCTION todos_set_completed_at();
+		END IF;
+	END
+	$$`,
+	`CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_users_last_seen_at ON users(last_seen_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_todos_created_at ON todos(created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_todos_completed_at ON todos(completed_at)`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS due_date DATE`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC'`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'todo'
+		CHECK (status IN ('todo', 'in_progress', 'blocked', 'done', 'cancelled'))`,
+	// Runs while todos_completed_at still only fires on completed, so the
+	// completed_at values backfilled above are kept. Once todos_sync_status
+	// keeps status and completed in step it matches no rows.
+	`UPDATE todos SET status = 'done' WHERE completed AND status = 'todo'`,
+	`CREATE TABLE IF NOT EXISTS todo_status_history (
+		id BIGSERIAL PRIMARY KEY,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		from_status VARCHAR(16),
+		to_status VARCHAR(16) NOT NULL,
+		changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_todo_status_history_todo_id ON todo_status_history(todo_id)`,
+	// status is the source of truth and completed mirrors it. Writers that
+	// only set completed, such as Hasura clients, move the status between
+	// done and todo instead. Allowed transitions are enforced by the API.
+	`CREATE OR REPLACE FUNCTION todos_sync_status() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'INSERT' THEN
+			IF NEW.completed IS TRUE AND NEW.status = 'todo' THEN
+				NEW.status := 'done';
+			END IF;
+		ELSIF NEW.status = OLD.status AND NEW.completed IS DISTINCT FROM OLD.completed THEN
+			IF NEW.completed IS TRUE THEN
+				NEW.status := 'done';
+			ELSIF OLD.status = 'done' THEN
+				NEW.status := 'todo';
+			END IF;
+		END IF;
 
+		NEW.completed := NEW.status = 'done';
+		IF NEW.status <> 'done' THEN
+			NEW.completed_at := NULL;
+		ELSIF TG_OP = 'INSERT' OR OLD.status <> 'done' THEN
+			NEW.completed_at := CURRENT_TIMESTAMP;
+		END IF;
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`,
+	`CREATE OR REPLACE TRIGGER todos_completed_at
+		BEFORE INSERT OR UPDATE OF completed, status ON todos
+		FOR EACH ROW EXECUTE FUNCTION todos_sync_status()`,
+	`CREATE OR REPLACE FUNCTION todos_record_status() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'INSERT' THEN
+			INSERT INTO todo_status_history (todo_id, to_status) VALUES (NEW.id, NEW.status);
+		ELSIF NEW.status <> OLD.status THEN
+			INSERT INTO todo_status_history (todo_id, from_status, to_status) VALUES (NEW.id, OLD.status, NEW.status);
+		END IF;
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql`,
+	`CREATE OR REPLACE TRIGGER todos_status_history
+		AFTER INSERT OR UPDATE OF completed, status ON todos
+		FOR EACH ROW EXECUTE FUNCTION todos_record_status()`,
+	// Rank keys for manual ordering, compared byte by byte. A todo has none
+	// until it is first moved.
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS position VARCHAR(64) COLLATE "C"`,
+	`CREATE INDEX IF NOT EXISTS idx_todos_user_position ON todos(user_id, position NULLS FIRST, id DESC)`,
+	`CREATE TABLE IF NOT EXISTS boards (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_boards_user_id ON boards(user_id)`,
+	// A todo's column follows from its status, so a board shows each status
+	// in at most one column.
+	`CREATE TABLE IF NOT EXISTS board_columns (
+		id SERIAL PRIMARY KEY,
+		board_id INTEGER NOT NULL REFERENCES boards(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		status VARCHAR(16) NOT NULL
+			CHECK (status IN ('todo', 'in_progress', 'blocked', 'done', 'cancelled')),
+		wip_limit INTEGER CHECK (wip_limit > 0),
+		position INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (board_id, status)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_todos_user_status ON todos(user_id, status)`,
+	// Comments outlive their author's account, without the author.
+	`CREATE TABLE IF NOT EXISTS todo_comments (
+		id SERIAL PRIMARY KEY,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		edited_at TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_todo_comments_todo_id ON todo_comments(todo_id, id)`,
+	// Earlier bodies of edited comments; created_at is when the body was
+	// replaced.
+	`CREATE TABLE IF NOT EXISTS todo_comment_revisions (
+		id SERIAL PRIMARY KEY,
+		comment_id INTEGER NOT NULL REFERENCES todo_comments(id) ON DELETE CASCADE,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_todo_comment_revisions_comment_id ON todo_comment_revisions(comment_id)`,
+	`CREATE TABLE IF NOT EXISTS todo_comment_mentions (
+		comment_id INTEGER NOT NULL REFERENCES todo_comments(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		PRIMARY KEY (comment_id, user_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_todo_comment_mentions_user_id ON todo_comment_mentions(user_id, comment_id)`,
+	// Attachment contents live in a blob store under storage_key; user_id
+	// is the uploader, whose quota the size counts against.
+	`CREATE TABLE IF NOT EXISTS attachments (
+		id SERIAL PRIMARY KEY,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		filename VARCHAR(255) NOT NULL,
+		content_type VARCHAR(255) NOT NULL,
+		size BIGINT NOT NULL CHECK (size >= 0),
+		storage_key VARCHAR(255) NOT NULL UNIQUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_attachments_todo_id ON attachments(todo_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_attachments_user_id ON attachments(user_id)`,
+	// Blobs whose attachment row is gone, waiting for blob.Purge. Queuing
+	// from a trigger catches cascaded deletes of todos and users too.
+	`CREATE TABLE IF NOT EXISTS blob_deletions (
+		storage_key VARCHAR(255) PRIMARY KEY,
+		queued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE OR REPLACE FUNCTION attachments_queue_blob_deletion() RETURNS trigger AS $$
+	BEGIN
+		INSERT INTO blob_deletions (storage_key) VALUES (OLD.storage_key)
+		ON CONFLICT DO NOTHING;
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql`,
+	`CREATE OR REPLACE TRIGGER attachments_blob_deletion
+		AFTER DELETE ON attachments
+		FOR EACH ROW EXECUTE FUNCTION attachments_queue_blob_deletion()`,
+	// dedup_key names the event behind a generated notification, so that it
+	// is announced only once. NULLs never conflict.
+	`CREATE TABLE IF NOT EXISTS notifications (
+		id BIGSERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		kind VARCHAR(32) NOT NULL,
+		todo_id INTEGER REFERENCES todos(id) ON DELETE CASCADE,
+		message TEXT NOT NULL,
+		dedup_key VARCHAR(255),
+		read_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, dedup_key)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL`,
+	`CREATE INDEX IF NOT EXISTS idx_notifications_created_at ON notifications(created_at)`,
+	// Users without a row get the defaults.
+	`CREATE TABLE IF NOT EXISTS notification_preferences (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		due_soon BOOLEAN NOT NULL DEFAULT TRUE,
+		due_soon_days INTEGER NOT NULL DEFAULT 1 CHECK (due_soon_days BETWEEN 0 AND 7),
+		admin_actions BOOLEAN NOT NULL DEFAULT TRUE
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_todos_due_date ON todos(due_date) WHERE due_date IS NOT NULL`,
+	// password_changed_at is compared with token issue times, which are
+	// absolute. Existing values were written in the session time zone, which
+	// is how the conversion reads them.
+	`DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns
+		           WHERE table_name = 'users' AND column_name = 'password_changed_at'
+		             AND data_type = 'timestamp without time zone') THEN
+			ALTER TABLE users ALTER COLUMN password_changed_at TYPE TIMESTAMPTZ;
+		END IF;
+	END
+	$$`,
+	// Collaborators take part in a todo's comments alongside its owner.
+	`CREATE TABLE IF NOT EXISTS todo_collaborators (
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (todo_id, user_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_todo_collaborators_user_id ON todo_collaborators(user_id)`,
+}
+
+func RunMigrations(db *sql.DB) error {
 	for _, migration := range migrations {
 		if _, err := db.Exec(migration); err != nil {
 			return fmt.Errorf("migration failed: %v", err)
 		}
 	}
 
-	log.Println("Migrations completed successfully")
+	_, err := db.Exec(`
+		INSERT INTO schema_version (id, version) VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET version = GREATEST(schema_version.version, EXCLUDED.version)`,
+		SchemaVersion(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record schema version: %v", err)
+	}
+
+	slog.Info("Migrations completed successfully", "schema_version", SchemaVersion())
+	return nil
+}
+
+// SchemaVersion is the schema version this binary migrates to.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// CheckSchemaVersion reports an error unless the database has been migrated
+// to at least SchemaVersion.
+func CheckSchemaVersion(ctx context.Context, db *sql.DB) error {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT version FROM schema_version WHERE id = 1").Scan(&version)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("schema has not been migrated")
+	}
+	if err != nil {
+		return err
+	}
+	if version < SchemaVersion() {
+		return fmt.Errorf("schema version %d is behind expected version %d", version, SchemaVersion())
+	}
 	return nil
 }
@@ -1,29 +1,205 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/audit"
+	"todo-app/backend/internal/logging"
+	"todo-app/backend/internal/metrics"
+	"todo-app/backend/internal/middleware"
 	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/notify"
+	"todo-app/backend/internal/ratelimit"
+	"todo-app/backend/internal/stats"
+	"todo-app/backend/internal/validation"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AdminHandler struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Limiter *ratelimit.Limiter
+	// InvitationURL is the frontend page new users open to set a password.
+	InvitationURL    string
+	InvitationTTL    time.Duration
+	ImpersonationTTL time.Duration
+	Stats            *stats.Cache[*stats.Dashboard]
 }
 
-func NewAdminHandler(db *sql.DB) *AdminHandler {
-	return &AdminHandler{DB: db}
+func NewAdminHandler(db *sql.DB, limiter *ratelimit.Limiter, invitationURL string, invitationTTL, impersonationTTL, statsTTL time.Duration) *AdminHandler {
+	return &AdminHandler{
+		DB:               db,
+		Limiter:          limiter,
+		InvitationURL:    invitationURL,
+		InvitationTTL:    invitationTTL,
+		ImpersonationTTL: impersonationTTL,
+		Stats:            stats.NewCache[*stats.Dashboard](statsTTL),
+	}
+}
+
+// adminUserColumns are the users columns returned by the admin endpoints, in
+// the order scanAdminUser expects.
+const adminUserColumns = `id, email, is_admin, must_change_password, totp_enabled, suspended_at, created_at, updated_at`
+
+func scanAdminUser(row interface{ Scan(...any) error }, user *models.User) error {
+	return row.Scan(
+		&user.ID, &user.Email, &user.IsAdmin, &user.MustChangePassword,
+		&user.TwoFactorEnabled, &user.SuspendedAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+}
+
+// record counts an admin action and writes it to the audit log. The change
+// has already been made by then, so a failed write is logged rather than
+// failing the request.
+func (h *AdminHandler) record(c *gin.Context, action string, targetUserID int) {
+	metrics.AdminAction(action)
+
+	admin, _ := middleware.GetUserFromGinContext(c)
+	err := audit.Record(context.WithoutCancel(c.Request.Context()), h.DB, audit.Entry{
+		ActorID:      admin.UserID,
+		Action:       action,
+		TargetUserID: targetUserID,
+		Method:       c.Request.Method,
+		Route:        c.FullPath(),
+		RequestID:    c.GetString(logging.RequestIDKey),
+	})
+	if err != nil {
+		logging.FromContext(c).Error("Failed to record admin action", "action", action, "error", err)
+	}
+}
+
+// notify tells the target of an admin action what was done to their account.
+// Like record it runs after the change, so a failure is only logged.
+func (h *AdminHandler) notify(c *gin.Context, targetUserID int, message string) {
+	if admin, _ := middleware.GetUserFromGinContext(c); admin.UserID == targetUserID {
+		return
+	}
+	if err := notify.AdminAction(context.WithoutCancel(c.Request.Context()), h.DB, targetUserID, message); err != nil {
+		logging.FromContext(c).Error("Failed to notify user of admin action", "user_id", targetUserID, "error", err)
+	}
+}
+
+// confirmationHeader carries the token from RequestConfirmation.
+const confirmationHeader = "X-Confirmation-Token"
+
+// confirmedActions are the destructive actions that need a confirmation
+// token. Changing a role only needs one when admin rights are revoked.
+var confirmedActions = map[string]bool{
+	audit.ActionDeleteUser:         true,
+	audit.ActionUpdateRole:         true,
+	audit.ActionSuspendUser:        true,
+	audit.ActionForcePasswordReset: true,
+	audit.ActionResetTwoFactor:     true,
+}
+
+// confirmed checks the confirmation token of a destructive action. It writes
+// the error response itself and reports whether to continue.
+func (h *AdminHandler) confirmed(c *gin.Context, admin middleware.UserContext, action string, targetUserID int) bool {
+	token := c.GetHeader(confirmationHeader)
+	if token == "" || middleware.VerifyConfirmationToken(token, admin.UserID, action, targetUserID) != nil {
+		apperror.Respond(c, errConfirmationRequired)
+		return false
+	}
+	return true
+}
+
+// ensureAdminRemains fails with errLastAdmin if userID is the only active
+// admin. It locks every active admin row until tx ends, so concurrent
+// demotions, deletions and suspensions queue up and the later ones see the
+// earlier ones' result instead of each counting the other as remaining.
+func ensureAdminRemains(ctx context.Context, tx *sql.Tx, userID int) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM users
+		 WHERE is_admin AND suspended_at IS NULL
+		 ORDER BY id
+		 FOR UPDATE`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var active int
+	var targetActive bool
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		active++
+		if id == userID {
+			targetActive = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if targetActive && active == 1 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+// RequestConfirmation issues the token a destructive action must carry in
+// the X-Confirmation-Token header. It is bound to the admin, the action and
+// the target user, and expires after two minutes.
+func (h *AdminHandler) RequestConfirmation(c *gin.Context) {
+	ctx := c.Request.Context()
+	admin, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.ConfirmationRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+	if !confirmedActions[req.Action] {
+		apperror.Respond(c, apperror.Validation(
+			apperror.Field("action", "invalid_action", "Action does not need confirmation"),
+		))
+		return
+	}
+
+	var exists bool
+	err := h.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", req.UserID).Scan(&exists)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch user"))
+		return
+	}
+	if !exists {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+
+	token, expiresAt, err := middleware.GenerateConfirmationToken(admin.UserID, req.Action, req.UserID)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ConfirmationResponse{
+		ConfirmationToken: token,
+		ExpiresAt:         expiresAt,
+	})
 }
 
 func (h *AdminHandler) GetAllUsers(c *gin.Context) {
-	rows, err := h.DB.Query(
-		`SELECT id, email, is_admin, created_at, updated_at
+	ctx := c.Request.Context()
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT `+adminUserColumns+`
 		 FROM users ORDER BY created_at DESC`,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch users"))
 		return
 	}
 	defer rows.Close()
@@ -31,9 +207,8 @@ func (h *AdminHandler) GetAllUsers(c *gin.Context) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		err := rows.Scan(&user.ID, &user.Email, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan user"})
+		if err := scanAdminUser(rows, &user); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan user"))
 			return
 		}
 		users = append(users, user)
@@ -47,25 +222,26 @@ func (h *AdminHandler) GetAllUsers(c *gin.Context) {
 }
 
 func (h *AdminHandler) GetUser(c *gin.Context) {
+	ctx := c.Request.Context()
 	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		apperror.Respond(c, errInvalidUserID)
 		return
 	}
 
 	var user models.User
-	err = h.DB.QueryRow(
-		`SELECT id, email, is_admin, created_at, updated_at
+	err = scanAdminUser(h.DB.QueryRowContext(ctx,
+		`SELECT `+adminUserColumns+`
 		 FROM users WHERE id = $1`,
 		userID,
-	).Scan(&user.ID, &user.Email, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt)
+	), &user)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apperror.Respond(c, errUserNotFound)
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch user"))
 		return
 	}
 
@@ -73,76 +249,149 @@ func (h *AdminHandler) GetUser(c *gin.Context) {
 }
 
 func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	admin, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
 	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		apperror.Respond(c, errInvalidUserID)
+		return
+	}
+	if userID == admin.UserID {
+		apperror.Respond(c, errCannotTargetSelf)
+		return
+	}
+	if !h.confirmed(c, admin, audit.ActionDeleteUser, userID) {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete user"))
 		return
 	}
+	defer tx.Rollback()
 
-	result, err := h.DB.Exec("DELETE FROM users WHERE id = $1", userID)
+	if err := ensureAdminRemains(ctx, tx, userID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete user"))
+		return
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete user"))
 		return
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil || rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete user"))
 		return
 	}
 
+	h.record(c, audit.ActionDeleteUser, userID)
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
 func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
+	ctx := c.Request.Context()
+	admin, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
 	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		apperror.Respond(c, errInvalidUserID)
 		return
 	}
 
 	var req struct {
 		IsAdmin bool `json:"is_admin"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
 		return
 	}
 
+	if !req.IsAdmin {
+		if userID == admin.UserID {
+			apperror.Respond(c, errCannotTargetSelf)
+			return
+		}
+		if !h.confirmed(c, admin, audit.ActionUpdateRole, userID) {
+			return
+		}
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update user role"))
+		return
+	}
+	defer tx.Rollback()
+
+	if !req.IsAdmin {
+		if err := ensureAdminRemains(ctx, tx, userID); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to update user role"))
+			return
+		}
+	}
+
 	var user models.User
-	err = h.DB.QueryRow(
+	err = scanAdminUser(tx.QueryRowContext(ctx,
 		`UPDATE users SET is_admin = $1, updated_at = CURRENT_TIMESTAMP
 		 WHERE id = $2
-		 RETURNING id, email, is_admin, created_at, updated_at`,
+		 RETURNING `+adminUserColumns,
 		req.IsAdmin, userID,
-	).Scan(&user.ID, &user.Email, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt)
+	), &user)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apperror.Respond(c, errUserNotFound)
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update user role"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update user role"))
 		return
 	}
 
+	h.record(c, audit.ActionUpdateRole, userID)
+	if req.IsAdmin {
+		h.notify(c, userID, "An administrator granted you admin rights.")
+	} else {
+		h.notify(c, userID, "An administrator revoked your admin rights.")
+	}
 	c.JSON(http.StatusOK, user)
 }
 
 func (h *AdminHandler) GetUserTodos(c *gin.Context) {
+	ctx := c.Request.Context()
 	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		apperror.Respond(c, errInvalidUserID)
 		return
 	}
 
-	rows, err := h.DB.Query(
-		`SELECT id, user_id, title, description, completed, created_at, updated_at
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT `+todoColumns+`
 		 FROM todos WHERE user_id = $1 ORDER BY created_at DESC`,
 		userID,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch todos"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch todos"))
 		return
 	}
 	defer rows.Close()
@@ -150,12 +399,8 @@ func (h *AdminHandler) GetUserTodos(c *gin.Context) {
 	var todos []models.Todo
 	for rows.Next() {
 		var todo models.Todo
-		err := rows.Scan(
-			&todo.ID, &todo.UserID, &todo.Title, &todo.Description,
-			&todo.Completed, &todo.CreatedAt, &todo.UpdatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan todo"})
+		if err := scanTodo(rows, &todo); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan todo"))
 			return
 		}
 		todos = append(todos, todo)
@@ -167,3 +412,414 @@ func (h *AdminHandler) GetUserTodos(c *gin.Context) {
 
 	c.JSON(http.StatusOK, todos)
 }
+
+func (h *AdminHandler) ResetUserTwoFactor(c *gin.Context) {
+	ctx := c.Request.Context()
+	admin, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidUserID)
+		return
+	}
+	if !h.confirmed(c, admin, audit.ActionResetTwoFactor, userID) {
+		return
+	}
+
+	var exists bool
+	err = h.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch user"))
+		return
+	}
+	if !exists {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+
+	if err := resetTwoFactor(ctx, h.DB, userID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to reset two-factor authentication"))
+		return
+	}
+
+	h.record(c, audit.ActionResetTwoFactor, userID)
+	h.notify(c, userID, "An administrator turned off two-factor authentication for your account.")
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+func (h *AdminHandler) GetLockouts(c *gin.Context) {
+	lockouts, err := h.Limiter.Store.Lockouts(c.Request.Context())
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch lockouts"))
+		return
+	}
+
+	c.JSON(http.StatusOK, lockouts)
+}
+
+func (h *AdminHandler) ClearLockout(c *gin.Context) {
+	key := c.Param("key")
+	if key == "" {
+		apperror.Respond(c, errInvalidLockout)
+		return
+	}
+
+	if err := h.Limiter.Store.ClearFailures(c.Request.Context(), key); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to clear lockout"))
+		return
+	}
+
+	h.record(c, audit.ActionClearLockout, 0)
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared successfully"})
+}
+
+// CreateUser creates an account without a password and returns an invitation
+// link through which the user sets one.
+func (h *AdminHandler) CreateUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	admin, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.CreateUserRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create user"))
+		return
+	}
+	defer tx.Rollback()
+
+	// An empty hash never matches, so the account cannot be signed into with
+	// a password until the invitation is accepted.
+	var user models.User
+	err = scanAdminUser(tx.QueryRowContext(ctx,
+		`INSERT INTO users (email, password, is_admin) VALUES ($1, '', $2)
+		 RETURNING `+adminUserColumns,
+		req.Email, req.IsAdmin,
+	), &user)
+	if apperror.IsUniqueViolation(err) {
+		apperror.Respond(c, errEmailTaken)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create user"))
+		return
+	}
+
+	token, expiresAt, err := createInvitation(ctx, tx, user.ID, admin.UserID, h.InvitationTTL)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create invitation"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create user"))
+		return
+	}
+
+	link, err := url.Parse(h.InvitationURL)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create invitation"))
+		return
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	h.record(c, audit.ActionCreateUser, user.ID)
+	c.JSON(http.StatusCreated, models.InvitationResponse{
+		User:          user,
+		InvitationURL: link.String(),
+		ExpiresAt:     expiresAt,
+	})
+}
+
+func (h *AdminHandler) UpdateUserEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidUserID)
+		return
+	}
+
+	var req models.UpdateEmailRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	var user models.User
+	err = scanAdminUser(h.DB.QueryRowContext(ctx,
+		`UPDATE users SET email = $1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2
+		 RETURNING `+adminUserColumns,
+		req.Email, userID,
+	), &user)
+
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if apperror.IsUniqueViolation(err) {
+		apperror.Respond(c, errEmailTaken)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update email"))
+		return
+	}
+
+	h.record(c, audit.ActionUpdateEmail, userID)
+	h.notify(c, userID, "An administrator changed your email address to "+user.Email+".")
+	c.JSON(http.StatusOK, user)
+}
+
+// SuspendUser blocks the account from signing in. Its existing sessions stop
+// working at once because GinAuthMiddleware checks the flag on every request.
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	h.setSuspended(c, true)
+}
+
+func (h *AdminHandler) ReactivateUser(c *gin.Context) {
+	h.setSuspended(c, false)
+}
+
+func (h *AdminHandler) setSuspended(c *gin.Context, suspend bool) {
+	ctx := c.Request.Context()
+	admin, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidUserID)
+		return
+	}
+	if userID == admin.UserID {
+		apperror.Respond(c, errCannotTargetSelf)
+		return
+	}
+
+	if suspend && !h.confirmed(c, admin, audit.ActionSuspendUser, userID) {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update user"))
+		return
+	}
+	defer tx.Rollback()
+
+	// Suspending twice keeps the original timestamp.
+	query := `UPDATE users SET suspended_at = COALESCE(suspended_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $1
+		 RETURNING ` + adminUserColumns
+	action := audit.ActionSuspendUser
+	if suspend {
+		if err := ensureAdminRemains(ctx, tx, userID); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to update user"))
+			return
+		}
+	} else {
+		query = `UPDATE users SET suspended_at = NULL, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $1
+		 RETURNING ` + adminUserColumns
+		action = audit.ActionReactivateUser
+	}
+
+	var user models.User
+	err = scanAdminUser(tx.QueryRowContext(ctx, query, userID), &user)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update user"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update user"))
+		return
+	}
+
+	h.record(c, action, userID)
+	if suspend {
+		h.notify(c, userID, "An administrator suspended your account.")
+	} else {
+		h.notify(c, userID, "An administrator reactivated your account.")
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ForcePasswordReset signs the user out everywhere and requires a new
+// password at the next sign-in.
+func (h *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	ctx := c.Request.Context()
+	admin, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidUserID)
+		return
+	}
+	if !h.confirmed(c, admin, audit.ActionForcePasswordReset, userID) {
+		return
+	}
+
+	// Moving password_changed_at forward revokes every token issued so far.
+	var user models.User
+	err = scanAdminUser(h.DB.QueryRowContext(ctx,
+		`UPDATE users
+		 SET must_change_password = TRUE, password_changed_at = CURRENT_TIMESTAMP,
+		     updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $1
+		 RETURNING `+adminUserColumns,
+		userID,
+	), &user)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update user"))
+		return
+	}
+
+	h.record(c, audit.ActionForcePasswordReset, userID)
+	h.notify(c, userID, "An administrator signed you out everywhere and asked you to choose a new password.")
+	c.JSON(http.StatusOK, user)
+}
+
+// Impersonate issues a short-lived token for acting as another user. Every
+// request made with it is written to the audit log under the admin's id, and
+// it cannot reach the admin routes or change the user's credentials.
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	ctx := c.Request.Context()
+	admin, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidUserID)
+		return
+	}
+	if userID == admin.UserID {
+		apperror.Respond(c, errCannotTargetSelf)
+		return
+	}
+
+	var user models.User
+	err = scanAdminUser(h.DB.QueryRowContext(ctx,
+		`SELECT `+adminUserColumns+` FROM users WHERE id = $1`,
+		userID,
+	), &user)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch user"))
+		return
+	}
+	if user.IsAdmin {
+		apperror.Respond(c, errCannotImpersonate)
+		return
+	}
+	if user.SuspendedAt != nil {
+		apperror.Respond(c, errAccountSuspended)
+		return
+	}
+
+	expiresAt := time.Now().Add(h.ImpersonationTTL).Truncate(time.Second)
+	token, err := middleware.GenerateToken(user.ID, false, middleware.WithImpersonator(admin.UserID, h.ImpersonationTTL))
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+		return
+	}
+
+	h.record(c, audit.ActionImpersonate, userID)
+	h.notify(c, userID, "An administrator started a session acting as you.")
+	c.JSON(http.StatusOK, models.ImpersonationResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      user,
+	})
+}
+
+const (
+	defaultAuditLogLimit = 100
+	maxAuditLogLimit     = 500
+)
+
+// GetAuditLog lists admin actions and impersonated requests, newest first,
+// optionally only those involving the user_id query parameter.
+func (h *AdminHandler) GetAuditLog(c *gin.Context) {
+	var userID int
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id <= 0 {
+			apperror.Respond(c, errInvalidUserID)
+			return
+		}
+		userID = id
+	}
+
+	limit := defaultAuditLogLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxAuditLogLimit {
+			apperror.Respond(c, errInvalidLimit.WithMessage("Limit must be between 1 and "+strconv.Itoa(maxAuditLogLimit)))
+			return
+		}
+		limit = n
+	}
+
+	entries, err := audit.List(c.Request.Context(), h.DB, userID, limit)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch audit log"))
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetStats returns the dashboard statistics. from and to are inclusive
+// dates (YYYY-MM-DD, UTC) and default to the last 30 days; bucket is day,
+// week or month.
+func (h *AdminHandler) GetStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	r, ok := statsRange(c, time.Now().UTC())
+	if !ok {
+		return
+	}
+
+	dashboard, err := h.Stats.Get(r.String(), func() (*stats.Dashboard, error) {
+		return stats.AdminDashboard(ctx, h.DB, r)
+	})
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to compute statistics"))
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
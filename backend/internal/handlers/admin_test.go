@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"todo-app/backend/internal/audit"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// adminUser is a users row of adminDB.
+type adminUser struct {
+	isAdmin   bool
+	suspended bool
+}
+
+// adminDB keeps users 1 and 2 (admins) and 3 in memory and answers the
+// admin handlers' and the auth middleware's queries from them. audit holds
+// the audit_log rows written, as their arguments.
+type adminDB struct {
+	users map[int64]*adminUser
+	audit [][]driver.Value
+}
+
+func newAdminDB(t *testing.T) (*sqltest.DB, *adminDB, *AdminHandler) {
+	t.Helper()
+
+	middleware.SetJWTSecret("test-secret")
+	state := &adminDB{users: map[int64]*adminUser{
+		1: {isAdmin: true},
+		2: {isAdmin: true},
+		3: {},
+	}}
+	userRow := func(id int64) sqltest.Result {
+		user, ok := state.users[id]
+		columns := []string{"id", "email", "is_admin", "must_change_password", "totp_enabled", "suspended_at", "created_at", "updated_at"}
+		if !ok {
+			return sqltest.Result{Columns: columns}
+		}
+		var suspendedAt driver.Value
+		if user.suspended {
+			suspendedAt = testTime
+		}
+		return sqltest.Row(columns, id, "user@example.com", user.isAdmin, false, false, suspendedAt, testTime, testTime)
+	}
+
+	fake, db := sqltest.New(t)
+	fake.On("SELECT password_changed_at, is_admin", func(args []driver.Value) sqltest.Result {
+		user := state.users[args[0].(int64)]
+		var suspendedAt driver.Value
+		if user.suspended {
+			suspendedAt = testTime
+		}
+		return sqltest.Row([]string{"password_changed_at", "is_admin", "must_change_password", "suspended_at", "last_seen_at"},
+			time.Now().Add(-time.Hour), user.isAdmin, false, suspendedAt, time.Now())
+	})
+	fake.On("SELECT id FROM users\n\t\t WHERE is_admin AND suspended_at IS NULL", func([]driver.Value) sqltest.Result {
+		result := sqltest.Result{Columns: []string{"id"}}
+		for id := int64(1); id <= 3; id++ {
+			if user, ok := state.users[id]; ok && user.isAdmin && !user.suspended {
+				result.Rows = append(result.Rows, []driver.Value{id})
+			}
+		}
+		return result
+	})
+	fake.On("SELECT "+adminUserColumns+" FROM users WHERE id = $1", func(args []driver.Value) sqltest.Result {
+		return userRow(args[0].(int64))
+	})
+	fake.On("DELETE FROM users WHERE id = $1", func(args []driver.Value) sqltest.Result {
+		id := args[0].(int64)
+		if _, ok := state.users[id]; !ok {
+			return sqltest.Result{}
+		}
+		delete(state.users, id)
+		return sqltest.Result{RowsAffected: 1}
+	})
+	fake.On("UPDATE users SET is_admin = $1", func(args []driver.Value) sqltest.Result {
+		id := args[1].(int64)
+		if user, ok := state.users[id]; ok {
+			user.isAdmin = args[0].(bool)
+		}
+		return userRow(id)
+	})
+	fake.On("UPDATE users SET suspended_at = COALESCE(suspended_at, CURRENT_TIMESTAMP)", func(args []driver.Value) sqltest.Result {
+		id := args[0].(int64)
+		if user, ok := state.users[id]; ok {
+			user.suspended = true
+		}
+		return userRow(id)
+	})
+	fake.On("INSERT INTO audit_log", func(args []driver.Value) sqltest.Result {
+		state.audit = append(state.audit, args)
+		return sqltest.Result{RowsAffected: 1}
+	})
+	fake.On("INSERT INTO notifications", func([]driver.Value) sqltest.Result {
+		return sqltest.Result{RowsAffected: 1}
+	})
+
+	return fake, state, NewAdminHandler(db, nil, "", time.Hour, time.Hour, time.Minute)
+}
+
+// confirmation returns the confirmation header admin 1 sends for action on
+// targetID.
+func confirmation(t *testing.T, action string, targetID int) string {
+	t.Helper()
+
+	token, _, err := middleware.GenerateConfirmationToken(1, action, targetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAdminSafeguards(t *testing.T) {
+	middleware.SetJWTSecret("test-secret")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		// setup runs before the request. Admin 1 acts with the rights its
+		// middleware saw, so a setup that demotes them stands for a
+		// concurrent request that committed first.
+		setup  func(*adminDB)
+		status int
+		code   string
+	}{
+		{"delete", http.MethodDelete, "/api/admin/users/3", "", confirmation(t, audit.ActionDeleteUser, 3), nil, http.StatusOK, ""},
+		{"delete without confirmation", http.MethodDelete, "/api/admin/users/3", "", "", nil, http.StatusPreconditionRequired, "confirmation_required"},
+		{"delete confirmed for another user", http.MethodDelete, "/api/admin/users/3", "", confirmation(t, audit.ActionDeleteUser, 2), nil, http.StatusPreconditionRequired, "confirmation_required"},
+		{"delete confirmed for another action", http.MethodDelete, "/api/admin/users/3", "", confirmation(t, audit.ActionSuspendUser, 3), nil, http.StatusPreconditionRequired, "confirmation_required"},
+		{"delete yourself", http.MethodDelete, "/api/admin/users/1", "", confirmation(t, audit.ActionDeleteUser, 1), nil, http.StatusConflict, "cannot_target_self"},
+		{"delete the last active admin", http.MethodDelete, "/api/admin/users/2", "", confirmation(t, audit.ActionDeleteUser, 2),
+			func(s *adminDB) { s.users[1].isAdmin = false }, http.StatusConflict, "last_admin"},
+		{"delete an admin while another remains", http.MethodDelete, "/api/admin/users/2", "", confirmation(t, audit.ActionDeleteUser, 2), nil, http.StatusOK, ""},
+		{"demote", http.MethodPut, "/api/admin/users/2/role", `{"is_admin": false}`, confirmation(t, audit.ActionUpdateRole, 2), nil, http.StatusOK, ""},
+		{"demote without confirmation", http.MethodPut, "/api/admin/users/2/role", `{"is_admin": false}`, "", nil, http.StatusPreconditionRequired, "confirmation_required"},
+		{"demote yourself", http.MethodPut, "/api/admin/users/1/role", `{"is_admin": false}`, confirmation(t, audit.ActionUpdateRole, 1), nil, http.StatusConflict, "cannot_target_self"},
+		{"demote the last active admin", http.MethodPut, "/api/admin/users/2/role", `{"is_admin": false}`, confirmation(t, audit.ActionUpdateRole, 2),
+			func(s *adminDB) { s.users[1].isAdmin = false }, http.StatusConflict, "last_admin"},
+		// A suspended admin does not count as remaining.
+		{"demote the last unsuspended admin", http.MethodPut, "/api/admin/users/2/role", `{"is_admin": false}`, confirmation(t, audit.ActionUpdateRole, 2),
+			func(s *adminDB) { s.users[1].suspended = true }, http.StatusConflict, "last_admin"},
+		{"promote without confirmation", http.MethodPut, "/api/admin/users/3/role", `{"is_admin": true}`, "", nil, http.StatusOK, ""},
+		{"suspend the last active admin", http.MethodPost, "/api/admin/users/2/suspend", "", confirmation(t, audit.ActionSuspendUser, 2),
+			func(s *adminDB) { s.users[1].isAdmin = false }, http.StatusConflict, "last_admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, state, h := newAdminDB(t)
+			if tt.setup != nil {
+				tt.setup(state)
+			}
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.DELETE("/api/admin/users/:id", withUser(1, h.DeleteUser))
+			r.PUT("/api/admin/users/:id/role", withUser(1, h.UpdateUserRole))
+			r.POST("/api/admin/users/:id/suspend", withUser(1, h.SuspendUser))
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set(confirmationHeader, tt.token)
+			}
+			actions := counter(t, "todoapp_admin_actions_total")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if got, want := counter(t, "todoapp_admin_actions_total")-actions, float64(len(state.audit)); got != want {
+				t.Errorf("admin_actions_total rose by %v, want %v", got, want)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.code != "" && !strings.Contains(w.Body.String(), `"code":"`+tt.code+`"`) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.code)
+			}
+			if tt.status == http.StatusOK {
+				if len(state.audit) != 1 {
+					t.Errorf("%d audit entries, want 1", len(state.audit))
+				}
+				return
+			}
+			for _, q := range fake.Calls() {
+				if strings.HasPrefix(q, "DELETE") || strings.HasPrefix(q, "UPDATE") {
+					t.Errorf("user changed despite the refusal: %s", q)
+				}
+			}
+			if len(state.audit) != 0 {
+				t.Errorf("refused action was audited: %v", state.audit)
+			}
+		})
+	}
+}
+
+// newAdminAPI wires the admin handler behind the middleware the way
+// routes.go does, with GET /api/todos standing for the user routes.
+func newAdminAPI(t *testing.T) (*gin.Engine, *adminDB) {
+	t.Helper()
+
+	_, state, h := newAdminDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/todos", middleware.GinAuthMiddleware(h.DB), func(c *gin.Context) {
+		c.JSON(http.StatusOK, []models.Todo{})
+	})
+	admin := r.Group("/api/admin", middleware.GinAuthMiddleware(h.DB), middleware.GinAdminMiddleware())
+	admin.POST("/users/:id/suspend", h.SuspendUser)
+	admin.POST("/users/:id/impersonate", h.Impersonate)
+	return r, state
+}
+
+func request(r http.Handler, method, path, token string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSuspensionEndsExistingSessions(t *testing.T) {
+	r, _ := newAdminAPI(t)
+	adminToken, err := middleware.GenerateToken(1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userToken, err := middleware.GenerateToken(3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := request(r, http.MethodGet, "/api/todos", userToken); w.Code != http.StatusOK {
+		t.Fatalf("before suspension: status = %d: %s", w.Code, w.Body.String())
+	}
+	w := request(r, http.MethodPost, "/api/admin/users/3/suspend", adminToken,
+		confirmationHeader, confirmation(t, audit.ActionSuspendUser, 3))
+	if w.Code != http.StatusOK {
+		t.Fatalf("suspend: status = %d: %s", w.Code, w.Body.String())
+	}
+	w = request(r, http.MethodGet, "/api/todos", userToken)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"code":"account_suspended"`) {
+		t.Errorf("after suspension: status = %d: %s, want account_suspended", w.Code, w.Body.String())
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	r, state := newAdminAPI(t)
+	adminToken, err := middleware.GenerateToken(1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := request(r, http.MethodPost, "/api/admin/users/3/impersonate", adminToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("impersonate: status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.ImpersonationResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	claims := &middleware.Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(resp.Token, claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 3 || claims.ImpersonatedBy != 1 || claims.IsAdmin {
+		t.Errorf("claims = user %d, impersonated_by %d, admin %v, want user 3 by 1", claims.UserID, claims.ImpersonatedBy, claims.IsAdmin)
+	}
+	state.audit = nil
+
+	// The user's own routes work and are audited under the admin.
+	if w := request(r, http.MethodGet, "/api/todos", resp.Token); w.Code != http.StatusOK {
+		t.Fatalf("todos: status = %d: %s", w.Code, w.Body.String())
+	}
+	if len(state.audit) != 1 || state.audit[0][0] != int64(3) || state.audit[0][1] != int64(1) || state.audit[0][2] != audit.ActionImpersonatedRequest {
+		t.Errorf("audit = %v, want one impersonated request by admin 1", state.audit)
+	}
+
+	// Even when the impersonated user is an admin in the meantime, the
+	// token cannot reach the admin routes.
+	state.users[3].isAdmin = true
+	w = request(r, http.MethodPost, "/api/admin/users/2/impersonate", resp.Token)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"code":"impersonation_not_allowed"`) {
+		t.Errorf("admin route: status = %d: %s, want impersonation_not_allowed", w.Code, w.Body.String())
+	}
+}
+
+func TestImpersonateRefusals(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		setup  func(*adminDB)
+		status int
+		code   string
+	}{
+		{"yourself", "/api/admin/users/1/impersonate", nil, http.StatusConflict, "cannot_target_self"},
+		{"an admin", "/api/admin/users/2/impersonate", nil, http.StatusConflict, "cannot_impersonate_admin"},
+		{"a suspended user", "/api/admin/users/3/impersonate", func(s *adminDB) { s.users[3].suspended = true }, http.StatusForbidden, "account_suspended"},
+		{"a missing user", "/api/admin/users/9/impersonate", nil, http.StatusNotFound, "user_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, state := newAdminAPI(t)
+			if tt.setup != nil {
+				tt.setup(state)
+			}
+			token, err := middleware.GenerateToken(1, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := request(r, http.MethodPost, tt.path, token)
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), `"code":"`+tt.code+`"`) {
+				t.Errorf("status = %d: %s, want %d %s", w.Code, w.Body.String(), tt.status, tt.code)
+			}
+			if len(state.audit) != 0 {
+				t.Errorf("refused impersonation was audited: %v", state.audit)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/blob"
+	"todo-app/backend/internal/logging"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AttachmentHandler struct {
+	DB    *sql.DB
+	Store blob.Store
+	// MaxFileBytes caps a single upload and QuotaBytes the total size of a
+	// user's uploads.
+	MaxFileBytes int64
+	QuotaBytes   int64
+	// AllowedTypes are the media types uploads may have, as sniffed from
+	// their content.
+	AllowedTypes []string
+	URLTTL       time.Duration
+	// PublicURL is where clients reach the API, for download links served
+	// by the API itself.
+	PublicURL string
+}
+
+func NewAttachmentHandler(db *sql.DB, store blob.Store, maxFileBytes, quotaBytes int64, allowedTypes []string, urlTTL time.Duration, publicURL string) *AttachmentHandler {
+	return &AttachmentHandler{
+		DB:           db,
+		Store:        store,
+		MaxFileBytes: maxFileBytes,
+		QuotaBytes:   quotaBytes,
+		AllowedTypes: allowedTypes,
+		URLTTL:       urlTTL,
+		PublicURL:    strings.TrimRight(publicURL, "/"),
+	}
+}
+
+// sniffLen is how much of an upload http.DetectContentType looks at.
+const sniffLen = 512
+
+// attachmentColumns are the columns scanAttachment expects, followed by the
+// storage key.
+const attachmentColumns = `id, todo_id, filename, content_type, size, created_at, storage_key`
+
+func scanAttachment(row interface{ Scan(...any) error }, attachment *models.Attachment, key *string) error {
+	return row.Scan(
+		&attachment.ID, &attachment.TodoID, &attachment.Filename, &attachment.ContentType,
+		&attachment.Size, &attachment.CreatedAt, key,
+	)
+}
+
+// sign sets the download URL of attachment. Stores that presign their own
+// URLs serve the download directly; otherwise the URL points at
+// DownloadAttachment.
+func (h *AttachmentHandler) sign(attachment *models.Attachment, key string) error {
+	expiresAt := time.Now().Add(h.URLTTL).Truncate(time.Second)
+	if presigner, ok := h.Store.(blob.Presigner); ok {
+		u, err := presigner.PresignGet(key, attachment.Filename, expiresAt)
+		if err != nil {
+			return err
+		}
+		attachment.URL = u
+	} else {
+		token, err := middleware.GenerateDownloadToken(attachment.ID, expiresAt)
+		if err != nil {
+			return err
+		}
+		attachment.URL = fmt.Sprintf("%s/api/attachments/%d/download?token=%s", h.PublicURL, attachment.ID, url.QueryEscape(token))
+	}
+	attachment.URLExpiresAt = expiresAt
+	return nil
+}
+
+// attachmentParams reads the todo ID and checks that the user owns the todo.
+func (h *AttachmentHandler) attachmentParams(c *gin.Context) (userID, todoID int, ok bool) {
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return 0, 0, false
+	}
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidTodoID)
+		return 0, 0, false
+	}
+
+	var exists bool
+	err = h.DB.QueryRowContext(c.Request.Context(),
+		"SELECT EXISTS(SELECT 1 FROM todos WHERE id = $1 AND user_id = $2)",
+		todoID, userCtx.UserID,
+	).Scan(&exists)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch todo"))
+		return 0, 0, false
+	}
+	if !exists {
+		apperror.Respond(c, errTodoNotFound)
+		return 0, 0, false
+	}
+	return userCtx.UserID, todoID, true
+}
+
+func attachmentID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("attachmentId"))
+	if err != nil {
+		apperror.Respond(c, errInvalidFileID)
+		return 0, false
+	}
+	return id, true
+}
+
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func storageUsed(ctx context.Context, q rowQueryer, userID int) (int64, error) {
+	var used int64
+	err := q.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = $1",
+		userID,
+	).Scan(&used)
+	return used, err
+}
+
+// attachmentFilename reduces an uploaded file name to its base name without
+// control characters, as at most 255 characters.
+func attachmentFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(name, "")))
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	return name
+}
+
+// allowedType reports whether the sniffed content type is one of
+// AllowedTypes. Parameters such as charset are ignored.
+func (h *AttachmentHandler) allowedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range h.AllowedTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// discard deletes a blob whose attachment row was never written. A failure
+// only leaks storage, so it is logged.
+func (h *AttachmentHandler) discard(c *gin.Context, key string) {
+	if err := h.Store.Delete(context.WithoutCancel(c.Request.Context()), key); err != nil {
+		logging.FromContext(c).Warn("Failed to delete orphaned blob", "storage_key", key, "error", err)
+	}
+}
+
+// UploadAttachment attaches the multipart "file" field to a todo. The type
+// is sniffed from the content rather than taken from the client, and the
+// upload must fit in the user's quota.
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, todoID, ok := h.attachmentParams(c)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apperror.Respond(c, apperror.ErrBodyTooLarge)
+			return
+		}
+		apperror.Respond(c, apperror.Validation(apperror.Field("file", "required", "A file is required")))
+		return
+	}
+	if header.Size > h.MaxFileBytes {
+		apperror.Respond(c, apperror.ErrBodyTooLarge.WithMessage(fmt.Sprintf("Attachments may be at most %d bytes", h.MaxFileBytes)))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to read upload"))
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to read upload"))
+		return
+	}
+	contentType := http.DetectContentType(head[:n])
+	if !h.allowedType(contentType) {
+		apperror.Respond(c, errAttachmentType.WithMessage("Files of type "+contentType+" are not allowed"))
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to read upload"))
+		return
+	}
+
+	// Checked again under a lock below; this only avoids storing a blob
+	// that cannot be kept.
+	used, err := storageUsed(ctx, h.DB, userID)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to check storage quota"))
+		return
+	}
+	if used+header.Size > h.QuotaBytes {
+		apperror.Respond(c, errAttachmentQuota)
+		return
+	}
+
+	key, err := blob.NewKey(strconv.Itoa(userID))
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to store attachment"))
+		return
+	}
+	if err := h.Store.Put(ctx, key, file, header.Size, contentType); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to store attachment"))
+		return
+	}
+
+	attachment := models.Attachment{
+		TodoID:      todoID,
+		Filename:    attachmentFilename(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+	}
+	if err := h.insert(ctx, userID, key, &attachment); err != nil {
+		h.discard(c, key)
+		apperror.Respond(c, err)
+		return
+	}
+	if err := h.sign(&attachment, key); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to sign download URL"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// insert records an uploaded attachment. The user's row is locked while the
+// quota is checked, so that concurrent uploads cannot overshoot it together.
+func (h *AttachmentHandler) insert(ctx context.Context, userID int, key string, attachment *models.Attachment) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return apperror.Wrap(err, "Failed to save attachment")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", userID); err != nil {
+		return apperror.Wrap(err, "Failed to save attachment")
+	}
+	used, err := storageUsed(ctx, tx, userID)
+	if err != nil {
+		return apperror.Wrap(err, "Failed to check storage quota")
+	}
+	if used+attachment.Size > h.QuotaBytes {
+		return errAttachmentQuota
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO attachments (todo_id, user_id, filename, content_type, size, storage_key)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		attachment.TodoID, userID, attachment.Filename, attachment.ContentType, attachment.Size, key,
+	).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		return apperror.Wrap(err, "Failed to save attachment")
+	}
+	if err := tx.Commit(); err != nil {
+		return apperror.Wrap(err, "Failed to save attachment")
+	}
+	return nil
+}
+
+// ListAttachments lists a todo's attachments, oldest first, each with a
+// fresh download URL.
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	ctx := c.Request.Context()
+	_, todoID, ok := h.attachmentParams(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT `+attachmentColumns+` FROM attachments WHERE todo_id = $1 ORDER BY id`,
+		todoID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch attachments"))
+		return
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		var attachment models.Attachment
+		var key string
+		if err := scanAttachment(rows, &attachment, &key); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan attachment"))
+			return
+		}
+		if err := h.sign(&attachment, key); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to sign download URL"))
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch attachments"))
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// DeleteAttachment deletes an attachment. Its blob is removed in the
+// background by blob.Purge.
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	_, todoID, ok := h.attachmentParams(c)
+	if !ok {
+		return
+	}
+	id, ok := attachmentID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.DB.ExecContext(ctx,
+		"DELETE FROM attachments WHERE id = $1 AND todo_id = $2",
+		id, todoID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete attachment"))
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		apperror.Respond(c, errFileNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// DownloadAttachment serves an attachment to the holder of a download token
+// from its URL. It needs no session, so that links work in plain <a> and
+// <img> elements.
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := attachmentID(c)
+	if !ok {
+		return
+	}
+	if err := middleware.VerifyDownloadToken(c.Query("token"), id); err != nil {
+		apperror.Respond(c, errDownloadLinkInvalid)
+		return
+	}
+
+	var attachment models.Attachment
+	var key string
+	err := scanAttachment(h.DB.QueryRowContext(ctx,
+		`SELECT `+attachmentColumns+` FROM attachments WHERE id = $1`,
+		id,
+	), &attachment, &key)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errFileNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch attachment"))
+		return
+	}
+
+	body, err := h.Store.Open(ctx, key)
+	if errors.Is(err, blob.ErrNotFound) {
+		apperror.Respond(c, errFileNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to read attachment"))
+		return
+	}
+	defer body.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, body, map[string]string{
+		"Content-Disposition":    blob.ContentDisposition(attachment.Filename),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, no-store",
+	})
+}
+
+// GetStorageUsage reports how much of their attachment quota the user has
+// used.
+func (h *AttachmentHandler) GetStorageUsage(c *gin.Context) {
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	used, err := storageUsed(c.Request.Context(), h.DB, userCtx.UserID)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch storage usage"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.StorageUsage{UsedBytes: used, QuotaBytes: h.QuotaBytes})
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todo-app/backend/internal/blob"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memStore is a blob.Store in memory.
+type memStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	types map[string]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{blobs: map[string][]byte{}, types: map[string]string{}}
+}
+
+func (s *memStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = data
+	s.types[key] = contentType
+	return nil
+}
+
+func (s *memStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
+
+// pngData is enough of a PNG for http.DetectContentType.
+var pngData = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+// newAttachmentRouter serves user 1's todo 5 from an attachment handler
+// that allows PNGs of up to 100 bytes within a 250-byte quota.
+func newAttachmentRouter(t *testing.T) (*gin.Engine, *sqltest.DB, *memStore) {
+	t.Helper()
+
+	middleware.SetJWTSecret("test-secret")
+	fake, db := sqltest.New(t)
+	fake.On("SELECT EXISTS(SELECT 1 FROM todos", func(args []driver.Value) sqltest.Result {
+		return sqltest.Row([]string{"exists"}, args[0] == int64(5) && args[1] == int64(1))
+	})
+	fake.On("SELECT COALESCE(SUM(size), 0) FROM attachments", func([]driver.Value) sqltest.Result {
+		return sqltest.Row([]string{"sum"}, int64(0))
+	})
+	fake.On("FROM users WHERE id = $1 FOR UPDATE", func([]driver.Value) sqltest.Result {
+		return sqltest.Result{RowsAffected: 1}
+	})
+	fake.On("INSERT INTO attachments", func([]driver.Value) sqltest.Result {
+		return sqltest.Row([]string{"id", "created_at"}, int64(9), testTime)
+	})
+
+	store := newMemStore()
+	h := NewAttachmentHandler(db, store, 100, 250, []string{"image/png"}, time.Hour, "https://todo.example.com/")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/todos/:id/attachments", withUser(1, h.UploadAttachment))
+	r.GET("/api/attachments/:attachmentId/download", h.DownloadAttachment)
+	return r, fake, store
+}
+
+func upload(r http.Handler, path, filename, contentType string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := make(map[string][]string)
+	header["Content-Disposition"] = []string{`form-data; name="file"; filename="` + filename + `"`}
+	header["Content-Type"] = []string{contentType}
+	part, _ := form.CreatePart(header)
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadAttachment(t *testing.T) {
+	r, _, store := newAttachmentRouter(t)
+
+	w := upload(r, "/api/todos/5/attachments", `..\..\screen shot.png`, "application/octet-stream", pngData)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var attachment models.Attachment
+	if err := json.Unmarshal(w.Body.Bytes(), &attachment); err != nil {
+		t.Fatal(err)
+	}
+	if attachment.ID != 9 || attachment.Filename != "screen shot.png" || attachment.ContentType != "image/png" || attachment.Size != int64(len(pngData)) {
+		t.Errorf("attachment = %+v", attachment)
+	}
+	if !strings.HasPrefix(attachment.URL, "https://todo.example.com/api/attachments/9/download?token=") {
+		t.Errorf("url = %s", attachment.URL)
+	}
+	if len(store.blobs) != 1 {
+		t.Fatalf("%d blobs stored, want 1", len(store.blobs))
+	}
+	for key, data := range store.blobs {
+		if !strings.HasPrefix(key, "1/") || !bytes.Equal(data, pngData) || store.types[key] != "image/png" {
+			t.Errorf("blob %s = %d bytes of %s", key, len(data), store.types[key])
+		}
+	}
+
+	if w := upload(r, "/api/todos/6/attachments", "a.png", "image/png", pngData); w.Code != http.StatusNotFound {
+		t.Errorf("another user's todo: status = %d, want 404", w.Code)
+	}
+}
+
+func TestUploadAttachmentRejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		status      int
+		code        string
+	}{
+		// The type is sniffed, so the client's Content-Type does not count.
+		{"HTML claiming to be PNG", "image/png", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType, "unsupported_attachment_type"},
+		{"executable", "image/png", append([]byte("MZ\x90\x00"), make([]byte, 60)...), http.StatusUnsupportedMediaType, "unsupported_attachment_type"},
+		{"over the size limit", "image/png", append(pngData, make([]byte, 100)...), http.StatusRequestEntityTooLarge, "body_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fake, store := newAttachmentRouter(t)
+
+			w := upload(r, "/api/todos/5/attachments", "a.png", tt.contentType, tt.data)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), `"code":"`+tt.code+`"`) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.code)
+			}
+			if len(store.blobs) != 0 {
+				t.Error("rejected upload was stored")
+			}
+			for _, q := range fake.Calls() {
+				if strings.Contains(q, "INSERT") {
+					t.Errorf("rejected upload was recorded: %s", q)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadAttachmentQuota(t *testing.T) {
+	tests := []struct {
+		name string
+		// used is what the quota queries return, first before storing the
+		// blob and then under the user-row lock.
+		used     []int64
+		stored   bool
+		inserted bool
+	}{
+		{"fits", []int64{100, 100}, true, true},
+		{"full before the upload", []int64{200, 200}, false, false},
+		// Another upload finished between the checks.
+		{"filled while uploading", []int64{100, 200}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fake, store := newAttachmentRouter(t)
+			var calls []string
+			fake.On("SELECT COALESCE(SUM(size), 0) FROM attachments", func([]driver.Value) sqltest.Result {
+				used := tt.used[0]
+				tt.used = tt.used[1:]
+				calls = append(calls, "sum")
+				return sqltest.Row([]string{"sum"}, used)
+			})
+			fake.On("FROM users WHERE id = $1 FOR UPDATE", func([]driver.Value) sqltest.Result {
+				calls = append(calls, "lock")
+				return sqltest.Result{RowsAffected: 1}
+			})
+			inserted := false
+			fake.On("INSERT INTO attachments", func([]driver.Value) sqltest.Result {
+				inserted = true
+				return sqltest.Row([]string{"id", "created_at"}, int64(9), testTime)
+			})
+
+			w := upload(r, "/api/todos/5/attachments", "a.png", "image/png", pngData)
+			want := http.StatusCreated
+			if !tt.inserted {
+				want = http.StatusConflict
+			}
+			if w.Code != want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, want, w.Body.String())
+			}
+			if inserted != tt.inserted {
+				t.Errorf("inserted = %v, want %v", inserted, tt.inserted)
+			}
+			// A blob that was stored but could not be kept is deleted.
+			if kept := len(store.blobs) == 1; kept != tt.inserted {
+				t.Errorf("blob kept = %v, want %v", kept, tt.inserted)
+			}
+			if tt.stored && strings.Join(calls, " ") != "sum lock sum" {
+				t.Errorf("queries = %v, want the quota checked again under the lock", calls)
+			}
+		})
+	}
+}
+
+func TestUploadAttachmentInsertFailureDeletesBlob(t *testing.T) {
+	r, fake, store := newAttachmentRouter(t)
+	put := 0
+	fake.On("INSERT INTO attachments", func([]driver.Value) sqltest.Result {
+		put = len(store.blobs)
+		return sqltest.Result{Err: errors.New("connection reset")}
+	})
+
+	w := upload(r, "/api/todos/5/attachments", "a.png", "image/png", pngData)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500: %s", w.Code, w.Body.String())
+	}
+	if put != 1 {
+		t.Fatalf("%d blobs stored before the insert, want 1", put)
+	}
+	if len(store.blobs) != 0 {
+		t.Error("blob left behind after the insert failed")
+	}
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	r, fake, store := newAttachmentRouter(t)
+	store.blobs["1/abc"] = pngData
+	fake.On("FROM attachments WHERE id = $1", func(args []driver.Value) sqltest.Result {
+		columns := []string{"id", "todo_id", "filename", "content_type", "size", "created_at", "storage_key"}
+		key := "1/abc"
+		if args[0] == int64(8) {
+			key = "1/gone"
+		}
+		return sqltest.Row(columns, args[0], int64(5), `report "final".png`, "image/png", int64(len(pngData)), testTime, key)
+	})
+
+	token := func(id int, expiresAt time.Time) string {
+		token, err := middleware.GenerateDownloadToken(id, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	challenge, err := middleware.GenerateChallengeToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := middleware.GenerateToken(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		id     string
+		token  string
+		status int
+	}{
+		{"valid", "9", token(9, later), http.StatusOK},
+		{"expired", "9", token(9, time.Now().Add(-time.Minute)), http.StatusForbidden},
+		{"for another attachment", "9", token(10, later), http.StatusForbidden},
+		{"challenge token", "9", challenge, http.StatusForbidden},
+		{"session token", "9", session, http.StatusForbidden},
+		{"no token", "9", "", http.StatusForbidden},
+		{"blob missing", "8", token(8, later), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveJSON(r, http.MethodGet, "/api/attachments/"+tt.id+"/download?token="+url.QueryEscape(tt.token), "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if !bytes.Equal(w.Body.Bytes(), pngData) {
+				t.Error("body is not the blob")
+			}
+			headers := map[string]string{
+				"Content-Type":           "image/png",
+				"Content-Disposition":    `attachment; filename="report \"final\".png"`,
+				"X-Content-Type-Options": "nosniff",
+				"Cache-Control":          "private, no-store",
+			}
+			for name, want := range headers {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
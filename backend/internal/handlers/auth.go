@@ -1,48 +1,66 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"net/http"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/logging"
+	"todo-app/backend/internal/metrics"
 	"todo-app/backend/internal/middleware"
 	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/ratelimit"
+	"todo-app/backend/internal/validation"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Policy  *middleware.PasswordPolicy
+	Limiter *ratelimit.Limiter
 }
 
-func NewAuthHandler(db *sql.DB) *AuthHandler {
-	return &AuthHandler{DB: db}
+func NewAuthHandler(db *sql.DB, policy *middleware.PasswordPolicy, limiter *ratelimit.Limiter) *AuthHandler {
+	return &AuthHandler{DB: db, Policy: policy, Limiter: limiter}
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req models.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email and password are required"})
+	if err := h.Policy.Validate(req.Password, req.Email); err != nil {
+		apperror.Respond(c, passwordPolicyError("password", err))
 		return
 	}
 
 	hashedPassword, err := middleware.HashPassword(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to hash password"))
 		return
 	}
 
 	var userID int
-	err = h.DB.QueryRow(
+	err = h.DB.QueryRowContext(ctx,
 		"INSERT INTO users (email, password) VALUES ($1, $2) RETURNING id",
 		req.Email, hashedPassword,
 	).Scan(&userID)
 
+	if apperror.IsUniqueViolation(err) {
+		apperror.Respond(c, errEmailTaken)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create user"))
 		return
 	}
 
@@ -53,38 +71,78 @@ func (h *AuthHandler) Register(c *gin.Context) {
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	accountKey := ratelimit.AccountKey(req.Email)
+	wait, err := h.Limiter.CheckAccount(ctx, accountKey)
+	if err != nil {
+		logging.FromContext(c).Error("Rate limit store error", "error", err)
+	}
+	if wait > 0 {
+		middleware.AbortTooManyRequests(c, wait)
 		return
 	}
 
 	var user models.User
-	err := h.DB.QueryRow(
-		"SELECT id, email, password, is_admin FROM users WHERE email = $1",
+	err = h.DB.QueryRowContext(ctx,
+		"SELECT id, email, password, is_admin, must_change_password, totp_enabled, suspended_at FROM users WHERE email = $1",
 		req.Email,
-	).Scan(&user.ID, &user.Email, &user.Password, &user.IsAdmin)
+	).Scan(&user.ID, &user.Email, &user.Password, &user.IsAdmin, &user.MustChangePassword, &user.TwoFactorEnabled, &user.SuspendedAt)
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	if err != nil && err != sql.ErrNoRows {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
 		return
 	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+
+	// Unknown emails count as failures too, so lockouts don't reveal which
+	// accounts exist.
+	if err == sql.ErrNoRows || !middleware.CheckPassword(req.Password, user.Password) {
+		if _, err := h.Limiter.Failure(ctx, accountKey); err != nil {
+			logging.FromContext(c).Error("Rate limit store error", "error", err)
+		}
+		metrics.Login("password", metrics.LoginFailed)
+		apperror.Respond(c, errInvalidCredentials)
+		return
+	}
+
+	if err := h.Limiter.Success(ctx, accountKey); err != nil {
+		logging.FromContext(c).Error("Rate limit store error", "error", err)
+	}
+
+	// Checked only after the password, so suspension does not reveal that
+	// an account exists.
+	if user.SuspendedAt != nil {
+		metrics.Login("password", metrics.LoginFailed)
+		apperror.Respond(c, errAccountSuspended)
 		return
 	}
 
-	if !middleware.CheckPassword(req.Password, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	if user.TwoFactorEnabled {
+		challenge, err := middleware.GenerateChallengeToken(user.ID)
+		if err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+			return
+		}
+
+		c.JSON(http.StatusOK, models.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		})
 		return
 	}
 
 	token, err := middleware.GenerateToken(user.ID, user.IsAdmin)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
 		return
 	}
 
+	metrics.Login("password", metrics.LoginSucceeded)
 	user.Password = ""
 	response := models.LoginResponse{
 		Token: token,
@@ -95,22 +153,312 @@ func (h *AuthHandler) Login(c *gin.Context) {
 }
 
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
+	ctx := c.Request.Context()
 	userCtx, ok := middleware.GetUserFromGinContext(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apperror.Respond(c, apperror.ErrUnauthorized)
 		return
 	}
 
 	var user models.User
-	err := h.DB.QueryRow(
-		"SELECT id, email, is_admin, created_at, updated_at FROM users WHERE id = $1",
+	err := h.DB.QueryRowContext(ctx,
+		`SELECT id, email, is_admin, must_change_password, totp_enabled, created_at, updated_at
+		 FROM users WHERE id = $1`,
 		userCtx.UserID,
-	).Scan(&user.ID, &user.Email, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(
+		&user.ID, &user.Email, &user.IsAdmin, &user.MustChangePassword,
+		&user.TwoFactorEnabled, &user.CreatedAt, &user.UpdatedAt,
+	)
 
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apperror.Respond(c, errUserNotFound)
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
+
+func (h *AuthHandler) GetSettings(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var settings models.UserSettings
+	err := h.DB.QueryRowContext(ctx, "SELECT timezone FROM users WHERE id = $1", userCtx.UserID).Scan(&settings.Timezone)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch settings"))
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings replaces the user's settings. The time zone decides where
+// days begin in GET /api/me/stats.
+func (h *AuthHandler) UpdateSettings(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.UserSettings
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	var settings models.UserSettings
+	err := h.DB.QueryRowContext(ctx,
+		`UPDATE users SET timezone = $1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2
+		 RETURNING timezone`,
+		req.Timezone, userCtx.UserID,
+	).Scan(&settings.Timezone)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update settings"))
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	var user models.User
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT id, email, password, is_admin FROM users WHERE id = $1",
+		userCtx.UserID,
+	).Scan(&user.ID, &user.Email, &user.Password, &user.IsAdmin)
+
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	// Wrong current passwords count toward the same lockout as failed logins,
+	// so a stolen session cannot be used to guess the password.
+	accountKey := ratelimit.AccountKey(user.Email)
+	wait, err := h.Limiter.CheckAccount(ctx, accountKey)
+	if err != nil {
+		logging.FromContext(c).Error("Rate limit store error", "error", err)
+	}
+	if wait > 0 {
+		middleware.AbortTooManyRequests(c, wait)
+		return
+	}
+
+	if !middleware.CheckPassword(req.CurrentPassword, user.Password) {
+		if _, err := h.Limiter.Failure(ctx, accountKey); err != nil {
+			logging.FromContext(c).Error("Rate limit store error", "error", err)
+		}
+		apperror.Respond(c, errIncorrectPassword.WithMessage("Current password is incorrect"))
+		return
+	}
+
+	if err := h.Limiter.Success(ctx, accountKey); err != nil {
+		logging.FromContext(c).Error("Rate limit store error", "error", err)
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		apperror.Respond(c, apperror.Validation(
+			apperror.Field("new_password", "password_unchanged", "New password must differ from the current password"),
+		))
+		return
+	}
+
+	if err := h.Policy.Validate(req.NewPassword, user.Email); err != nil {
+		apperror.Respond(c, passwordPolicyError("new_password", err))
+		return
+	}
+
+	hashedPassword, err := middleware.HashPassword(req.NewPassword)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to hash password"))
+		return
+	}
+
+	_, err = h.DB.ExecContext(ctx,
+		`UPDATE users
+		 SET password = $1, password_changed_at = CURRENT_TIMESTAMP,
+		     must_change_password = FALSE, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2`,
+		hashedPassword, user.ID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update password"))
+		return
+	}
+
+	// Tokens issued before the change are now rejected, including the one
+	// used for this request, so hand back a fresh one.
+	var opts []middleware.TokenOption
+	if userCtx.MFA {
+		opts = append(opts, middleware.WithMFA())
+	}
+	token, err := middleware.GenerateToken(user.ID, user.IsAdmin, opts...)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully",
+		"token":   token,
+	})
+}
+
+// AcceptInvitation sets the first password of an account created by an admin
+// and signs the user in. Each invitation can be used once.
+func (h *AuthHandler) AcceptInvitation(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.AcceptInvitationRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx,
+		`UPDATE user_invitations SET used_at = CURRENT_TIMESTAMP
+		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		 RETURNING user_id`,
+		hashInvitationToken(req.Token),
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errInvitationInvalid)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	var user models.User
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, email, is_admin, totp_enabled, suspended_at, created_at
+		 FROM users WHERE id = $1 FOR UPDATE`,
+		userID,
+	).Scan(&user.ID, &user.Email, &user.IsAdmin, &user.TwoFactorEnabled, &user.SuspendedAt, &user.CreatedAt)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	if user.SuspendedAt != nil {
+		apperror.Respond(c, errAccountSuspended)
+		return
+	}
+
+	if err := h.Policy.Validate(req.Password, user.Email); err != nil {
+		apperror.Respond(c, passwordPolicyError("password", err))
+		return
+	}
+
+	hashedPassword, err := middleware.HashPassword(req.Password)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to hash password"))
+		return
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE users
+		 SET password = $1, password_changed_at = CURRENT_TIMESTAMP,
+		     must_change_password = FALSE, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2
+		 RETURNING updated_at`,
+		hashedPassword, user.ID,
+	).Scan(&user.UpdatedAt)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update password"))
+		return
+	}
+
+	// Any other invitation for the account is now pointless.
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM user_invitations WHERE user_id = $1 AND used_at IS NULL",
+		user.ID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.IsAdmin)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.LoginResponse{
+		Token: token,
+		User:  user,
+	})
+}
+
+const invitationTokenBytes = 32
+
+// createInvitation stores an invitation for userID and returns its token.
+// Only a hash of the token is kept, like recovery codes.
+func createInvitation(ctx context.Context, tx *sql.Tx, userID, createdBy int, ttl time.Duration) (string, time.Time, error) {
+	buf := make([]byte, invitationTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	var expiresAt time.Time
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO user_invitations (token_hash, user_id, created_by, expires_at)
+		 VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second')
+		 RETURNING expires_at`,
+		hashInvitationToken(token), userID, createdBy, int(ttl.Seconds()),
+	).Scan(&expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
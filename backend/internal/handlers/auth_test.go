@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"todo-app/backend/internal/metrics"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/ratelimit"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withUser runs the request as userID without going through the auth
+// middleware.
+func withUser(userID int, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user", middleware.UserContext{UserID: userID})
+		handler(c)
+	}
+}
+
+func serveJSON(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// counter sums the samples of the named metric whose labels include the
+// given name-value pairs.
+func counter(t *testing.T, name string, labels ...string) float64 {
+	t.Helper()
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	samples:
+		for _, metric := range family.GetMetric() {
+			for i := 0; i+1 < len(labels); i += 2 {
+				found := false
+				for _, label := range metric.GetLabel() {
+					found = found || label.GetName() == labels[i] && label.GetValue() == labels[i+1]
+				}
+				if !found {
+					continue samples
+				}
+			}
+			sum += metric.GetCounter().GetValue()
+		}
+	}
+	return sum
+}
+
+func TestChangePasswordCountsTowardLockout(t *testing.T) {
+	fake, db := sqltest.New(t)
+	hash, err := middleware.HashPassword("kx7qpmzv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.On("SELECT id, email, password, is_admin FROM users", func(args []driver.Value) sqltest.Result {
+		return sqltest.Row([]string{"id", "email", "password", "is_admin"}, int64(1), "alice@example.com", hash, false)
+	})
+
+	store := ratelimit.NewMemoryStore()
+	h := &AuthHandler{
+		DB:     db,
+		Policy: middleware.DefaultPasswordPolicy(),
+		Limiter: &ratelimit.Limiter{
+			Store:   store,
+			Account: ratelimit.Rate{PerMinute: 60, Burst: 100},
+			Lockout: ratelimit.LockoutPolicy{Threshold: 3, Base: time.Minute, Max: time.Hour},
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/api/me/password", withUser(1, h.ChangePassword))
+
+	wrong := `{"current_password": "guess", "new_password": "Tr0ub4dor&3"}`
+	for i := 1; i <= 3; i++ {
+		if w := serveJSON(r, http.MethodPut, "/api/me/password", wrong); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status = %d, want 401", i, w.Code)
+		}
+	}
+
+	// The right password is refused too while the account is locked.
+	right := `{"current_password": "kx7qpmzv", "new_password": "Tr0ub4dor&3"}`
+	w := serveJSON(r, http.MethodPut, "/api/me/password", right)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After not set")
+	}
+
+	// Logins for the same account are locked as well.
+	if wait, _ := h.Limiter.CheckAccount(context.Background(), ratelimit.AccountKey("Alice@example.com")); wait <= 0 {
+		t.Error("login not locked after failed password changes")
+	}
+}
@@ -0,0 +1,586 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/metrics"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/todostatus"
+	"todo-app/backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type BoardHandler struct {
+	DB *sql.DB
+}
+
+func NewBoardHandler(db *sql.DB) *BoardHandler {
+	return &BoardHandler{DB: db}
+}
+
+// defaultColumns are the columns of a new board, in order.
+var defaultColumns = []struct{ name, status string }{
+	{"To do", todostatus.Todo},
+	{"In progress", todostatus.InProgress},
+	{"Blocked", todostatus.Blocked},
+	{"Done", todostatus.Done},
+}
+
+// boardViewQuery reads a board, its columns and their cards in one query.
+// The card fields are NULL for an empty column, and the column fields are
+// NULL for a board without columns.
+const boardViewQuery = `
+	SELECT t.id, t.user_id, t.title, t.description, t.status, t.completed, t.due_date,
+	       t.position, t.completed_at, t.created_at, t.updated_at, t.comment_count,
+	       b.id, b.name, b.created_at, b.updated_at,
+	       c.id, c.name, c.status, c.wip_limit, c.position
+	FROM boards b
+	LEFT JOIN board_columns c ON c.board_id = b.id
+	LEFT JOIN LATERAL (
+		SELECT ` + todoColumns + ` FROM todos
+		WHERE user_id = b.user_id AND status = c.status
+	) t ON true
+	WHERE b.id = $1 AND b.user_id = $2
+	ORDER BY c.position, c.id, t.position NULLS FIRST, t.id DESC`
+
+// loadBoard returns the board view, or errBoardNotFound.
+func loadBoard(ctx context.Context, db *sql.DB, boardID, userID int) (*models.Board, error) {
+	rows, err := db.QueryContext(ctx, boardViewQuery, boardID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var board *models.Board
+	for rows.Next() {
+		var b models.Board
+		var columnID, position sql.NullInt64
+		var name, status sql.NullString
+		var wipLimit *int
+		// The card fields are all NULL for an empty column.
+		var cardID, cardUserID, commentCount sql.NullInt64
+		var title, description, cardStatus, cardPosition sql.NullString
+		var completed sql.NullBool
+		var dueDate, completedAt, createdAt, updatedAt sql.NullTime
+		err := rows.Scan(
+			&cardID, &cardUserID, &title, &description, &cardStatus, &completed, &dueDate,
+			&cardPosition, &completedAt, &createdAt, &updatedAt, &commentCount,
+			&b.ID, &b.Name, &b.CreatedAt, &b.UpdatedAt, &columnID, &name, &status, &wipLimit, &position,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if board == nil {
+			b.Columns = []models.BoardColumn{}
+			board = &b
+		}
+		if !columnID.Valid {
+			continue
+		}
+		if n := len(board.Columns); n == 0 || board.Columns[n-1].ID != int(columnID.Int64) {
+			board.Columns = append(board.Columns, models.BoardColumn{
+				ID:       int(columnID.Int64),
+				Name:     name.String,
+				Status:   status.String,
+				WIPLimit: wipLimit,
+				Position: int(position.Int64),
+				Cards:    []models.Todo{},
+			})
+		}
+		if cardID.Valid {
+			card := models.Todo{
+				ID:           int(cardID.Int64),
+				UserID:       int(cardUserID.Int64),
+				Title:        title.String,
+				Description:  description.String,
+				Status:       cardStatus.String,
+				Completed:    completed.Bool,
+				DueDate:      formatDueDate(dueDate),
+				CommentCount: int(commentCount.Int64),
+				CreatedAt:    createdAt.Time,
+				UpdatedAt:    updatedAt.Time,
+			}
+			if cardPosition.Valid {
+				card.Position = &cardPosition.String
+			}
+			if completedAt.Valid {
+				card.CompletedAt = &completedAt.Time
+			}
+			column := &board.Columns[len(board.Columns)-1]
+			column.Cards = append(column.Cards, card)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if board == nil {
+		return nil, errBoardNotFound
+	}
+	return board, nil
+}
+
+// respondBoard sends the current view of the board.
+func (h *BoardHandler) respondBoard(c *gin.Context, status, boardID, userID int) {
+	board, err := loadBoard(c.Request.Context(), h.DB, boardID, userID)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch board"))
+		return
+	}
+	c.JSON(status, board)
+}
+
+func boardParams(c *gin.Context) (userID, boardID int, ok bool) {
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return 0, 0, false
+	}
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidBoardID)
+		return 0, 0, false
+	}
+	return userCtx.UserID, boardID, true
+}
+
+func (h *BoardHandler) ListBoards(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT id, name, created_at, updated_at
+		 FROM boards WHERE user_id = $1 ORDER BY id`,
+		userCtx.UserID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch boards"))
+		return
+	}
+	defer rows.Close()
+
+	boards := []models.BoardSummary{}
+	for rows.Next() {
+		var b models.BoardSummary
+		if err := rows.Scan(&b.ID, &b.Name, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan board"))
+			return
+		}
+		boards = append(boards, b)
+	}
+	if err := rows.Err(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch boards"))
+		return
+	}
+
+	c.JSON(http.StatusOK, boards)
+}
+
+// CreateBoard creates a board with defaultColumns.
+func (h *BoardHandler) CreateBoard(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.BoardRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	names := make([]string, len(defaultColumns))
+	statuses := make([]string, len(defaultColumns))
+	for i, col := range defaultColumns {
+		names[i], statuses[i] = col.name, col.status
+	}
+
+	var boardID int
+	err := h.DB.QueryRowContext(ctx,
+		`WITH board AS (
+		   INSERT INTO boards (user_id, name) VALUES ($1, $2) RETURNING id
+		 ), columns AS (
+		   INSERT INTO board_columns (board_id, name, status, position)
+		   SELECT board.id, col.name, col.status, col.position
+		   FROM board, unnest($3::text[], $4::text[]) WITH ORDINALITY AS col(name, status, position)
+		 )
+		 SELECT id FROM board`,
+		userCtx.UserID, req.Name, pq.Array(names), pq.Array(statuses),
+	).Scan(&boardID)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create board"))
+		return
+	}
+
+	h.respondBoard(c, http.StatusCreated, boardID, userCtx.UserID)
+}
+
+// GetBoard returns the board with every column and its cards.
+func (h *BoardHandler) GetBoard(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+	h.respondBoard(c, http.StatusOK, boardID, userID)
+}
+
+func (h *BoardHandler) UpdateBoard(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.BoardRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	result, err := h.DB.ExecContext(ctx,
+		`UPDATE boards SET name = $1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2 AND user_id = $3`,
+		req.Name, boardID, userID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update board"))
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		apperror.Respond(c, errBoardNotFound)
+		return
+	}
+
+	h.respondBoard(c, http.StatusOK, boardID, userID)
+}
+
+// DeleteBoard deletes the board and its columns. The todos are kept.
+func (h *BoardHandler) DeleteBoard(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.DB.ExecContext(ctx,
+		"DELETE FROM boards WHERE id = $1 AND user_id = $2",
+		boardID, userID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete board"))
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		apperror.Respond(c, errBoardNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Board deleted successfully"})
+}
+
+func bindColumn(c *gin.Context) (models.BoardColumnRequest, bool) {
+	var req models.BoardColumnRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return req, false
+	}
+	if req.WIPLimit != nil && *req.WIPLimit < 1 {
+		apperror.Respond(c, apperror.Validation(apperror.Field("wip_limit", "too_small", "Must be at least 1")))
+		return req, false
+	}
+	return req, true
+}
+
+// CreateColumn adds a column to the board, last unless a position is given.
+func (h *BoardHandler) CreateColumn(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+	req, ok := bindColumn(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.DB.ExecContext(ctx,
+		`INSERT INTO board_columns (board_id, name, status, wip_limit, position)
+		 SELECT b.id, $3, $4, $5,
+		        COALESCE($6, (SELECT COALESCE(MAX(position), 0) + 1 FROM board_columns WHERE board_id = b.id))
+		 FROM boards b WHERE b.id = $1 AND b.user_id = $2`,
+		boardID, userID, req.Name, req.Status, req.WIPLimit, req.Position,
+	)
+	if apperror.IsUniqueViolation(err) {
+		apperror.Respond(c, errColumnStatusTaken)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create column"))
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		apperror.Respond(c, errBoardNotFound)
+		return
+	}
+
+	h.respondBoard(c, http.StatusCreated, boardID, userID)
+}
+
+// UpdateColumn replaces the column's name, status and WIP limit, and moves
+// it when a position is given. Lowering the WIP limit below the number of
+// cards is allowed; it only stops further cards from moving in.
+func (h *BoardHandler) UpdateColumn(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+	columnID, err := strconv.Atoi(c.Param("columnId"))
+	if err != nil {
+		apperror.Respond(c, errInvalidColumnID)
+		return
+	}
+	req, ok := bindColumn(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.DB.ExecContext(ctx,
+		`UPDATE board_columns c
+		 SET name = $1, status = $2, wip_limit = $3, position = COALESCE($4, c.position)
+		 FROM boards b
+		 WHERE c.id = $5 AND c.board_id = $6 AND b.id = c.board_id AND b.user_id = $7`,
+		req.Name, req.Status, req.WIPLimit, req.Position, columnID, boardID, userID,
+	)
+	if apperror.IsUniqueViolation(err) {
+		apperror.Respond(c, errColumnStatusTaken)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update column"))
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		apperror.Respond(c, errColumnNotFound)
+		return
+	}
+
+	h.respondBoard(c, http.StatusOK, boardID, userID)
+}
+
+// DeleteColumn removes the column from the board. Its cards are not
+// changed; they just no longer appear on this board.
+func (h *BoardHandler) DeleteColumn(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+	columnID, err := strconv.Atoi(c.Param("columnId"))
+	if err != nil {
+		apperror.Respond(c, errInvalidColumnID)
+		return
+	}
+
+	result, err := h.DB.ExecContext(ctx,
+		`DELETE FROM board_columns c USING boards b
+		 WHERE c.id = $1 AND c.board_id = $2 AND b.id = c.board_id AND b.user_id = $3`,
+		columnID, boardID, userID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete column"))
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		apperror.Respond(c, errColumnNotFound)
+		return
+	}
+
+	h.respondBoard(c, http.StatusOK, boardID, userID)
+}
+
+// MoveCard moves a todo into a column and places it among the column's
+// cards in one transaction. The status change must be an allowed
+// transition and is subject to checkWIPLimit. Reordering within a column is
+// never limited.
+func (h *BoardHandler) MoveCard(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+	todoID, err := strconv.Atoi(c.Param("todoId"))
+	if err != nil {
+		apperror.Respond(c, errInvalidTodoID)
+		return
+	}
+
+	var req models.MoveCardRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+	if req.ColumnID == 0 {
+		apperror.Respond(c, apperror.Validation(apperror.Field("column_id", "required", "This field is required")))
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+		return
+	}
+	defer tx.Rollback()
+
+	// The order lock also serialises moves into the same column, so the WIP
+	// count below cannot be overtaken by another move.
+	if err := lockTodoOrder(ctx, tx, userID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+		return
+	}
+
+	var status string
+	err = tx.QueryRowContext(ctx,
+		`SELECT c.status
+		 FROM board_columns c JOIN boards b ON b.id = c.board_id
+		 WHERE c.id = $1 AND c.board_id = $2 AND b.user_id = $3`,
+		req.ColumnID, boardID, userID,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errColumnNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+		return
+	}
+
+	current, err := lockTodoStatus(ctx, tx, todoID, userID)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+		return
+	}
+	if err := checkTransition(current, status); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	if current != status {
+		if err := checkWIPLimit(ctx, tx, userID, status); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+			return
+		}
+
+		_, err := tx.ExecContext(ctx,
+			"UPDATE todos SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+			status, todoID,
+		)
+		if err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+			return
+		}
+	}
+
+	afterID, beforeID := req.AfterID, req.BeforeID
+	if afterID == nil && beforeID == nil {
+		// Without anchors the card goes last, which is after the column's
+		// last card in the manual order.
+		var last int
+		err := tx.QueryRowContext(ctx,
+			`SELECT id FROM todos WHERE user_id = $1 AND status = $2 AND id <> $3
+			 ORDER BY position DESC NULLS LAST, id LIMIT 1`,
+			userID, status, todoID,
+		).Scan(&last)
+		if err != nil && err != sql.ErrNoRows {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+			return
+		}
+		if err == nil {
+			afterID = &last
+		}
+	} else if err := checkAnchorsInColumn(ctx, tx, userID, status, afterID, beforeID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+		return
+	}
+	if afterID != nil || beforeID != nil {
+		if err := placeTodo(ctx, tx, userID, todoID, afterID, beforeID); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move card"))
+		return
+	}
+	if status == todostatus.Done && current != todostatus.Done {
+		metrics.TodosCompleted.Inc()
+	}
+
+	h.respondBoard(c, http.StatusOK, boardID, userID)
+}
+
+// checkWIPLimit rejects moving a todo into status while a column with that
+// status, on any of the user's boards, holds as many cards as its WIP limit.
+// A card shows on every board with a column for its status, so every such
+// limit applies. tx must hold lockTodoOrder, which serialises status
+// changes, so that the count cannot be overtaken.
+func checkWIPLimit(ctx context.Context, tx *sql.Tx, userID int, status string) error {
+	var name string
+	var limit, cards int
+	err := tx.QueryRowContext(ctx,
+		`SELECT c.name, c.wip_limit,
+		        (SELECT COUNT(*) FROM todos WHERE user_id = $1 AND status = $2)
+		 FROM board_columns c JOIN boards b ON b.id = c.board_id
+		 WHERE b.user_id = $1 AND c.status = $2 AND c.wip_limit IS NOT NULL
+		 ORDER BY c.wip_limit, c.id LIMIT 1`,
+		userID, status,
+	).Scan(&name, &limit, &cards)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cards >= limit {
+		return errWIPLimitReached.WithMessage(fmt.Sprintf("Column %q has reached its WIP limit of %d", name, limit))
+	}
+	return nil
+}
+
+// checkAnchorsInColumn rejects anchors that are not cards of the column the
+// card moves to.
+func checkAnchorsInColumn(ctx context.Context, tx *sql.Tx, userID int, status string, anchorIDs ...*int) error {
+	var ids []int64
+	for _, id := range anchorIDs {
+		if id != nil {
+			ids = append(ids, int64(*id))
+		}
+	}
+
+	var n int
+	err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM todos WHERE user_id = $1 AND status = $2 AND id = ANY($3)",
+		userID, status, pq.Array(ids),
+	).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n != len(ids) {
+		return errInvalidMove.WithMessage("Anchors must be cards in the target column")
+	}
+	return nil
+}
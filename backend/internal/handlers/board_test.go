@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoadBoard(t *testing.T) {
+	fake, db := sqltest.New(t)
+	board := []driver.Value{int64(7), "Sprint", testTime, testTime}
+	column := func(id int64, status string, wipLimit driver.Value, position int64) []driver.Value {
+		return append(append([]driver.Value{}, board...), id, "Column", status, wipLimit, position)
+	}
+	nullCard := make([]driver.Value, len(todoColumnNames))
+	row := func(card, rest []driver.Value) []driver.Value {
+		return append(append([]driver.Value{}, card...), rest...)
+	}
+	fake.On("FROM boards b", func(args []driver.Value) sqltest.Result {
+		return sqltest.Result{
+			Columns: append(append([]string{}, todoColumnNames...), "id", "name", "created_at", "updated_at", "id", "name", "status", "wip_limit", "position"),
+			Rows: [][]driver.Value{
+				row(todoRow(11, "todo", testTime), column(1, "todo", int64(3), 1)),
+				row(todoRow(12, "todo", nil), column(1, "todo", int64(3), 1)),
+				row(nullCard, column(2, "in_progress", nil, 2)),
+			},
+		}
+	})
+
+	got, err := loadBoard(context.Background(), db, 7, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 7 || len(got.Columns) != 2 {
+		t.Fatalf("board = %+v, want board 7 with 2 columns", got)
+	}
+	todo, empty := got.Columns[0], got.Columns[1]
+	var ids []int
+	for _, card := range todo.Cards {
+		ids = append(ids, card.ID)
+	}
+	if !reflect.DeepEqual(ids, []int{11, 12}) || todo.WIPLimit == nil || *todo.WIPLimit != 3 {
+		t.Errorf("first column = %+v, want cards 11 and 12 and a WIP limit of 3", todo)
+	}
+	if due := todo.Cards[0].DueDate; due == nil || *due != "2026-01-02" {
+		t.Errorf("due date = %v, want 2026-01-02", due)
+	}
+	if todo.Cards[1].DueDate != nil {
+		t.Errorf("due date = %v, want nil", *todo.Cards[1].DueDate)
+	}
+	if empty.Cards == nil || len(empty.Cards) != 0 || empty.WIPLimit != nil {
+		t.Errorf("empty column = %+v, want no cards and no WIP limit", empty)
+	}
+}
+
+func TestStatusChangesRespectWIPLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		full   bool
+		status int
+	}{
+		{"update into a full column", http.MethodPut, "/api/todos/5", `{"title": "Todo", "status": "in_progress"}`, true, http.StatusConflict},
+		{"update into a column with room", http.MethodPut, "/api/todos/5", `{"title": "Todo", "status": "in_progress"}`, false, http.StatusOK},
+		{"update keeping the status", http.MethodPut, "/api/todos/5", `{"title": "Renamed", "status": "todo"}`, true, http.StatusOK},
+		// Creating a todo is not a status change.
+		{"create in a full column", http.MethodPost, "/api/todos", `{"title": "Todo", "status": "in_progress"}`, true, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := sqltest.New(t)
+			fake.On("FROM users WHERE id = $1 FOR UPDATE", func(args []driver.Value) sqltest.Result {
+				return sqltest.Row([]string{"id"}, int64(1))
+			})
+			fake.On("SELECT status FROM todos", func(args []driver.Value) sqltest.Result {
+				return sqltest.Row([]string{"status"}, "todo")
+			})
+			fake.On("c.wip_limit IS NOT NULL", func(args []driver.Value) sqltest.Result {
+				cards := int64(1)
+				if tt.full {
+					cards = 2
+				}
+				return sqltest.Row([]string{"name", "wip_limit", "count"}, "Doing", int64(2), cards)
+			})
+			fake.On("RETURNING", func(args []driver.Value) sqltest.Result {
+				return sqltest.Row(todoColumnNames, todoRow(5, "in_progress", nil)...)
+			})
+
+			h := NewTodoHandler(db)
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.POST("/api/todos", withUser(1, h.CreateTodo))
+			r.PUT("/api/todos/:id", withUser(1, h.UpdateTodo))
+
+			created := counter(t, "todoapp_todos_created_total")
+			w := serveJSON(r, tt.method, tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			wantCreated := 0.0
+			if tt.status == http.StatusCreated {
+				wantCreated = 1
+			}
+			if got := counter(t, "todoapp_todos_created_total") - created; got != wantCreated {
+				t.Errorf("todos_created_total rose by %v, want %v", got, wantCreated)
+			}
+			if tt.status == http.StatusConflict && !strings.Contains(w.Body.String(), "wip_limit_reached") {
+				t.Errorf("body = %s, want wip_limit_reached", w.Body.String())
+			}
+			checked := false
+			for _, q := range fake.Calls() {
+				if strings.Contains(q, "RETURNING") && tt.status == http.StatusConflict {
+					t.Errorf("todo written despite the WIP limit: %s", q)
+				}
+				checked = checked || strings.Contains(q, "c.wip_limit IS NOT NULL")
+			}
+			if tt.method == http.MethodPost && checked {
+				t.Error("WIP limit checked on create")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCollaborators lists the users taking part in a todo's comments
+// besides its owner, in the order they were added.
+func (h *CommentHandler) ListCollaborators(c *gin.Context) {
+	_, todoID, _, ok := h.commentParams(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.DB.QueryContext(c.Request.Context(),
+		`SELECT u.id, u.email, tc.created_at
+		 FROM todo_collaborators tc JOIN users u ON u.id = tc.user_id
+		 WHERE tc.todo_id = $1
+		 ORDER BY tc.created_at, u.id`,
+		todoID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch collaborators"))
+		return
+	}
+	defer rows.Close()
+
+	collaborators := []models.Collaborator{}
+	for rows.Next() {
+		var collaborator models.Collaborator
+		if err := rows.Scan(&collaborator.UserID, &collaborator.Email, &collaborator.AddedAt); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan collaborator"))
+			return
+		}
+		collaborators = append(collaborators, collaborator)
+	}
+	if err := rows.Err(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch collaborators"))
+		return
+	}
+
+	c.JSON(http.StatusOK, collaborators)
+}
+
+// AddCollaborator lets the user with the given email see and comment on
+// the todo. Only the owner may add collaborators.
+func (h *CommentHandler) AddCollaborator(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, todoID, ownerID, ok := h.commentParams(c)
+	if !ok {
+		return
+	}
+	if userID != ownerID {
+		apperror.Respond(c, errNotTodoOwner)
+		return
+	}
+
+	var req models.CollaboratorRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	var collaborator models.Collaborator
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT id, email FROM users WHERE LOWER(email) = LOWER($1)",
+		req.Email,
+	).Scan(&collaborator.UserID, &collaborator.Email)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to add collaborator"))
+		return
+	}
+	if collaborator.UserID == ownerID {
+		apperror.Respond(c, errCollaboratorIsOwner)
+		return
+	}
+
+	err = h.DB.QueryRowContext(ctx,
+		"INSERT INTO todo_collaborators (todo_id, user_id) VALUES ($1, $2) RETURNING created_at",
+		todoID, collaborator.UserID,
+	).Scan(&collaborator.AddedAt)
+	if apperror.IsUniqueViolation(err) {
+		apperror.Respond(c, errAlreadyCollaborator)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to add collaborator"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, collaborator)
+}
+
+// RemoveCollaborator takes a collaborator off the todo. The owner may
+// remove anyone, and collaborators may remove themselves. Their comments
+// stay, but mentions of them are no longer shown.
+func (h *CommentHandler) RemoveCollaborator(c *gin.Context) {
+	userID, todoID, ownerID, ok := h.commentParams(c)
+	if !ok {
+		return
+	}
+	collaboratorID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		apperror.Respond(c, errInvalidUserID)
+		return
+	}
+	if userID != ownerID && userID != collaboratorID {
+		apperror.Respond(c, errNotTodoOwner)
+		return
+	}
+
+	result, err := h.DB.ExecContext(c.Request.Context(),
+		"DELETE FROM todo_collaborators WHERE todo_id = $1 AND user_id = $2",
+		todoID, collaboratorID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to remove collaborator"))
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		apperror.Respond(c, errNotCollaborator)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator removed successfully"})
+}
@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/markdown"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type CommentHandler struct {
+	DB *sql.DB
+}
+
+func NewCommentHandler(db *sql.DB) *CommentHandler {
+	return &CommentHandler{DB: db}
+}
+
+// commentColumns are the columns scanComment expects, selected from
+// commentTables.
+const (
+	commentColumns = `c.id, c.todo_id, c.author_id, u.email, c.body, c.created_at, c.edited_at`
+	commentTables  = `todo_comments c LEFT JOIN users u ON u.id = c.author_id`
+)
+
+func scanComment(row interface{ Scan(...any) error }, comment *models.Comment, extra ...any) error {
+	return row.Scan(append([]any{
+		&comment.ID, &comment.TodoID, &comment.AuthorID, &comment.AuthorEmail,
+		&comment.Body, &comment.CreatedAt, &comment.EditedAt,
+	}, extra...)...)
+}
+
+// canSeeTodo is a condition on todos t that holds when user, an SQL
+// expression, is the todo's owner or one of its collaborators.
+func canSeeTodo(user string) string {
+	return `(t.user_id = ` + user + ` OR EXISTS (
+		SELECT 1 FROM todo_collaborators tc WHERE tc.todo_id = t.id AND tc.user_id = ` + user + `))`
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// renderComments fills in the mentions and HTML body of each comment. Only
+// the users recorded as mentioned who can still see the todo are
+// highlighted, so an address that matched no one when the comment was
+// saved, or a collaborator since removed, stays plain text.
+func renderComments(ctx context.Context, q queryer, comments []models.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(comments))
+	for i, comment := range comments {
+		ids[i] = int64(comment.ID)
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT m.comment_id, u.id, LOWER(u.email)
+		 FROM todo_comment_mentions m
+		 JOIN users u ON u.id = m.user_id
+		 JOIN todo_comments c ON c.id = m.comment_id
+		 JOIN todos t ON t.id = c.todo_id
+		 WHERE m.comment_id = ANY($1) AND `+canSeeTodo("u.id")+`
+		 ORDER BY m.comment_id, u.email`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	mentions := make(map[int][]models.Mention)
+	for rows.Next() {
+		var commentID int
+		var m models.Mention
+		if err := rows.Scan(&commentID, &m.UserID, &m.Email); err != nil {
+			return err
+		}
+		mentions[commentID] = append(mentions[commentID], m)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comment := &comments[i]
+		comment.Mentions = mentions[comment.ID]
+		if comment.Mentions == nil {
+			comment.Mentions = []models.Mention{}
+		}
+		known := make(map[string]bool, len(comment.Mentions))
+		for _, m := range comment.Mentions {
+			known[m.Email] = true
+		}
+		comment.BodyHTML = markdown.Render(comment.Body, func(email string) bool { return known[email] })
+	}
+	return nil
+}
+
+// saveMentions records the users mentioned in body, replacing any earlier
+// mentions of the comment. Only the todo's owner and collaborators are
+// resolved; other addresses are ignored, so that a mention neither grants
+// access nor reveals whether an address is registered.
+func saveMentions(ctx context.Context, tx *sql.Tx, todoID, commentID int, body string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM todo_comment_mentions WHERE comment_id = $1", commentID); err != nil {
+		return err
+	}
+	emails := markdown.Mentions(body)
+	if len(emails) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO todo_comment_mentions (comment_id, user_id)
+		 SELECT $1, u.id FROM todos t JOIN users u ON `+canSeeTodo("u.id")+`
+		 WHERE t.id = $2 AND LOWER(u.email) = ANY($3)
+		 ON CONFLICT DO NOTHING`,
+		commentID, todoID, pq.Array(emails),
+	)
+	return err
+}
+
+// loadComment returns a rendered comment on todoID, or errCommentNotFound.
+func loadComment(ctx context.Context, tx *sql.Tx, todoID, commentID int) (models.Comment, error) {
+	var comment models.Comment
+	err := scanComment(tx.QueryRowContext(ctx,
+		`SELECT `+commentColumns+` FROM `+commentTables+`
+		 WHERE c.id = $1 AND c.todo_id = $2`,
+		commentID, todoID,
+	), &comment)
+	if err == sql.ErrNoRows {
+		return comment, errCommentNotFound
+	}
+	if err != nil {
+		return comment, err
+	}
+	comments := []models.Comment{comment}
+	if err := renderComments(ctx, tx, comments); err != nil {
+		return comment, err
+	}
+	return comments[0], nil
+}
+
+// commentParams reads the todo ID and checks that the user may take part in
+// its discussion, as its owner or a collaborator. Other users get
+// errTodoNotFound, as for the todo itself.
+func (h *CommentHandler) commentParams(c *gin.Context) (userID, todoID, ownerID int, ok bool) {
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return 0, 0, 0, false
+	}
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidTodoID)
+		return 0, 0, 0, false
+	}
+
+	err = h.DB.QueryRowContext(c.Request.Context(),
+		`SELECT t.user_id FROM todos t WHERE t.id = $1 AND `+canSeeTodo("$2"),
+		todoID, userCtx.UserID,
+	).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errTodoNotFound)
+		return 0, 0, 0, false
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch todo"))
+		return 0, 0, 0, false
+	}
+	return userCtx.UserID, todoID, ownerID, true
+}
+
+func commentID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("commentId"))
+	if err != nil {
+		apperror.Respond(c, errInvalidCommentID)
+		return 0, false
+	}
+	return id, true
+}
+
+// ListComments lists the comments on a todo, oldest first.
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	ctx := c.Request.Context()
+	_, todoID, _, ok := h.commentParams(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT `+commentColumns+` FROM `+commentTables+`
+		 WHERE c.todo_id = $1 ORDER BY c.id`,
+		todoID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch comments"))
+		return
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		if err := scanComment(rows, &comment); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan comment"))
+			return
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch comments"))
+		return
+	}
+	if err := renderComments(ctx, h.DB, comments); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch comments"))
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, todoID, _, ok := h.commentParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.CommentRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create comment"))
+		return
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO todo_comments (todo_id, author_id, body) VALUES ($1, $2, $3) RETURNING id",
+		todoID, userID, req.Body,
+	).Scan(&id)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create comment"))
+		return
+	}
+	if err := saveMentions(ctx, tx, todoID, id, req.Body); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create comment"))
+		return
+	}
+	comment, err := loadComment(ctx, tx, todoID, id)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create comment"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create comment"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateComment replaces the body of the user's own comment. The previous
+// body is kept as a revision, and the mentions are resolved again.
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, todoID, _, ok := h.commentParams(c)
+	if !ok {
+		return
+	}
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CommentRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update comment"))
+		return
+	}
+	defer tx.Rollback()
+
+	var authorID sql.NullInt64
+	var body string
+	err = tx.QueryRowContext(ctx,
+		"SELECT author_id, body FROM todo_comments WHERE id = $1 AND todo_id = $2 FOR UPDATE",
+		id, todoID,
+	).Scan(&authorID, &body)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errCommentNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update comment"))
+		return
+	}
+	if !authorID.Valid || int(authorID.Int64) != userID {
+		apperror.Respond(c, errNotCommentAuthor)
+		return
+	}
+
+	// Saving the same text again is not an edit.
+	if req.Body != body {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO todo_comment_revisions (comment_id, body) VALUES ($1, $2)",
+			id, body,
+		)
+		if err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to update comment"))
+			return
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE todo_comments SET body = $1, edited_at = CURRENT_TIMESTAMP WHERE id = $2",
+			req.Body, id,
+		)
+		if err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to update comment"))
+			return
+		}
+		if err := saveMentions(ctx, tx, todoID, id, req.Body); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to update comment"))
+			return
+		}
+	}
+
+	comment, err := loadComment(ctx, tx, todoID, id)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update comment"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update comment"))
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment deletes a comment with its revisions. The author and the
+// todo's owner may delete it.
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, todoID, ownerID, ok := h.commentParams(c)
+	if !ok {
+		return
+	}
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.DB.ExecContext(ctx,
+		"DELETE FROM todo_comments WHERE id = $1 AND todo_id = $2 AND (author_id = $3 OR $3 = $4)",
+		id, todoID, userID, ownerID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete comment"))
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		// Tell a missing comment apart from someone else's.
+		var exists bool
+		err := h.DB.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM todo_comments WHERE id = $1 AND todo_id = $2)",
+			id, todoID,
+		).Scan(&exists)
+		if err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to delete comment"))
+			return
+		}
+		if exists {
+			apperror.Respond(c, errNotCommentAuthor.WithMessage("Only the author or the todo's owner can delete this comment"))
+			return
+		}
+		apperror.Respond(c, errCommentNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// ListRevisions lists the earlier bodies of a comment, oldest first.
+func (h *CommentHandler) ListRevisions(c *gin.Context) {
+	ctx := c.Request.Context()
+	_, todoID, _, ok := h.commentParams(c)
+	if !ok {
+		return
+	}
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	var exists bool
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM todo_comments WHERE id = $1 AND todo_id = $2)",
+		id, todoID,
+	).Scan(&exists)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch revisions"))
+		return
+	}
+	if !exists {
+		apperror.Respond(c, errCommentNotFound)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT id, body, created_at FROM todo_comment_revisions
+		 WHERE comment_id = $1 ORDER BY id`,
+		id,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch revisions"))
+		return
+	}
+	defer rows.Close()
+
+	revisions := []models.CommentRevision{}
+	for rows.Next() {
+		var r models.CommentRevision
+		if err := rows.Scan(&r.ID, &r.Body, &r.ReplacedAt); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan revision"))
+			return
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch revisions"))
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+const (
+	defaultMentionLimit = 50
+	maxMentionLimit     = 200
+)
+
+// ListMentions lists the comments that mention the user, newest first.
+// The user's own comments are left out, as are comments on todos the user
+// can no longer see.
+func (h *CommentHandler) ListMentions(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	limit := defaultMentionLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxMentionLimit {
+			apperror.Respond(c, errInvalidLimit.WithMessage("Limit must be between 1 and "+strconv.Itoa(maxMentionLimit)))
+			return
+		}
+		limit = n
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT `+commentColumns+`, t.title
+		 FROM todo_comment_mentions m
+		 JOIN `+commentTables+` ON c.id = m.comment_id
+		 JOIN todos t ON t.id = c.todo_id
+		 WHERE m.user_id = $1 AND `+canSeeTodo("$1")+` AND c.author_id IS DISTINCT FROM $1
+		 ORDER BY c.id DESC
+		 LIMIT $2`,
+		userCtx.UserID, limit,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch mentions"))
+		return
+	}
+	defer rows.Close()
+
+	var comments []models.Comment
+	var titles []string
+	for rows.Next() {
+		var comment models.Comment
+		var title string
+		if err := scanComment(rows, &comment, &title); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan mention"))
+			return
+		}
+		comments = append(comments, comment)
+		titles = append(titles, title)
+	}
+	if err := rows.Err(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch mentions"))
+		return
+	}
+	if err := renderComments(ctx, h.DB, comments); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch mentions"))
+		return
+	}
+
+	notices := make([]models.MentionNotice, len(comments))
+	for i, comment := range comments {
+		notices[i] = models.MentionNotice{Comment: comment, TodoTitle: titles[i]}
+	}
+	c.JSON(http.StatusOK, notices)
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// commentDB answers the comment queries from a todo 5 owned by user 1,
+// keeping collaborators, comments and mentions in memory. The access rule
+// the SQL applies, owner or collaborator, is applied here the same way.
+type commentDB struct {
+	emails        map[int64]string
+	collaborators map[int64]bool
+	comments      map[int64][]driver.Value
+	mentions      map[int64][]int64
+}
+
+func newCommentRouter(t *testing.T) (*gin.Engine, *commentDB) {
+	t.Helper()
+
+	state := &commentDB{
+		emails:        map[int64]string{1: "alice@example.com", 2: "bob@example.com", 3: "carol@example.com"},
+		collaborators: map[int64]bool{},
+		comments:      map[int64][]driver.Value{},
+		mentions:      map[int64][]int64{},
+	}
+	canSee := func(userID int64) bool { return userID == 1 || state.collaborators[userID] }
+	commentCols := []string{"id", "todo_id", "author_id", "email", "body", "created_at", "edited_at"}
+
+	fake, db := sqltest.New(t)
+	fake.On("SELECT t.user_id FROM todos t", func(args []driver.Value) sqltest.Result {
+		if args[0] != int64(5) || !canSee(args[1].(int64)) {
+			return sqltest.Result{Columns: []string{"user_id"}}
+		}
+		return sqltest.Row([]string{"user_id"}, int64(1))
+	})
+	fake.On("SELECT id, email FROM users", func(args []driver.Value) sqltest.Result {
+		for id, email := range state.emails {
+			if strings.EqualFold(email, args[0].(string)) {
+				return sqltest.Row([]string{"id", "email"}, id, email)
+			}
+		}
+		return sqltest.Result{Columns: []string{"id", "email"}}
+	})
+	fake.On("INSERT INTO todo_collaborators", func(args []driver.Value) sqltest.Result {
+		userID := args[1].(int64)
+		if state.collaborators[userID] {
+			return sqltest.Result{Err: &pq.Error{Code: "23505"}}
+		}
+		state.collaborators[userID] = true
+		return sqltest.Row([]string{"created_at"}, testTime)
+	})
+	fake.On("DELETE FROM todo_collaborators", func(args []driver.Value) sqltest.Result {
+		userID := args[1].(int64)
+		if !state.collaborators[userID] {
+			return sqltest.Result{}
+		}
+		delete(state.collaborators, userID)
+		return sqltest.Result{RowsAffected: 1}
+	})
+	fake.On("INSERT INTO todo_comments", func(args []driver.Value) sqltest.Result {
+		id := int64(len(state.comments) + 1)
+		state.comments[id] = []driver.Value{id, args[0], args[1], state.emails[args[1].(int64)], args[2], testTime, nil}
+		return sqltest.Row([]string{"id"}, id)
+	})
+	fake.On("DELETE FROM todo_comment_mentions", func(args []driver.Value) sqltest.Result {
+		delete(state.mentions, args[0].(int64))
+		return sqltest.Result{}
+	})
+	fake.On("INSERT INTO todo_comment_mentions", func(args []driver.Value) sqltest.Result {
+		var emails pq.StringArray
+		if err := emails.Scan(args[2]); err != nil {
+			t.Fatal(err)
+		}
+		commentID := args[0].(int64)
+		for _, email := range emails {
+			for id, e := range state.emails {
+				if e == email && canSee(id) {
+					state.mentions[commentID] = append(state.mentions[commentID], id)
+				}
+			}
+		}
+		return sqltest.Result{}
+	})
+	fake.On("WHERE c.id = $1 AND c.todo_id = $2", func(args []driver.Value) sqltest.Result {
+		return sqltest.Row(commentCols, state.comments[args[0].(int64)]...)
+	})
+	fake.On("SELECT m.comment_id, u.id", func(args []driver.Value) sqltest.Result {
+		var ids pq.Int64Array
+		if err := ids.Scan(args[0]); err != nil {
+			t.Fatal(err)
+		}
+		result := sqltest.Result{Columns: []string{"comment_id", "id", "email"}}
+		for _, commentID := range ids {
+			for _, userID := range state.mentions[commentID] {
+				if canSee(userID) {
+					result.Rows = append(result.Rows, []driver.Value{commentID, userID, state.emails[userID]})
+				}
+			}
+		}
+		return result
+	})
+	fake.On("c.author_id IS DISTINCT FROM $1", func(args []driver.Value) sqltest.Result {
+		userID := args[0].(int64)
+		result := sqltest.Result{Columns: append(commentCols, "title")}
+		if !canSee(userID) {
+			return result
+		}
+		for id := int64(len(state.comments)); id > 0; id-- {
+			comment := state.comments[id]
+			if comment[2] == userID {
+				continue
+			}
+			for _, mentioned := range state.mentions[id] {
+				if mentioned == userID {
+					result.Rows = append(result.Rows, append(append([]driver.Value{}, comment...), "Ship it"))
+				}
+			}
+		}
+		return result
+	})
+
+	h := NewCommentHandler(db)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	for _, userID := range []int{1, 2, 3} {
+		prefix := "/as/" + strconv.Itoa(userID)
+		r.GET(prefix+"/me/mentions", withUser(userID, h.ListMentions))
+		r.POST(prefix+"/todos/:id/comments", withUser(userID, h.CreateComment))
+		r.POST(prefix+"/todos/:id/collaborators", withUser(userID, h.AddCollaborator))
+		r.DELETE(prefix+"/todos/:id/collaborators/:userId", withUser(userID, h.RemoveCollaborator))
+	}
+	return r, state
+}
+
+func mentionFeed(t *testing.T, r *gin.Engine, userID int) []models.MentionNotice {
+	t.Helper()
+
+	w := serveJSON(r, http.MethodGet, "/as/"+strconv.Itoa(userID)+"/me/mentions", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("mentions: status = %d: %s", w.Code, w.Body.String())
+	}
+	var notices []models.MentionNotice
+	if err := json.Unmarshal(w.Body.Bytes(), &notices); err != nil {
+		t.Fatal(err)
+	}
+	return notices
+}
+
+func TestMentionReachesCollaboratorFeed(t *testing.T) {
+	r, _ := newCommentRouter(t)
+
+	if w := serveJSON(r, http.MethodPost, "/as/1/todos/5/collaborators", `{"email": "Bob@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("add collaborator: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	w := serveJSON(r, http.MethodPost, "/as/1/todos/5/comments", `{"body": "@bob@example.com and @carol@example.com, please review"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("comment: status = %d: %s", w.Code, w.Body.String())
+	}
+	var comment models.Comment
+	if err := json.Unmarshal(w.Body.Bytes(), &comment); err != nil {
+		t.Fatal(err)
+	}
+	// carol cannot see the todo, so her address stays plain text.
+	if len(comment.Mentions) != 1 || comment.Mentions[0].UserID != 2 {
+		t.Errorf("mentions = %+v, want only bob", comment.Mentions)
+	}
+
+	notices := mentionFeed(t, r, 2)
+	if len(notices) != 1 || notices[0].Comment.ID != comment.ID || notices[0].TodoTitle != "Ship it" {
+		t.Fatalf("bob's feed = %+v, want the comment", notices)
+	}
+	if len(mentionFeed(t, r, 3)) != 0 {
+		t.Error("carol's feed has a comment on a todo she cannot see")
+	}
+	if len(mentionFeed(t, r, 1)) != 0 {
+		t.Error("alice's feed has her own comment")
+	}
+
+	// A collaborator can reply and mention the owner.
+	if w := serveJSON(r, http.MethodPost, "/as/2/todos/5/comments", `{"body": "done, @alice@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("reply: status = %d: %s", w.Code, w.Body.String())
+	}
+	if notices := mentionFeed(t, r, 1); len(notices) != 1 || notices[0].Comment.Body != "done, @alice@example.com" {
+		t.Errorf("alice's feed = %+v, want bob's reply", notices)
+	}
+
+	// Once removed, bob no longer sees the todo or the mention.
+	if w := serveJSON(r, http.MethodDelete, "/as/2/todos/5/collaborators/2", ""); w.Code != http.StatusOK {
+		t.Fatalf("leave: status = %d: %s", w.Code, w.Body.String())
+	}
+	if len(mentionFeed(t, r, 2)) != 0 {
+		t.Error("bob's feed still has the mention after he left")
+	}
+	if w := serveJSON(r, http.MethodPost, "/as/2/todos/5/comments", `{"body": "still here?"}`); w.Code != http.StatusNotFound {
+		t.Errorf("comment after leaving: status = %d, want 404", w.Code)
+	}
+}
+
+func TestCollaboratorChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"owner adds", http.MethodPost, "/as/1/todos/5/collaborators", `{"email": "carol@example.com"}`, http.StatusCreated, ""},
+		{"added twice", http.MethodPost, "/as/1/todos/5/collaborators", `{"email": "bob@example.com"}`, http.StatusConflict, "already_collaborator"},
+		{"unknown email", http.MethodPost, "/as/1/todos/5/collaborators", `{"email": "dave@example.com"}`, http.StatusNotFound, "user_not_found"},
+		{"owner's own email", http.MethodPost, "/as/1/todos/5/collaborators", `{"email": "alice@example.com"}`, http.StatusConflict, "collaborator_is_owner"},
+		{"collaborator adds", http.MethodPost, "/as/2/todos/5/collaborators", `{"email": "carol@example.com"}`, http.StatusForbidden, "not_todo_owner"},
+		{"stranger adds", http.MethodPost, "/as/3/todos/5/collaborators", `{"email": "carol@example.com"}`, http.StatusNotFound, "todo_not_found"},
+		{"owner removes", http.MethodDelete, "/as/1/todos/5/collaborators/2", "", http.StatusOK, ""},
+		{"owner removes a non-collaborator", http.MethodDelete, "/as/1/todos/5/collaborators/3", "", http.StatusNotFound, "collaborator_not_found"},
+		{"collaborator removes someone else", http.MethodDelete, "/as/2/todos/5/collaborators/1", "", http.StatusForbidden, "not_todo_owner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, state := newCommentRouter(t)
+			state.collaborators[2] = true
+
+			w := serveJSON(r, tt.method, tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.code != "" && !strings.Contains(w.Body.String(), `"code":"`+tt.code+`"`) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.code)
+			}
+		})
+	}
+}
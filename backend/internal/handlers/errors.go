@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/middleware"
+)
+
+// Errors returned by handlers. Codes are part of the API contract; change
+// messages freely but never a code.
+var (
+	errInvalidUserID    = apperror.New(http.StatusBadRequest, "invalid_user_id", "Invalid user ID")
+	errInvalidTodoID    = apperror.New(http.StatusBadRequest, "invalid_todo_id", "Invalid todo ID")
+	errInvalidPasskeyID = apperror.New(http.StatusBadRequest, "invalid_passkey_id", "Invalid passkey ID")
+	errInvalidLockout   = apperror.New(http.StatusBadRequest, "invalid_lockout_key", "Invalid lockout key")
+	errInvalidLimit     = apperror.New(http.StatusBadRequest, "invalid_limit", "Invalid limit")
+	errInvalidDateRange = apperror.New(http.StatusBadRequest, "invalid_date_range", "Invalid date range")
+	errInvalidBucket    = apperror.New(http.StatusBadRequest, "invalid_bucket", "Bucket must be day, week or month")
+	errInvalidSort      = apperror.New(http.StatusBadRequest, "invalid_sort", "Sort must be created_at or position")
+	errInvalidMove      = apperror.New(http.StatusBadRequest, "invalid_move", "after_id or before_id is required")
+	errInvalidBoardID   = apperror.New(http.StatusBadRequest, "invalid_board_id", "Invalid board ID")
+	errInvalidColumnID  = apperror.New(http.StatusBadRequest, "invalid_column_id", "Invalid column ID")
+	errInvalidCommentID = apperror.New(http.StatusBadRequest, "invalid_comment_id", "Invalid comment ID")
+	errInvalidFileID    = apperror.New(http.StatusBadRequest, "invalid_attachment_id", "Invalid attachment ID")
+	errInvalidNoticeID  = apperror.New(http.StatusBadRequest, "invalid_notification_id", "Invalid notification ID")
+
+	errUserNotFound    = apperror.New(http.StatusNotFound, "user_not_found", "User not found")
+	errTodoNotFound    = apperror.New(http.StatusNotFound, "todo_not_found", "Todo not found")
+	errPasskeyNotFound = apperror.New(http.StatusNotFound, "passkey_not_found", "Passkey not found")
+	errBoardNotFound   = apperror.New(http.StatusNotFound, "board_not_found", "Board not found")
+	errColumnNotFound  = apperror.New(http.StatusNotFound, "column_not_found", "Column not found")
+	errCommentNotFound = apperror.New(http.StatusNotFound, "comment_not_found", "Comment not found")
+	errFileNotFound    = apperror.New(http.StatusNotFound, "attachment_not_found", "Attachment not found")
+	errNoticeNotFound  = apperror.New(http.StatusNotFound, "notification_not_found", "Notification not found")
+	errNotCollaborator = apperror.New(http.StatusNotFound, "collaborator_not_found", "Collaborator not found")
+
+	errEmailTaken            = apperror.New(http.StatusConflict, "email_taken", "Email already exists")
+	errInvalidCredentials    = apperror.New(http.StatusUnauthorized, "invalid_credentials", "Invalid email or password")
+	errIncorrectPassword     = apperror.New(http.StatusUnauthorized, "incorrect_password", "Password is incorrect")
+	errInvalidChallenge      = apperror.New(http.StatusUnauthorized, "invalid_challenge", "Invalid or expired challenge")
+	errInvalidCode           = apperror.New(http.StatusUnauthorized, "invalid_verification_code", "Invalid verification code")
+	errInvalidRecoveryCode   = apperror.New(http.StatusUnauthorized, "invalid_recovery_code", "Invalid recovery code")
+	errTwoFactorNotEnabled   = apperror.New(http.StatusBadRequest, "two_factor_not_enabled", "Two-factor authentication is not enabled")
+	errTwoFactorEnabled      = apperror.New(http.StatusConflict, "two_factor_already_enabled", "Two-factor authentication is already enabled")
+	errTwoFactorNotStarted   = apperror.New(http.StatusBadRequest, "two_factor_setup_not_started", "Two-factor setup has not been started")
+	errNoPendingTwoFactor    = apperror.New(http.StatusNotFound, "two_factor_setup_not_found", "No pending two-factor setup")
+	errPasskeySessionInvalid = apperror.New(http.StatusBadRequest, "passkey_session_invalid", "Invalid or expired passkey session")
+	errPasskeyInvalid        = apperror.New(http.StatusBadRequest, "passkey_credential_invalid", "Invalid passkey credential")
+	errPasskeyAttestation    = apperror.New(http.StatusBadRequest, "passkey_attestation_failed", "Passkey verification failed")
+	errPasskeyExists         = apperror.New(http.StatusConflict, "passkey_already_registered", "Passkey is already registered")
+	errNoPasskeys            = apperror.New(http.StatusBadRequest, "no_passkeys", "No passkeys registered for this account")
+	errPasskeyVerification   = apperror.New(http.StatusUnauthorized, "passkey_verification_failed", "Passkey verification failed")
+	errPasskeyCloned         = apperror.New(http.StatusUnauthorized, "passkey_sign_count_invalid", "Passkey sign count check failed")
+	errSSOStateInvalid       = apperror.New(http.StatusBadRequest, "sso_state_invalid", "Invalid or expired single sign-on state")
+	errSSOFailed             = apperror.New(http.StatusUnauthorized, "sso_failed", "Single sign-on failed")
+	errSSOEmailNotVerified   = apperror.New(http.StatusForbidden, "sso_email_not_verified", "Email address is not verified by the identity provider")
+	errSSOIdentityConflict   = apperror.New(http.StatusConflict, "sso_identity_conflict", "This email is already linked to a different single sign-on identity")
+	errAccountSuspended      = apperror.New(http.StatusForbidden, "account_suspended", "This account has been suspended")
+	errInvitationInvalid     = apperror.New(http.StatusBadRequest, "invitation_invalid", "Invalid or expired invitation")
+	errCannotTargetSelf      = apperror.New(http.StatusConflict, "cannot_target_self", "Admins cannot do this to their own account")
+	errCannotImpersonate     = apperror.New(http.StatusConflict, "cannot_impersonate_admin", "Admin accounts cannot be impersonated")
+	errLastAdmin             = apperror.New(http.StatusConflict, "last_admin", "At least one active admin must remain")
+	errColumnStatusTaken     = apperror.New(http.StatusConflict, "column_status_taken", "Another column on this board already shows this status")
+	errWIPLimitReached       = apperror.New(http.StatusConflict, "wip_limit_reached", "The column has reached its WIP limit")
+	errNotCommentAuthor      = apperror.New(http.StatusForbidden, "not_comment_author", "Only the author can edit this comment")
+	errNotTodoOwner          = apperror.New(http.StatusForbidden, "not_todo_owner", "Only the todo's owner can do this")
+	errCollaboratorIsOwner   = apperror.New(http.StatusConflict, "collaborator_is_owner", "The todo's owner cannot be added as a collaborator")
+	errAlreadyCollaborator   = apperror.New(http.StatusConflict, "already_collaborator", "This user is already a collaborator")
+	errAttachmentType        = apperror.New(http.StatusUnsupportedMediaType, "unsupported_attachment_type", "This file type is not allowed")
+	errAttachmentQuota       = apperror.New(http.StatusConflict, "attachment_quota_exceeded", "The upload would exceed your attachment storage quota")
+	errDownloadLinkInvalid   = apperror.New(http.StatusForbidden, "download_link_invalid", "Invalid or expired download link")
+	errStatusTransition      = apperror.New(http.StatusConflict, "invalid_status_transition", "This status change is not allowed")
+	errConfirmationRequired  = apperror.New(http.StatusPreconditionRequired, "confirmation_required", "This action must be confirmed with a token from POST /api/admin/confirmations")
+)
+
+// passwordPolicyError reports a password policy failure against field.
+func passwordPolicyError(field string, err error) *apperror.Error {
+	var code, message string
+	switch {
+	case errors.Is(err, middleware.ErrPasswordBreached):
+		code, message = "password_breached", "This password has appeared in a data breach; please choose another"
+	case errors.Is(err, middleware.ErrPasswordTooWeak):
+		code, message = "password_too_weak", "Password is too weak; avoid common words, sequences and your email address"
+	case errors.Is(err, middleware.ErrPasswordTooShort):
+		code, message = "password_too_short", "Invalid password: "+err.Error()
+	case errors.Is(err, middleware.ErrPasswordTooLong):
+		code, message = "password_too_long", "Invalid password: "+err.Error()
+	default:
+		code, message = "password_invalid", "Invalid password: "+err.Error()
+	}
+	return apperror.Validation(apperror.Field(field, code, message))
+}
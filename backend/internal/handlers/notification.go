@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/notify"
+	"todo-app/backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	DB *sql.DB
+}
+
+func NewNotificationHandler(db *sql.DB) *NotificationHandler {
+	return &NotificationHandler{DB: db}
+}
+
+const notificationColumns = `id, kind, todo_id, message, read_at, created_at`
+
+func scanNotification(row interface{ Scan(...any) error }, n *models.Notification) error {
+	return row.Scan(&n.ID, &n.Kind, &n.TodoID, &n.Message, &n.ReadAt, &n.CreatedAt)
+}
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+)
+
+// ListNotifications lists the user's notifications, newest first, with the
+// number still unread. unread=true leaves out the ones already read.
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	limit := defaultNotificationLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			apperror.Respond(c, errInvalidLimit.WithMessage("Limit must be between 1 and "+strconv.Itoa(maxNotificationLimit)))
+			return
+		}
+		limit = n
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT `+notificationColumns+` FROM notifications
+		 WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		 ORDER BY id DESC
+		 LIMIT $3`,
+		userCtx.UserID, unreadOnly, limit,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch notifications"))
+		return
+	}
+	defer rows.Close()
+
+	list := models.NotificationList{Notifications: []models.Notification{}}
+	for rows.Next() {
+		var n models.Notification
+		if err := scanNotification(rows, &n); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan notification"))
+			return
+		}
+		list.Notifications = append(list.Notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch notifications"))
+		return
+	}
+
+	err = h.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL",
+		userCtx.UserID,
+	).Scan(&list.UnreadCount)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to count notifications"))
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// MarkNotificationRead marks one of the user's notifications as read. A
+// notification already read keeps its read_at.
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apperror.Respond(c, errInvalidNoticeID)
+		return
+	}
+
+	var n models.Notification
+	err = scanNotification(h.DB.QueryRowContext(ctx,
+		`UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING `+notificationColumns,
+		id, userCtx.UserID,
+	), &n)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errNoticeNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update notification"))
+		return
+	}
+
+	c.JSON(http.StatusOK, n)
+}
+
+func (h *NotificationHandler) MarkAllNotificationsRead(c *gin.Context) {
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	_, err := h.DB.ExecContext(c.Request.Context(),
+		"UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL",
+		userCtx.UserID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update notifications"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
+}
+
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	prefs := models.NotificationPreferences{DueSoon: true, DueSoonDays: notify.DefaultDueSoonDays, AdminActions: true}
+	err := h.DB.QueryRowContext(c.Request.Context(),
+		"SELECT due_soon, due_soon_days, admin_actions FROM notification_preferences WHERE user_id = $1",
+		userCtx.UserID,
+	).Scan(&prefs.DueSoon, &prefs.DueSoonDays, &prefs.AdminActions)
+	if err != nil && err != sql.ErrNoRows {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch notification preferences"))
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences replaces the user's notification preferences. Turning a
+// kind off stops new notifications of it; existing ones are kept.
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.NotificationPreferences
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+	if req.DueSoonDays < 0 || req.DueSoonDays > 7 {
+		apperror.Respond(c, apperror.Validation(apperror.Field("due_soon_days", "out_of_range", "Must be between 0 and 7")))
+		return
+	}
+
+	_, err := h.DB.ExecContext(c.Request.Context(),
+		`INSERT INTO notification_preferences (user_id, due_soon, due_soon_days, admin_actions)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (user_id) DO UPDATE
+		 SET due_soon = EXCLUDED.due_soon, due_soon_days = EXCLUDED.due_soon_days, admin_actions = EXCLUDED.admin_actions`,
+		userCtx.UserID, req.DueSoon, req.DueSoonDays, req.AdminActions,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update notification preferences"))
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
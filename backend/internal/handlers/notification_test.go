@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notificationDB keeps notifications in memory as rows of
+// notificationColumns plus the owning user, and answers the handler's
+// queries the way the SQL does.
+type notificationDB struct {
+	owners map[int64]int64
+	read   map[int64]bool
+}
+
+func newNotificationRouter(t *testing.T) (*gin.Engine, *sqltest.DB, *notificationDB) {
+	t.Helper()
+
+	// Notifications 1 to 3 are user 1's, 4 is user 2's; 1 is already read.
+	state := &notificationDB{
+		owners: map[int64]int64{1: 1, 2: 1, 3: 1, 4: 2},
+		read:   map[int64]bool{1: true},
+	}
+	columns := []string{"id", "kind", "todo_id", "message", "read_at", "created_at"}
+	row := func(id int64) []driver.Value {
+		var readAt driver.Value
+		if state.read[id] {
+			readAt = testTime
+		}
+		return []driver.Value{id, "due_soon", int64(5), "Due today: Todo", readAt, testTime}
+	}
+
+	fake, db := sqltest.New(t)
+	fake.On("FROM notifications\n", func(args []driver.Value) sqltest.Result {
+		userID, unreadOnly, limit := args[0].(int64), args[1].(bool), args[2].(int64)
+		result := sqltest.Result{Columns: columns}
+		for id := int64(len(state.owners)); id > 0 && int64(len(result.Rows)) < limit; id-- {
+			if state.owners[id] == userID && !(unreadOnly && state.read[id]) {
+				result.Rows = append(result.Rows, row(id))
+			}
+		}
+		return result
+	})
+	fake.On("SELECT COUNT(*) FROM notifications", func(args []driver.Value) sqltest.Result {
+		var n int64
+		for id, owner := range state.owners {
+			if owner == args[0].(int64) && !state.read[id] {
+				n++
+			}
+		}
+		return sqltest.Row([]string{"count"}, n)
+	})
+	fake.On("WHERE id = $1 AND user_id = $2", func(args []driver.Value) sqltest.Result {
+		id := args[0].(int64)
+		if state.owners[id] != args[1].(int64) {
+			return sqltest.Result{Columns: columns}
+		}
+		state.read[id] = true
+		return sqltest.Row(columns, row(id)...)
+	})
+	fake.On("SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1", func(args []driver.Value) sqltest.Result {
+		var n int64
+		for id, owner := range state.owners {
+			if owner == args[0].(int64) && !state.read[id] {
+				state.read[id] = true
+				n++
+			}
+		}
+		return sqltest.Result{RowsAffected: n}
+	})
+
+	h := NewNotificationHandler(db)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/me/notifications", withUser(1, h.ListNotifications))
+	r.POST("/api/me/notifications/read", withUser(1, h.MarkAllNotificationsRead))
+	r.POST("/api/me/notifications/:id/read", withUser(1, h.MarkNotificationRead))
+	r.GET("/api/me/notification-preferences", withUser(1, h.GetPreferences))
+	r.PUT("/api/me/notification-preferences", withUser(1, h.UpdatePreferences))
+	return r, fake, state
+}
+
+func listNotifications(t *testing.T, r *gin.Engine, query string) models.NotificationList {
+	t.Helper()
+
+	w := serveJSON(r, http.MethodGet, "/api/me/notifications"+query, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: status = %d: %s", w.Code, w.Body.String())
+	}
+	var list models.NotificationList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func noticeIDs(list models.NotificationList) string {
+	ids := []int64{}
+	for _, n := range list.Notifications {
+		ids = append(ids, n.ID)
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestListNotifications(t *testing.T) {
+	r, _, _ := newNotificationRouter(t)
+
+	tests := []struct {
+		query string
+		ids   string
+	}{
+		{"", "[3 2 1]"},
+		{"?unread=true", "[3 2]"},
+		{"?limit=1", "[3]"},
+	}
+	for _, tt := range tests {
+		list := listNotifications(t, r, tt.query)
+		if got := noticeIDs(list); got != tt.ids {
+			t.Errorf("list%s = %s, want %s", tt.query, got, tt.ids)
+		}
+		if list.UnreadCount != 2 {
+			t.Errorf("list%s: unread_count = %d, want 2", tt.query, list.UnreadCount)
+		}
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=201", "?limit=ten"} {
+		if w := serveJSON(r, http.MethodGet, "/api/me/notifications"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("list%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestMarkNotificationRead(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		unread int
+	}{
+		{"unread", "/api/me/notifications/2/read", http.StatusOK, 1},
+		{"already read", "/api/me/notifications/1/read", http.StatusOK, 2},
+		{"another user's", "/api/me/notifications/4/read", http.StatusNotFound, 2},
+		{"missing", "/api/me/notifications/99/read", http.StatusNotFound, 2},
+		{"invalid id", "/api/me/notifications/abc/read", http.StatusBadRequest, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _, state := newNotificationRouter(t)
+
+			w := serveJSON(r, http.MethodPost, tt.path, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusOK {
+				var n models.Notification
+				if err := json.Unmarshal(w.Body.Bytes(), &n); err != nil {
+					t.Fatal(err)
+				}
+				if n.ReadAt == nil {
+					t.Error("read_at not set")
+				}
+			}
+			if state.read[4] {
+				t.Error("user 2's notification was marked read")
+			}
+			if got := listNotifications(t, r, "").UnreadCount; got != tt.unread {
+				t.Errorf("unread_count = %d, want %d", got, tt.unread)
+			}
+		})
+	}
+}
+
+func TestMarkAllNotificationsRead(t *testing.T) {
+	r, fake, state := newNotificationRouter(t)
+
+	if w := serveJSON(r, http.MethodPost, "/api/me/notifications/read", ""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := listNotifications(t, r, "").UnreadCount; got != 0 {
+		t.Errorf("unread_count = %d, want 0", got)
+	}
+	if got := noticeIDs(listNotifications(t, r, "?unread=true")); got != "[]" {
+		t.Errorf("unread = %s, want none", got)
+	}
+	if state.read[4] {
+		t.Error("user 2's notification was marked read")
+	}
+	// read_at IS NULL keeps the time earlier notifications were read.
+	for _, q := range fake.Calls() {
+		if strings.HasPrefix(q, "UPDATE") && !strings.Contains(q, "read_at IS NULL") {
+			t.Errorf("update overwrites read_at: %s", q)
+		}
+	}
+}
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"on the day", `{"due_soon": true, "due_soon_days": 0, "admin_actions": true}`, http.StatusOK},
+		{"a week ahead", `{"due_soon": true, "due_soon_days": 7, "admin_actions": false}`, http.StatusOK},
+		{"all off", `{"due_soon": false, "due_soon_days": 1, "admin_actions": false}`, http.StatusOK},
+		{"negative", `{"due_soon": true, "due_soon_days": -1, "admin_actions": true}`, http.StatusBadRequest},
+		{"over a week", `{"due_soon": true, "due_soon_days": 8, "admin_actions": true}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fake, _ := newNotificationRouter(t)
+			var saved []driver.Value
+			fake.On("INSERT INTO notification_preferences", func(args []driver.Value) sqltest.Result {
+				saved = args
+				return sqltest.Result{RowsAffected: 1}
+			})
+
+			w := serveJSON(r, http.MethodPut, "/api/me/notification-preferences", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				if saved != nil {
+					t.Error("preferences saved despite the invalid body")
+				}
+				if !strings.Contains(w.Body.String(), `"due_soon_days"`) {
+					t.Errorf("body = %s, want an error on due_soon_days", w.Body.String())
+				}
+				return
+			}
+			var prefs models.NotificationPreferences
+			if err := json.Unmarshal([]byte(tt.body), &prefs); err != nil {
+				t.Fatal(err)
+			}
+			want := []driver.Value{int64(1), prefs.DueSoon, int64(prefs.DueSoonDays), prefs.AdminActions}
+			if fmt.Sprint(saved) != fmt.Sprint(want) {
+				t.Errorf("saved %v, want %v", saved, want)
+			}
+		})
+	}
+}
+
+func TestGetNotificationPreferencesDefaults(t *testing.T) {
+	r, fake, _ := newNotificationRouter(t)
+	fake.On("FROM notification_preferences", func([]driver.Value) sqltest.Result {
+		return sqltest.Result{Columns: []string{"due_soon", "due_soon_days", "admin_actions"}}
+	})
+
+	w := serveJSON(r, http.MethodGet, "/api/me/notification-preferences", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	want := `{"due_soon":true,"due_soon_days":1,"admin_actions":true}`
+	if got := w.Body.String(); got != want {
+		t.Errorf("preferences = %s, want %s", got, want)
+	}
+}
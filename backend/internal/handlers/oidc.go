@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/logging"
+	"todo-app/backend/internal/metrics"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/oidc"
+
+	"github.com/gin-gonic/gin"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+var errOIDCAccountConflict = errors.New("email is linked to a different identity")
+
+type OIDCHandler struct {
+	DB       *sql.DB
+	Provider *oidc.Provider
+	// PostLoginRedirect, when set, is where the browser is sent after a
+	// successful callback with the token in the URL fragment. Otherwise the
+	// callback responds with the usual login JSON.
+	PostLoginRedirect string
+}
+
+func NewOIDCHandler(db *sql.DB, provider *oidc.Provider, postLoginRedirect string) *OIDCHandler {
+	return &OIDCHandler{DB: db, Provider: provider, PostLoginRedirect: postLoginRedirect}
+}
+
+func (h *OIDCHandler) Login(c *gin.Context) {
+	ctx := c.Request.Context()
+	state, err := oidc.RandomString()
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	if _, err := h.DB.ExecContext(ctx, "DELETE FROM oidc_states WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	_, err = h.DB.ExecContext(ctx,
+		`INSERT INTO oidc_states (state, nonce, code_verifier, expires_at)
+		 VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second')`,
+		state, nonce, verifier, int(oidcStateTTL.Seconds()),
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to start single sign-on"))
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.Provider.AuthCodeURL(state, nonce, verifier))
+}
+
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	ctx := c.Request.Context()
+	if errCode := c.Query("error"); errCode != "" {
+		metrics.Login("oidc", metrics.LoginFailed)
+		apperror.Respond(c, errSSOFailed.WithMessage("Single sign-on failed: "+errCode))
+		return
+	}
+
+	var nonce, verifier string
+	err := h.DB.QueryRowContext(ctx,
+		`DELETE FROM oidc_states
+		 WHERE state = $1 AND expires_at > CURRENT_TIMESTAMP
+		 RETURNING nonce, code_verifier`,
+		c.Query("state"),
+	).Scan(&nonce, &verifier)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errSSOStateInvalid)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	claims, err := h.Provider.Exchange(ctx, c.Query("code"), verifier, nonce)
+	if err != nil {
+		metrics.Login("oidc", metrics.LoginFailed)
+		apperror.Respond(c, errSSOFailed)
+		return
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		metrics.Login("oidc", metrics.LoginFailed)
+		apperror.Respond(c, errSSOEmailNotVerified)
+		return
+	}
+
+	user, err := h.linkUser(ctx, claims)
+	if errors.Is(err, errOIDCAccountConflict) {
+		apperror.Respond(c, errSSOIdentityConflict)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to sign in"))
+		return
+	}
+
+	if user.SuspendedAt != nil {
+		metrics.Login("oidc", metrics.LoginFailed)
+		apperror.Respond(c, errAccountSuspended)
+		return
+	}
+
+	var opts []middleware.TokenOption
+	if claims.MFA() {
+		opts = append(opts, middleware.WithMFA())
+	}
+	token, err := middleware.GenerateToken(user.ID, user.IsAdmin, opts...)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+		return
+	}
+
+	metrics.Login("oidc", metrics.LoginSucceeded)
+	if h.PostLoginRedirect != "" {
+		// A fragment is never sent to servers, keeping the token out of
+		// access logs and Referer headers.
+		c.Redirect(http.StatusFound, h.PostLoginRedirect+"#token="+url.QueryEscape(token))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.LoginResponse{
+		Token: token,
+		User:  *user,
+	})
+}
+
+// linkUser finds the account for an identity, linking an existing account
+// with the same email on first sign-on or provisioning a new one. Provisioned
+// accounts have no usable password.
+func (h *OIDCHandler) linkUser(ctx context.Context, claims *oidc.Claims) (*models.User, error) {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	issuer := h.Provider.Issuer()
+	var userID int
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM users WHERE oidc_issuer = $1 AND oidc_subject = $2 FOR UPDATE",
+		issuer, claims.Subject,
+	).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		var linkedSubject sql.NullString
+		err = tx.QueryRowContext(ctx,
+			"SELECT id, oidc_subject FROM users WHERE LOWER(email) = LOWER($1) FOR UPDATE",
+			claims.Email,
+		).Scan(&userID, &linkedSubject)
+
+		switch {
+		case err == sql.ErrNoRows:
+			err = tx.QueryRowContext(ctx,
+				`INSERT INTO users (email, password, oidc_issuer, oidc_subject)
+				 VALUES ($1, '', $2, $3) RETURNING id`,
+				strings.ToLower(claims.Email), issuer, claims.Subject,
+			).Scan(&userID)
+		case err != nil:
+		case linkedSubject.Valid:
+			return nil, errOIDCAccountConflict
+		default:
+			_, err = tx.ExecContext(ctx,
+				`UPDATE users SET oidc_issuer = $1, oidc_subject = $2, updated_at = CURRENT_TIMESTAMP
+				 WHERE id = $3`,
+				issuer, claims.Subject, userID,
+			)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if isAdmin, managed := h.Provider.IsAdmin(claims); managed {
+		var wasAdmin bool
+		err = tx.QueryRowContext(ctx, "SELECT is_admin FROM users WHERE id = $1", userID).Scan(&wasAdmin)
+		if err != nil {
+			return nil, err
+		}
+		// The groups decide who is an admin, except that the last active
+		// admin keeps the role so that nobody is locked out.
+		if wasAdmin && !isAdmin {
+			err = ensureAdminRemains(ctx, tx, userID)
+			if errors.Is(err, errLastAdmin) {
+				logging.FromContext(ctx).Warn("Not revoking admin rights of the last active admin", "user_id", userID)
+				isAdmin, err = true, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE users SET is_admin = $1, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $2 AND is_admin <> $1`,
+			isAdmin, userID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var user models.User
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, email, is_admin, must_change_password, totp_enabled, suspended_at, created_at, updated_at
+		 FROM users WHERE id = $1`,
+		userID,
+	).Scan(
+		&user.ID, &user.Email, &user.IsAdmin, &user.MustChangePassword,
+		&user.TwoFactorEnabled, &user.SuspendedAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, tx.Commit()
+}
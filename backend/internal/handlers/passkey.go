@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/metrics"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+
+	passkeySessionTTL = 5 * time.Minute
+)
+
+type PasskeyHandler struct {
+	DB       *sql.DB
+	WebAuthn *webauthn.WebAuthn
+}
+
+func NewPasskeyHandler(db *sql.DB, wa *webauthn.WebAuthn) *PasskeyHandler {
+	return &PasskeyHandler{DB: db, WebAuthn: wa}
+}
+
+// passkeyUser adapts a user row and its stored credentials to webauthn.User.
+// The user handle is the decimal user ID, which lets discoverable logins map
+// the handle returned by the authenticator straight back to a row.
+type passkeyUser struct {
+	models.User
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.ID))
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.Email
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.loadUser(ctx, userCtx.UserID)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	// Excluding existing credentials stops the same authenticator from being
+	// registered twice, while still allowing several different ones.
+	creation, session, err := h.WebAuthn.BeginRegistration(
+		user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to begin passkey registration"))
+		return
+	}
+
+	sessionID, err := h.saveSession(ctx, &user.ID, ceremonyRegistration, session)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to begin passkey registration"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"options":    creation,
+	})
+}
+
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.PasskeyRegistrationRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	session, sessionUserID, err := h.takeSession(ctx, req.SessionID, ceremonyRegistration)
+	if err != nil || sessionUserID == nil || *sessionUserID != userCtx.UserID {
+		apperror.Respond(c, errPasskeySessionInvalid)
+		return
+	}
+
+	user, err := h.loadUser(ctx, userCtx.UserID)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		apperror.Respond(c, errPasskeyInvalid)
+		return
+	}
+
+	credential, err := h.WebAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		apperror.Respond(c, errPasskeyAttestation)
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "Passkey"
+	}
+
+	transports := make([]string, len(credential.Transport))
+	for i, t := range credential.Transport {
+		transports[i] = string(t)
+	}
+
+	var passkey models.Passkey
+	err = h.DB.QueryRowContext(ctx,
+		`INSERT INTO webauthn_credentials
+		 (user_id, credential_id, public_key, attestation_type, transports, aaguid,
+		  sign_count, backup_eligible, backup_state, name)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING id, name, created_at, last_used_at`,
+		user.ID, credential.ID, credential.PublicKey, credential.AttestationType,
+		strings.Join(transports, ","), credential.Authenticator.AAGUID,
+		int64(credential.Authenticator.SignCount), credential.Flags.BackupEligible,
+		credential.Flags.BackupState, name,
+	).Scan(&passkey.ID, &passkey.Name, &passkey.CreatedAt, &passkey.LastUsedAt)
+	if err != nil {
+		apperror.Respond(c, errPasskeyExists)
+		return
+	}
+
+	c.JSON(http.StatusCreated, passkey)
+}
+
+// BeginLogin starts an assertion. With an email it is limited to that
+// account's credentials; without one it is a discoverable (usernameless)
+// login and the authenticator chooses the account.
+func (h *PasskeyHandler) BeginLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.PasskeyLoginBeginRequest
+	if c.Request.ContentLength > 0 {
+		if err := validation.Bind(c, &req); err != nil {
+			apperror.Respond(c, err)
+			return
+		}
+	}
+
+	var assertion *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	var userID *int
+
+	if req.Email != "" {
+		var id int
+		err := h.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", req.Email).Scan(&id)
+		if err != nil && err != sql.ErrNoRows {
+			apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+			return
+		}
+
+		var user *passkeyUser
+		if err == nil {
+			user, err = h.loadUser(ctx, id)
+			if err != nil {
+				apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+				return
+			}
+		}
+		if user == nil || len(user.credentials) == 0 {
+			apperror.Respond(c, errNoPasskeys)
+			return
+		}
+
+		assertion, session, err = h.WebAuthn.BeginLogin(user)
+		if err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to begin passkey login"))
+			return
+		}
+		userID = &user.ID
+	} else {
+		var err error
+		assertion, session, err = h.WebAuthn.BeginDiscoverableLogin()
+		if err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to begin passkey login"))
+			return
+		}
+	}
+
+	sessionID, err := h.saveSession(ctx, userID, ceremonyLogin, session)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to begin passkey login"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"options":    assertion,
+	})
+}
+
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.PasskeyLoginFinishRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	session, sessionUserID, err := h.takeSession(ctx, req.SessionID, ceremonyLogin)
+	if err != nil {
+		apperror.Respond(c, errPasskeySessionInvalid)
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		apperror.Respond(c, errPasskeyInvalid)
+		return
+	}
+
+	var user *passkeyUser
+	var credential *webauthn.Credential
+	if sessionUserID != nil {
+		user, err = h.loadUser(ctx, *sessionUserID)
+		if err == nil {
+			credential, err = h.WebAuthn.ValidateLogin(user, *session, parsed)
+		}
+	} else {
+		var found webauthn.User
+		found, credential, err = h.WebAuthn.ValidatePasskeyLogin(h.discoverUser(ctx), *session, parsed)
+		if err == nil {
+			user = found.(*passkeyUser)
+		}
+	}
+	if err != nil {
+		metrics.Login("passkey", metrics.LoginFailed)
+		apperror.Respond(c, errPasskeyVerification)
+		return
+	}
+
+	// A counter that fails to advance suggests the private key has been
+	// cloned. Authenticators that do not implement counters always report 0,
+	// which the library does not flag.
+	if credential.Authenticator.CloneWarning {
+		metrics.Login("passkey", metrics.LoginFailed)
+		apperror.Respond(c, errPasskeyCloned)
+		return
+	}
+
+	if user.SuspendedAt != nil {
+		metrics.Login("passkey", metrics.LoginFailed)
+		apperror.Respond(c, errAccountSuspended)
+		return
+	}
+
+	_, err = h.DB.ExecContext(ctx,
+		`UPDATE webauthn_credentials
+		 SET sign_count = $1, backup_state = $2, last_used_at = CURRENT_TIMESTAMP
+		 WHERE user_id = $3 AND credential_id = $4`,
+		int64(credential.Authenticator.SignCount), credential.Flags.BackupState,
+		user.ID, credential.ID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	// A user-verified passkey combines possession with a PIN or biometric, so
+	// it satisfies the second-factor requirement on its own. Without user
+	// verification it is only possession, so an account with two-factor
+	// authentication still has to answer a TOTP challenge.
+	var opts []middleware.TokenOption
+	if credential.Flags.UserVerified {
+		opts = append(opts, middleware.WithMFA())
+	} else if user.TwoFactorEnabled {
+		challenge, err := middleware.GenerateChallengeToken(user.ID)
+		if err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+			return
+		}
+
+		c.JSON(http.StatusOK, models.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		})
+		return
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.IsAdmin, opts...)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+		return
+	}
+
+	metrics.Login("passkey", metrics.LoginSucceeded)
+	c.JSON(http.StatusOK, models.LoginResponse{
+		Token: token,
+		User:  user.User,
+	})
+}
+
+func (h *PasskeyHandler) ListPasskeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT id, name, created_at, last_used_at
+		 FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`,
+		userCtx.UserID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch passkeys"))
+		return
+	}
+	defer rows.Close()
+
+	passkeys := []models.Passkey{}
+	for rows.Next() {
+		var passkey models.Passkey
+		if err := rows.Scan(&passkey.ID, &passkey.Name, &passkey.CreatedAt, &passkey.LastUsedAt); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan passkey"))
+			return
+		}
+		passkeys = append(passkeys, passkey)
+	}
+
+	c.JSON(http.StatusOK, passkeys)
+}
+
+func (h *PasskeyHandler) DeletePasskey(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	passkeyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidPasskeyID)
+		return
+	}
+
+	result, err := h.DB.ExecContext(ctx,
+		"DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2",
+		passkeyID, userCtx.UserID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete passkey"))
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		apperror.Respond(c, errPasskeyNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted successfully"})
+}
+
+func (h *PasskeyHandler) discoverUser(ctx context.Context) webauthn.DiscoverableUserHandler {
+	return func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		user, err := h.loadUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+}
+
+func (h *PasskeyHandler) loadUser(ctx context.Context, userID int) (*passkeyUser, error) {
+	user := &passkeyUser{}
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT id, email, is_admin, must_change_password, totp_enabled, suspended_at FROM users WHERE id = $1",
+		userID,
+	).Scan(&user.ID, &user.Email, &user.IsAdmin, &user.MustChangePassword, &user.TwoFactorEnabled, &user.SuspendedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT credential_id, public_key, attestation_type, transports, aaguid,
+		        sign_count, backup_eligible, backup_state
+		 FROM webauthn_credentials WHERE user_id = $1`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var credential webauthn.Credential
+		var transports string
+		var signCount int64
+		err := rows.Scan(
+			&credential.ID, &credential.PublicKey, &credential.AttestationType, &transports,
+			&credential.Authenticator.AAGUID, &signCount,
+			&credential.Flags.BackupEligible, &credential.Flags.BackupState,
+		)
+		if err != nil {
+			return nil, err
+		}
+		credential.Authenticator.SignCount = uint32(signCount)
+		if transports != "" {
+			for _, t := range strings.Split(transports, ",") {
+				credential.Transport = append(credential.Transport, protocol.AuthenticatorTransport(t))
+			}
+		}
+		user.credentials = append(user.credentials, credential)
+	}
+
+	return user, rows.Err()
+}
+
+// saveSession persists ceremony state in the database rather than memory so
+// the begin and finish requests may be served by different replicas.
+func (h *PasskeyHandler) saveSession(ctx context.Context, userID *int, ceremony string, session *webauthn.SessionData) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	sessionID := hex.EncodeToString(buf)
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := h.DB.ExecContext(ctx, "DELETE FROM webauthn_sessions WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		return "", err
+	}
+
+	_, err = h.DB.ExecContext(ctx,
+		`INSERT INTO webauthn_sessions (id, user_id, ceremony, data, expires_at)
+		 VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP + $5 * INTERVAL '1 second')`,
+		sessionID, userID, ceremony, data, int(passkeySessionTTL.Seconds()),
+	)
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// takeSession loads and deletes a ceremony session so that each challenge
+// can only be answered once.
+func (h *PasskeyHandler) takeSession(ctx context.Context, sessionID, ceremony string) (*webauthn.SessionData, *int, error) {
+	var data []byte
+	var userID sql.NullInt64
+	err := h.DB.QueryRowContext(ctx,
+		`DELETE FROM webauthn_sessions
+		 WHERE id = $1 AND ceremony = $2 AND expires_at > CURRENT_TIMESTAMP
+		 RETURNING user_id, data`,
+		sessionID, ceremony,
+	).Scan(&userID, &data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, nil, err
+	}
+
+	if !userID.Valid {
+		return &session, nil, nil
+	}
+	id := int(userID.Int64)
+	return &session, &id, nil
+}
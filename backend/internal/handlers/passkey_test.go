@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testRPID   = "todo.example.com"
+	testOrigin = "https://todo.example.com"
+)
+
+var b64 = base64.RawURLEncoding
+
+// testAuthenticator holds one P-256 passkey and signs assertions with it.
+type testAuthenticator struct {
+	key    *ecdsa.PrivateKey
+	id     []byte
+	userID int64
+	// signCount is the counter reported in the next assertion.
+	signCount uint32
+}
+
+func newTestAuthenticator(t *testing.T, id string, userID int64) *testAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthenticator{key: key, id: []byte(id), userID: userID}
+}
+
+// publicKey is the COSE key stored in webauthn_credentials.public_key.
+func (a *testAuthenticator) publicKey(t *testing.T) []byte {
+	t.Helper()
+
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	data, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: x,
+		YCoord: y,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// assert answers challenge the way navigator.credentials.get() does. uv
+// says whether the user was verified with a PIN or biometric.
+func (a *testAuthenticator) assert(t *testing.T, challenge string, uv bool) json.RawMessage {
+	t.Helper()
+
+	clientData, err := json.Marshal(map[string]string{
+		"type":      "webauthn.get",
+		"challenge": challenge,
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	flags := byte(0x01) // user present
+	if uv {
+		flags |= 0x04
+	}
+	authData := append(rpIDHash[:], flags)
+	authData = binary.BigEndian.AppendUint32(authData, a.signCount)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := json.Marshal(map[string]any{
+		"id":    b64.EncodeToString(a.id),
+		"rawId": b64.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64.EncodeToString(clientData),
+			"authenticatorData": b64.EncodeToString(authData),
+			"signature":         b64.EncodeToString(signature),
+			"userHandle":        b64.EncodeToString([]byte(strconv.FormatInt(a.userID, 10))),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credential
+}
+
+// passkeyDB keeps users, their stored credentials and ceremony sessions in
+// memory. User 1 (alice) has passkeys "a1" and "a2", user 2 (bob) has "b1".
+type passkeyDB struct {
+	totp      map[int64]bool
+	suspended map[int64]bool
+	// signCounts are the stored counters, by credential ID.
+	signCounts map[string]int64
+	sessions   map[string][]driver.Value
+	updated    []driver.Value
+}
+
+func newPasskeyRouter(t *testing.T, authenticators ...*testAuthenticator) (*gin.Engine, *passkeyDB) {
+	t.Helper()
+
+	middleware.SetJWTSecret("test-secret")
+	state := &passkeyDB{
+		totp:       map[int64]bool{},
+		suspended:  map[int64]bool{},
+		signCounts: map[string]int64{},
+		sessions:   map[string][]driver.Value{},
+	}
+	emails := map[int64]string{1: "alice@example.com", 2: "bob@example.com"}
+
+	fake, db := sqltest.New(t)
+	fake.On("SELECT id FROM users WHERE email = $1", func(args []driver.Value) sqltest.Result {
+		for id, email := range emails {
+			if email == args[0] {
+				return sqltest.Row([]string{"id"}, id)
+			}
+		}
+		return sqltest.Result{Columns: []string{"id"}}
+	})
+	fake.On("SELECT id, email, is_admin, must_change_password, totp_enabled, suspended_at FROM users", func(args []driver.Value) sqltest.Result {
+		id := args[0].(int64)
+		var suspendedAt driver.Value
+		if state.suspended[id] {
+			suspendedAt = testTime
+		}
+		return sqltest.Row([]string{"id", "email", "is_admin", "must_change_password", "totp_enabled", "suspended_at"},
+			id, emails[id], false, false, state.totp[id], suspendedAt)
+	})
+	fake.On("SELECT credential_id, public_key", func(args []driver.Value) sqltest.Result {
+		result := sqltest.Result{Columns: []string{"credential_id", "public_key", "attestation_type", "transports", "aaguid", "sign_count", "backup_eligible", "backup_state"}}
+		for _, a := range authenticators {
+			if a.userID == args[0].(int64) {
+				result.Rows = append(result.Rows, []driver.Value{a.id, a.publicKey(t), "none", "internal", make([]byte, 16), state.signCounts[string(a.id)], false, false})
+			}
+		}
+		return result
+	})
+	fake.On("DELETE FROM webauthn_sessions WHERE expires_at", func([]driver.Value) sqltest.Result {
+		return sqltest.Result{}
+	})
+	fake.On("INSERT INTO webauthn_sessions", func(args []driver.Value) sqltest.Result {
+		state.sessions[args[0].(string)] = []driver.Value{args[1], args[3]}
+		return sqltest.Result{RowsAffected: 1}
+	})
+	fake.On("RETURNING user_id, data", func(args []driver.Value) sqltest.Result {
+		session, ok := state.sessions[args[0].(string)]
+		if !ok {
+			return sqltest.Result{Columns: []string{"user_id", "data"}}
+		}
+		delete(state.sessions, args[0].(string))
+		return sqltest.Row([]string{"user_id", "data"}, session...)
+	})
+	fake.On("UPDATE webauthn_credentials", func(args []driver.Value) sqltest.Result {
+		state.updated = args
+		return sqltest.Result{RowsAffected: 1}
+	})
+	fake.On("DELETE FROM webauthn_credentials", func(args []driver.Value) sqltest.Result {
+		// Passkey 7 is alice's and 8 is bob's.
+		owners := map[int64]int64{7: 1, 8: 2}
+		if owners[args[0].(int64)] != args[1].(int64) {
+			return sqltest.Result{}
+		}
+		return sqltest.Result{RowsAffected: 1}
+	})
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "TODO App",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewPasskeyHandler(db, wa)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/passkeys/login/begin", h.BeginLogin)
+	r.POST("/api/passkeys/login/finish", h.FinishLogin)
+	r.DELETE("/api/me/passkeys/:id", withUser(1, h.DeletePasskey))
+	return r, state
+}
+
+// beginPasskeyLogin starts a login, for email or usernameless when it is
+// empty, and returns the session ID and challenge.
+func beginPasskeyLogin(t *testing.T, r *gin.Engine, email string) (string, string) {
+	t.Helper()
+
+	body := ""
+	if email != "" {
+		body = `{"email": "` + email + `"}`
+	}
+	w := serveJSON(r, http.MethodPost, "/api/passkeys/login/begin", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("begin: status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		SessionID string `json:"session_id"`
+		Options   struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+			} `json:"publicKey"`
+		} `json:"options"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.SessionID, resp.Options.PublicKey.Challenge
+}
+
+func finishPasskeyLogin(r *gin.Engine, sessionID string, credential json.RawMessage) (int, map[string]any) {
+	body, _ := json.Marshal(models.PasskeyLoginFinishRequest{SessionID: sessionID, Credential: credential})
+	w := serveJSON(r, http.MethodPost, "/api/passkeys/login/finish", string(body))
+	var resp map[string]any
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		// key picks the authenticator that answers: a1, a2 or b1.
+		key       string
+		uv        bool
+		totp      bool
+		stored    int64
+		signCount uint32
+		suspended bool
+		status    int
+		// result is "session", "mfa session", "challenge" or an error code.
+		result string
+	}{
+		{"user verified", "alice@example.com", "a1", true, false, 0, 0, false, http.StatusOK, "mfa session"},
+		{"user present only", "alice@example.com", "a1", false, false, 0, 0, false, http.StatusOK, "session"},
+		{"user verified with 2FA on", "alice@example.com", "a1", true, true, 0, 0, false, http.StatusOK, "mfa session"},
+		{"user present only with 2FA on", "alice@example.com", "a1", false, true, 0, 0, false, http.StatusOK, "challenge"},
+		{"second passkey", "alice@example.com", "a2", true, false, 0, 0, false, http.StatusOK, "mfa session"},
+		{"usernameless", "", "a2", true, false, 0, 0, false, http.StatusOK, "mfa session"},
+		{"usernameless with 2FA on", "", "a1", false, true, 0, 0, false, http.StatusOK, "challenge"},
+		{"counter advanced", "alice@example.com", "a1", true, false, 4, 5, false, http.StatusOK, "mfa session"},
+		{"counter went back", "alice@example.com", "a1", true, false, 5, 4, false, http.StatusUnauthorized, "passkey_sign_count_invalid"},
+		{"counter repeated", "alice@example.com", "a1", true, false, 5, 5, false, http.StatusUnauthorized, "passkey_sign_count_invalid"},
+		{"another user's passkey", "alice@example.com", "b1", true, false, 0, 0, false, http.StatusUnauthorized, "passkey_verification_failed"},
+		{"suspended", "alice@example.com", "a1", true, false, 0, 0, true, http.StatusForbidden, "account_suspended"},
+		{"suspended, usernameless", "", "a1", true, false, 0, 0, true, http.StatusForbidden, "account_suspended"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticators := map[string]*testAuthenticator{
+				"a1": newTestAuthenticator(t, "a1", 1),
+				"a2": newTestAuthenticator(t, "a2", 1),
+				"b1": newTestAuthenticator(t, "b1", 2),
+			}
+			r, state := newPasskeyRouter(t, authenticators["a1"], authenticators["a2"], authenticators["b1"])
+			state.totp[1] = tt.totp
+			state.suspended[1] = tt.suspended
+			state.signCounts[tt.key] = tt.stored
+			a := authenticators[tt.key]
+			a.signCount = tt.signCount
+
+			sessionID, challenge := beginPasskeyLogin(t, r, tt.email)
+			succeeded := counter(t, "todoapp_logins_total", "method", "passkey", "result", "succeeded")
+			failed := counter(t, "todoapp_logins_total", "method", "passkey", "result", "failed")
+			status, resp := finishPasskeyLogin(r, sessionID, a.assert(t, challenge, tt.uv))
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %v", status, tt.status, resp)
+			}
+			// A challenge is neither: the login finishes at POST /api/login/2fa.
+			wantSucceeded, wantFailed := 0.0, 0.0
+			switch {
+			case strings.HasSuffix(tt.result, "session"):
+				wantSucceeded = 1
+			case tt.result != "challenge":
+				wantFailed = 1
+			}
+			if got := counter(t, "todoapp_logins_total", "method", "passkey", "result", "succeeded") - succeeded; got != wantSucceeded {
+				t.Errorf("succeeded logins rose by %v, want %v", got, wantSucceeded)
+			}
+			if got := counter(t, "todoapp_logins_total", "method", "passkey", "result", "failed") - failed; got != wantFailed {
+				t.Errorf("failed logins rose by %v, want %v", got, wantFailed)
+			}
+
+			switch tt.result {
+			case "session", "mfa session":
+				claims := &middleware.Claims{}
+				if _, _, err := jwt.NewParser().ParseUnverified(resp["token"].(string), claims); err != nil {
+					t.Fatal(err)
+				}
+				if claims.UserID != 1 || claims.MFA != (tt.result == "mfa session") {
+					t.Errorf("claims = user %d, mfa %v, want %s for user 1", claims.UserID, claims.MFA, tt.result)
+				}
+				if state.updated == nil || state.updated[0] != int64(tt.signCount) || string(state.updated[3].([]byte)) != tt.key {
+					t.Errorf("credential update = %v, want sign count %d on %s", state.updated, tt.signCount, tt.key)
+				}
+			case "challenge":
+				if resp["token"] != nil || resp["two_factor_required"] != true {
+					t.Fatalf("response = %v, want a two-factor challenge", resp)
+				}
+				if userID, err := middleware.ParseChallengeToken(resp["challenge_token"].(string)); err != nil || userID != 1 {
+					t.Errorf("challenge for user %d, %v, want 1", userID, err)
+				}
+			default:
+				if resp["code"] != tt.result {
+					t.Errorf("code = %v, want %s", resp["code"], tt.result)
+				}
+				if resp["token"] != nil {
+					t.Error("token issued despite the failure")
+				}
+			}
+		})
+	}
+}
+
+func TestPasskeySessionIsSingleUse(t *testing.T) {
+	a := newTestAuthenticator(t, "a1", 1)
+	r, _ := newPasskeyRouter(t, a)
+
+	sessionID, challenge := beginPasskeyLogin(t, r, "alice@example.com")
+	credential := a.assert(t, challenge, true)
+	if status, resp := finishPasskeyLogin(r, sessionID, credential); status != http.StatusOK {
+		t.Fatalf("first use: status = %d: %v", status, resp)
+	}
+	status, resp := finishPasskeyLogin(r, sessionID, credential)
+	if status != http.StatusBadRequest || resp["code"] != "passkey_session_invalid" {
+		t.Errorf("replay: status = %d: %v, want passkey_session_invalid", status, resp)
+	}
+
+	if status, resp := finishPasskeyLogin(r, "unknown", credential); status != http.StatusBadRequest || resp["code"] != "passkey_session_invalid" {
+		t.Errorf("unknown session: status = %d: %v, want passkey_session_invalid", status, resp)
+	}
+}
+
+func TestPasskeyLoginWithoutPasskeys(t *testing.T) {
+	r, _ := newPasskeyRouter(t, newTestAuthenticator(t, "b1", 2))
+
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		w := serveJSON(r, http.MethodPost, "/api/passkeys/login/begin", `{"email": "`+email+`"}`)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"code":"no_passkeys"`) {
+			t.Errorf("%s: status = %d: %s, want no_passkeys", email, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestDeletePasskeyChecksOwner(t *testing.T) {
+	r, _ := newPasskeyRouter(t)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/me/passkeys/8", http.StatusNotFound},
+		{"/api/me/passkeys/9", http.StatusNotFound},
+		{"/api/me/passkeys/x", http.StatusBadRequest},
+		{"/api/me/passkeys/7", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := serveJSON(r, http.MethodDelete, tt.path, ""); w.Code != tt.status {
+			t.Errorf("DELETE %s: status = %d, want %d: %s", tt.path, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
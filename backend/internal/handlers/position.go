@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"todo-app/backend/internal/rank"
+)
+
+// todoOrder is the manual order of a user's todos. Todos get a position
+// when they are first moved; until then they come first, newest first.
+const todoOrder = `position NULLS FIRST, id DESC`
+
+// lockTodoOrder serialises changes to the order of the user's todos, so
+// that two moves never compute a key from the same neighbours.
+func lockTodoOrder(ctx context.Context, tx *sql.Tx, userID int) error {
+	var id int
+	return tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&id)
+}
+
+// placeTodo moves todoID right after afterID, right before beforeID, or
+// between the two, by rewriting its position only. When the neighbours
+// leave no room, or the key would grow too long, the whole list is spread
+// out first. tx must hold lockTodoOrder.
+func placeTodo(ctx context.Context, tx *sql.Tx, userID, todoID int, afterID, beforeID *int) error {
+	key, err := positionBetween(ctx, tx, userID, todoID, afterID, beforeID)
+	if errors.Is(err, rank.ErrNoRoom) || (err == nil && len(key) > rank.MaxLength) {
+		if err := rebalancePositions(ctx, tx, userID); err != nil {
+			return err
+		}
+		key, err = positionBetween(ctx, tx, userID, todoID, afterID, beforeID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE todos SET position = $1 WHERE id = $2", key, todoID)
+	return err
+}
+
+// positionBetween returns a key for todoID at the requested place. It
+// returns rank.ErrNoRoom when an anchor has no position yet or shares its
+// key with a neighbour.
+func positionBetween(ctx context.Context, tx *sql.Tx, userID, todoID int, afterID, beforeID *int) (string, error) {
+	var lo, hi string
+	var after, before anchor
+	var err error
+
+	if afterID != nil {
+		if after, err = loadAnchor(ctx, tx, userID, todoID, *afterID); err != nil {
+			return "", err
+		}
+		lo = after.position
+	}
+	if beforeID != nil {
+		if before, err = loadAnchor(ctx, tx, userID, todoID, *beforeID); err != nil {
+			return "", err
+		}
+		hi = before.position
+	}
+
+	switch {
+	case afterID != nil && beforeID != nil:
+		if after.position > before.position || (after.position == before.position && after.id < before.id) {
+			return "", errInvalidMove.WithMessage("after_id must come before before_id")
+		}
+	case afterID != nil:
+		// The todo that currently follows the anchor, if any.
+		err = tx.QueryRowContext(ctx,
+			`SELECT position FROM todos
+			 WHERE user_id = $1 AND id <> $2 AND (position > $3 OR (position = $3 AND id < $4))
+			 ORDER BY position, id DESC LIMIT 1`,
+			userID, todoID, after.position, after.id,
+		).Scan(&hi)
+	default:
+		// The positioned todo that currently precedes the anchor, if any.
+		// Todos without a position come before it either way.
+		err = tx.QueryRowContext(ctx,
+			`SELECT position FROM todos
+			 WHERE user_id = $1 AND id <> $2 AND (position < $3 OR (position = $3 AND id > $4))
+			 ORDER BY position DESC, id LIMIT 1`,
+			userID, todoID, before.position, before.id,
+		).Scan(&lo)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	return rank.Between(lo, hi)
+}
+
+type anchor struct {
+	id       int
+	position string
+}
+
+func loadAnchor(ctx context.Context, tx *sql.Tx, userID, todoID, anchorID int) (anchor, error) {
+	if anchorID == todoID {
+		return anchor{}, errInvalidMove.WithMessage("A todo cannot be moved next to itself")
+	}
+
+	var position sql.NullString
+	err := tx.QueryRowContext(ctx,
+		"SELECT position FROM todos WHERE id = $1 AND user_id = $2",
+		anchorID, userID,
+	).Scan(&position)
+	if err == sql.ErrNoRows {
+		return anchor{}, errTodoNotFound.WithMessage("Anchor todo not found")
+	}
+	if err != nil {
+		return anchor{}, err
+	}
+	if !position.Valid {
+		return anchor{}, rank.ErrNoRoom
+	}
+	return anchor{id: anchorID, position: position.String}, nil
+}
+
+// rebalancePositions gives all of the user's todos short, evenly spaced
+// keys in their current order.
+func rebalancePositions(ctx context.Context, tx *sql.Tx, userID int) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM todos WHERE user_id = $1 ORDER BY `+todoOrder,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE todos t SET position = k.position
+		 FROM unnest($1::int[], $2::text[]) AS k(id, position)
+		 WHERE t.id = k.id`,
+		pq.Array(ids), pq.Array(rank.Spread(len(ids))),
+	)
+	return err
+}
+
+// longPositionLength is the key length at which RebalancePositions spreads a
+// user's todos out. Half of rank.MaxLength leaves plenty of moves before a
+// move would have to rebalance the list itself.
+const longPositionLength = rank.MaxLength / 2
+
+// RebalancePositions spreads out the todos of up to limit users with a
+// position key of longPositionLength or more. Keys only grow when todos are
+// moved to the same spot again and again, so this keeps such moves from
+// paying for a whole-list rebalance. A user whose list could not be
+// rebalanced is retried on the next run.
+func RebalancePositions(ctx context.Context, db *sql.DB, limit int) error {
+	rows, err := db.QueryContext(ctx,
+		"SELECT DISTINCT user_id FROM todos WHERE LENGTH(position) >= $1 LIMIT $2",
+		longPositionLength, limit,
+	)
+	if err != nil {
+		return err
+	}
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, userID := range userIDs {
+		if err := rebalanceUser(ctx, db, userID); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func rebalanceUser(ctx context.Context, db *sql.DB, userID int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockTodoOrder(ctx, tx, userID); err != nil {
+		return err
+	}
+	if err := rebalancePositions(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
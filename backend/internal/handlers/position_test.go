@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/rank"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/lib/pq"
+)
+
+func TestRebalancePositions(t *testing.T) {
+	fake, db := sqltest.New(t)
+	var found []driver.Value
+	fake.On("SELECT DISTINCT user_id FROM todos", func(args []driver.Value) sqltest.Result {
+		found = args
+		return sqltest.Result{Columns: []string{"user_id"}, Rows: [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}}}
+	})
+	var locked []int64
+	fake.On("FROM users WHERE id = $1 FOR UPDATE", func(args []driver.Value) sqltest.Result {
+		locked = append(locked, args[0].(int64))
+		return sqltest.Row([]string{"id"}, args[0])
+	})
+	// User 1 has three todos and user 3 one; user 2's list fails to load.
+	todos := map[int64][]int64{1: {4, 9, 2}, 3: {7}}
+	fake.On("SELECT id FROM todos WHERE user_id = $1", func(args []driver.Value) sqltest.Result {
+		userID := args[0].(int64)
+		if userID == 2 {
+			return sqltest.Result{Err: errors.New("connection reset")}
+		}
+		result := sqltest.Result{Columns: []string{"id"}}
+		for _, id := range todos[userID] {
+			result.Rows = append(result.Rows, []driver.Value{id})
+		}
+		return result
+	})
+	updated := map[string][]string{}
+	fake.On("UPDATE todos t SET position = k.position", func(args []driver.Value) sqltest.Result {
+		var ids pq.Int64Array
+		var keys pq.StringArray
+		if err := ids.Scan(args[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := keys.Scan(args[1]); err != nil {
+			t.Fatal(err)
+		}
+		updated[fmt.Sprint([]int64(ids))] = keys
+		return sqltest.Result{RowsAffected: int64(len(ids))}
+	})
+
+	err := RebalancePositions(context.Background(), db, 100)
+	if err == nil || !strings.Contains(err.Error(), "user 2") {
+		t.Errorf("RebalancePositions = %v, want user 2's error", err)
+	}
+	if len(found) != 2 || found[0] != int64(rank.MaxLength/2) || found[1] != int64(100) {
+		t.Errorf("users found with args %v, want [%d 100]", found, rank.MaxLength/2)
+	}
+	// The others are still rebalanced, each under their order lock.
+	if len(locked) != 3 {
+		t.Errorf("locked users %v, want 1, 2 and 3", locked)
+	}
+	if got := updated["[4 9 2]"]; len(got) != 3 || !(got[0] < got[1] && got[1] < got[2]) || len(got[2]) >= rank.MaxLength/2 {
+		t.Errorf("user 1's keys = %v, want three short ascending keys", got)
+	}
+	if got := updated["[7]"]; len(got) != 1 {
+		t.Errorf("user 3's keys = %v, want one", got)
+	}
+}
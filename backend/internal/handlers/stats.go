@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/stats"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultStatsDays is the length of a statistics range when from is not
+// given.
+const defaultStatsDays = 30
+
+// statsRange reads the from, to and bucket query parameters shared by the
+// statistics endpoints. Dates are inclusive and in the zone of today, which
+// is also the default for to. It writes the error response itself and
+// reports whether to continue.
+func statsRange(c *gin.Context, today time.Time) (stats.Range, bool) {
+	to := today
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			apperror.Respond(c, errInvalidDateRange.WithMessage("The to date must be in YYYY-MM-DD format"))
+			return stats.Range{}, false
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -(defaultStatsDays - 1))
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			apperror.Respond(c, errInvalidDateRange.WithMessage("The from date must be in YYYY-MM-DD format"))
+			return stats.Range{}, false
+		}
+		from = t
+	}
+
+	r, err := stats.NewRange(from, to, c.DefaultQuery("bucket", stats.BucketDay))
+	switch err {
+	case nil:
+		return r, true
+	case stats.ErrInvalidBucket:
+		apperror.Respond(c, errInvalidBucket)
+	case stats.ErrTooManyBuckets:
+		apperror.Respond(c, errInvalidDateRange.WithMessage("The range may span at most "+strconv.Itoa(stats.MaxBuckets)+" buckets; use a larger bucket"))
+	case stats.ErrInvalidRange:
+		apperror.Respond(c, errInvalidDateRange.WithMessage("The from date must not be after the to date"))
+	default:
+		apperror.Respond(c, apperror.Wrap(err, "Failed to compute statistics"))
+	}
+	return stats.Range{}, false
+}
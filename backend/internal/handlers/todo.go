@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"strconv"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/metrics"
 	"todo-app/backend/internal/middleware"
 	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/stats"
+	"todo-app/backend/internal/todostatus"
+	"todo-app/backend/internal/validation"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,20 +25,95 @@ func NewTodoHandler(db *sql.DB) *TodoHandler {
 	return &TodoHandler{DB: db}
 }
 
+// todoColumns are the todos columns returned by the todo endpoints, in the
+// order scanTodo expects, followed by the number of comments. They must be
+// selected from todos without an alias.
+const todoColumns = `id, user_id, title, description, status, completed, due_date, position, completed_at, created_at, updated_at,
+	(SELECT COUNT(*) FROM todo_comments WHERE todo_id = todos.id) AS comment_count`
+
+// scanTodo scans todoColumns, followed by the extra columns if any.
+func scanTodo(row interface{ Scan(...any) error }, todo *models.Todo, extra ...any) error {
+	var dueDate sql.NullTime
+	dest := append([]any{
+		&todo.ID, &todo.UserID, &todo.Title, &todo.Description, &todo.Status,
+		&todo.Completed, &dueDate, &todo.Position, &todo.CompletedAt, &todo.CreatedAt, &todo.UpdatedAt,
+		&todo.CommentCount,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	todo.DueDate = formatDueDate(dueDate)
+	return nil
+}
+
+// formatDueDate returns a due_date value as the API shows it.
+func formatDueDate(dueDate sql.NullTime) *string {
+	if !dueDate.Valid {
+		return nil
+	}
+	date := dueDate.Time.Format("2006-01-02")
+	return &date
+}
+
+// requestedStatus is the status a TodoRequest asks for, given the current
+// status ("" for a new todo).
+func requestedStatus(req models.TodoRequest, current string) string {
+	if req.Status != nil && *req.Status != "" {
+		return *req.Status
+	}
+	if current == "" {
+		current = todostatus.Todo
+	}
+	return todostatus.ForCompleted(current, req.Completed)
+}
+
+// lockTodoStatus returns the status of the user's todo and locks its row
+// until tx ends.
+func lockTodoStatus(ctx context.Context, tx *sql.Tx, todoID, userID int) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx,
+		"SELECT status FROM todos WHERE id = $1 AND user_id = $2 FOR UPDATE",
+		todoID, userID,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", errTodoNotFound
+	}
+	return status, err
+}
+
+func checkTransition(from, to string) error {
+	if !todostatus.CanTransition(from, to) {
+		return errStatusTransition.WithMessage("Cannot change status from " + from + " to " + to)
+	}
+	return nil
+}
+
 func (h *TodoHandler) GetTodos(c *gin.Context) {
+	ctx := c.Request.Context()
 	userCtx, ok := middleware.GetUserFromGinContext(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apperror.Respond(c, apperror.ErrUnauthorized)
 		return
 	}
 
-	rows, err := h.DB.Query(
-		`SELECT id, user_id, title, description, completed, created_at, updated_at
-		 FROM todos WHERE user_id = $1 ORDER BY created_at DESC`,
+	var order string
+	switch c.DefaultQuery("sort", "created_at") {
+	case "created_at":
+		order = "created_at DESC"
+	case "position":
+		order = todoOrder
+	default:
+		apperror.Respond(c, errInvalidSort)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT `+todoColumns+`
+		 FROM todos WHERE user_id = $1 ORDER BY `+order,
 		userCtx.UserID,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch todos"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch todos"))
 		return
 	}
 	defer rows.Close()
@@ -39,12 +121,8 @@ func (h *TodoHandler) GetTodos(c *gin.Context) {
 	var todos []models.Todo
 	for rows.Next() {
 		var todo models.Todo
-		err := rows.Scan(
-			&todo.ID, &todo.UserID, &todo.Title, &todo.Description,
-			&todo.Completed, &todo.CreatedAt, &todo.UpdatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan todo"})
+		if err := scanTodo(rows, &todo); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan todo"))
 			return
 		}
 		todos = append(todos, todo)
@@ -58,34 +136,32 @@ func (h *TodoHandler) GetTodos(c *gin.Context) {
 }
 
 func (h *TodoHandler) GetTodo(c *gin.Context) {
+	ctx := c.Request.Context()
 	userCtx, ok := middleware.GetUserFromGinContext(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apperror.Respond(c, apperror.ErrUnauthorized)
 		return
 	}
 
 	todoID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo ID"})
+		apperror.Respond(c, errInvalidTodoID)
 		return
 	}
 
 	var todo models.Todo
-	err = h.DB.QueryRow(
-		`SELECT id, user_id, title, description, completed, created_at, updated_at
+	err = scanTodo(h.DB.QueryRowContext(ctx,
+		`SELECT `+todoColumns+`
 		 FROM todos WHERE id = $1 AND user_id = $2`,
 		todoID, userCtx.UserID,
-	).Scan(
-		&todo.ID, &todo.UserID, &todo.Title, &todo.Description,
-		&todo.Completed, &todo.CreatedAt, &todo.UpdatedAt,
-	)
+	), &todo)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+		apperror.Respond(c, errTodoNotFound)
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch todo"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch todo"))
 		return
 	}
 
@@ -93,112 +169,311 @@ func (h *TodoHandler) GetTodo(c *gin.Context) {
 }
 
 func (h *TodoHandler) CreateTodo(c *gin.Context) {
+	ctx := c.Request.Context()
 	userCtx, ok := middleware.GetUserFromGinContext(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apperror.Respond(c, apperror.ErrUnauthorized)
 		return
 	}
 
 	var req models.TodoRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
-	}
-
-	if req.Title == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
 		return
 	}
 
+	// completed and completed_at follow the status through the
+	// todos_sync_status trigger. A new todo is not a status change, so
+	// checkWIPLimit does not apply.
 	var todo models.Todo
-	err := h.DB.QueryRow(
-		`INSERT INTO todos (user_id, title, description, completed)
-		 VALUES ($1, $2, $3, $4)
-		 RETURNING id, user_id, title, description, completed, created_at, updated_at`,
-		userCtx.UserID, req.Title, req.Description, req.Completed,
-	).Scan(
-		&todo.ID, &todo.UserID, &todo.Title, &todo.Description,
-		&todo.Completed, &todo.CreatedAt, &todo.UpdatedAt,
-	)
-
+	err := scanTodo(h.DB.QueryRowContext(ctx,
+		`INSERT INTO todos (user_id, title, description, status, due_date)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, '')::date)
+		 RETURNING `+todoColumns,
+		userCtx.UserID, req.Title, req.Description, requestedStatus(req, ""), req.DueDate,
+	), &todo)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create todo"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to create todo"))
 		return
 	}
 
+	metrics.TodosCreated.Inc()
+	if todo.Completed {
+		metrics.TodosCompleted.Inc()
+	}
+
 	c.JSON(http.StatusCreated, todo)
 }
 
 func (h *TodoHandler) UpdateTodo(c *gin.Context) {
+	ctx := c.Request.Context()
 	userCtx, ok := middleware.GetUserFromGinContext(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apperror.Respond(c, apperror.ErrUnauthorized)
 		return
 	}
 
 	todoID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo ID"})
+		apperror.Respond(c, errInvalidTodoID)
 		return
 	}
 
 	var req models.TodoRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
 		return
 	}
 
-	var todo models.Todo
-	err = h.DB.QueryRow(
-		`UPDATE todos
-		 SET title = $1, description = $2, completed = $3, updated_at = CURRENT_TIMESTAMP
-		 WHERE id = $4 AND user_id = $5
-		 RETURNING id, user_id, title, description, completed, created_at, updated_at`,
-		req.Title, req.Description, req.Completed, todoID, userCtx.UserID,
-	).Scan(
-		&todo.ID, &todo.UserID, &todo.Title, &todo.Description,
-		&todo.Completed, &todo.CreatedAt, &todo.UpdatedAt,
-	)
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update todo"))
+		return
+	}
+	defer tx.Rollback()
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+	// The order lock serialises status changes for checkWIPLimit, and is
+	// taken first as in MoveCard. The row stays locked until commit, so the
+	// transition is checked against the status it actually replaces.
+	if err := lockTodoOrder(ctx, tx, userCtx.UserID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update todo"))
 		return
 	}
+	current, err := lockTodoStatus(ctx, tx, todoID, userCtx.UserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update todo"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update todo"))
+		return
+	}
+	status := requestedStatus(req, current)
+	if err := checkTransition(current, status); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+	if status != current {
+		if err := checkWIPLimit(ctx, tx, userCtx.UserID, status); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to update todo"))
+			return
+		}
+	}
+
+	var todo models.Todo
+	err = scanTodo(tx.QueryRowContext(ctx,
+		`UPDATE todos
+		 SET title = $1, description = $2, status = $3,
+		     due_date = CASE WHEN $4 THEN NULLIF($5, '')::date ELSE due_date END,
+		     updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $6
+		 RETURNING `+todoColumns,
+		req.Title, req.Description, status, req.DueDateSet, req.DueDate, todoID,
+	), &todo)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update todo"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to update todo"))
 		return
 	}
 
+	if status == todostatus.Done && current != todostatus.Done {
+		metrics.TodosCompleted.Inc()
+	}
+
 	c.JSON(http.StatusOK, todo)
 }
 
 func (h *TodoHandler) DeleteTodo(c *gin.Context) {
+	ctx := c.Request.Context()
 	userCtx, ok := middleware.GetUserFromGinContext(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apperror.Respond(c, apperror.ErrUnauthorized)
 		return
 	}
 
 	todoID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid todo ID"})
+		apperror.Respond(c, errInvalidTodoID)
 		return
 	}
 
-	result, err := h.DB.Exec(
+	result, err := h.DB.ExecContext(ctx,
 		"DELETE FROM todos WHERE id = $1 AND user_id = $2",
 		todoID, userCtx.UserID,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete todo"})
+		apperror.Respond(c, apperror.Wrap(err, "Failed to delete todo"))
 		return
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil || rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+		apperror.Respond(c, errTodoNotFound)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Todo deleted successfully"})
 }
+
+// MoveTodo changes the manual order of the user's todos. Only the moved
+// todo's position is written, unless the list has to be rebalanced.
+func (h *TodoHandler) MoveTodo(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidTodoID)
+		return
+	}
+
+	var req models.MoveTodoRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+	if req.AfterID == nil && req.BeforeID == nil {
+		apperror.Respond(c, errInvalidMove)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move todo"))
+		return
+	}
+	defer tx.Rollback()
+
+	if err := lockTodoOrder(ctx, tx, userCtx.UserID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move todo"))
+		return
+	}
+	if _, err := lockTodoStatus(ctx, tx, todoID, userCtx.UserID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move todo"))
+		return
+	}
+	if err := placeTodo(ctx, tx, userCtx.UserID, todoID, req.AfterID, req.BeforeID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move todo"))
+		return
+	}
+
+	var todo models.Todo
+	err = scanTodo(tx.QueryRowContext(ctx,
+		`SELECT `+todoColumns+` FROM todos WHERE id = $1`,
+		todoID,
+	), &todo)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move todo"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to move todo"))
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
+
+// GetStats returns the user's productivity statistics. from, to and bucket
+// work as for GET /api/admin/stats, except that dates are in the user's time
+// zone and default to the user's last 30 days.
+func (h *TodoHandler) GetStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var timezone string
+	err := h.DB.QueryRowContext(ctx, "SELECT timezone FROM users WHERE id = $1", userCtx.UserID).Scan(&timezone)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch settings"))
+		return
+	}
+	// Time zones are validated when saved, but the zone database may have
+	// dropped one since; fall back to UTC rather than failing.
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	r, ok := statsRange(c, time.Now().In(loc))
+	if !ok {
+		return
+	}
+
+	userStats, err := stats.ForUser(ctx, h.DB, userCtx.UserID, loc, r)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to compute statistics"))
+		return
+	}
+
+	c.JSON(http.StatusOK, userStats)
+}
+
+// GetTodoHistory lists the status changes of a todo, oldest first. The
+// first entry records the status it was created with.
+func (h *TodoHandler) GetTodoHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Respond(c, errInvalidTodoID)
+		return
+	}
+
+	var exists bool
+	err = h.DB.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM todos WHERE id = $1 AND user_id = $2)",
+		todoID, userCtx.UserID,
+	).Scan(&exists)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch todo"))
+		return
+	}
+	if !exists {
+		apperror.Respond(c, errTodoNotFound)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT id, from_status, to_status, changed_at
+		 FROM todo_status_history WHERE todo_id = $1
+		 ORDER BY id`,
+		todoID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch status history"))
+		return
+	}
+	defer rows.Close()
+
+	changes := []models.TodoStatusChange{}
+	for rows.Next() {
+		var change models.TodoStatusChange
+		if err := rows.Scan(&change.ID, &change.FromStatus, &change.ToStatus, &change.ChangedAt); err != nil {
+			apperror.Respond(c, apperror.Wrap(err, "Failed to scan status history"))
+			return
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to fetch status history"))
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+)
+
+var todoColumnNames = []string{
+	"id", "user_id", "title", "description", "status", "completed", "due_date",
+	"position", "completed_at", "created_at", "updated_at", "comment_count",
+}
+
+var testTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// todoRow is a todoColumns row for one of user 1's todos.
+func todoRow(id int64, status string, dueDate driver.Value) []driver.Value {
+	return []driver.Value{id, int64(1), "Todo", "", status, status == "done", dueDate, nil, nil, testTime, testTime, int64(0)}
+}
+
+func TestUpdateTodoDueDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		set     bool
+		dueDate driver.Value
+	}{
+		{"omitted keeps the due date", `{"title": "Todo"}`, http.StatusOK, false, nil},
+		{"null clears it", `{"title": "Todo", "due_date": null}`, http.StatusOK, true, nil},
+		{"empty clears it", `{"title": "Todo", "due_date": ""}`, http.StatusOK, true, ""},
+		{"date replaces it", `{"title": "Todo", "due_date": " 2026-03-01 "}`, http.StatusOK, true, "2026-03-01"},
+		{"invalid date", `{"title": "Todo", "due_date": "tomorrow"}`, http.StatusBadRequest, false, nil},
+		{"wrong type", `{"title": "Todo", "due_date": 20260301}`, http.StatusBadRequest, false, nil},
+		{"unknown field", `{"title": "Todo", "due": "2026-03-01"}`, http.StatusBadRequest, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := sqltest.New(t)
+			fake.On("FROM users WHERE id = $1 FOR UPDATE", func(args []driver.Value) sqltest.Result {
+				return sqltest.Row([]string{"id"}, int64(1))
+			})
+			fake.On("SELECT status FROM todos", func(args []driver.Value) sqltest.Result {
+				return sqltest.Row([]string{"status"}, "todo")
+			})
+			var updateArgs []driver.Value
+			fake.On("UPDATE todos", func(args []driver.Value) sqltest.Result {
+				updateArgs = args
+				return sqltest.Row(todoColumnNames, todoRow(5, "todo", testTime)...)
+			})
+
+			h := NewTodoHandler(db)
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.PUT("/api/todos/:id", withUser(1, h.UpdateTodo))
+
+			w := serveJSON(r, http.MethodPut, "/api/todos/5", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				if updateArgs != nil {
+					t.Error("todo updated despite the invalid body")
+				}
+				if tt.name != "unknown field" && !strings.Contains(w.Body.String(), `"due_date"`) {
+					t.Errorf("body = %s, want an error on due_date", w.Body.String())
+				}
+				return
+			}
+			// $4 says whether to write the due date, $5 is the value.
+			if updateArgs[3] != tt.set || updateArgs[4] != tt.dueDate {
+				t.Errorf("due date args = %v, %v, want %v, %v", updateArgs[3], updateArgs[4], tt.set, tt.dueDate)
+			}
+		})
+	}
+}
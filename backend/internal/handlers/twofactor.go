@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/logging"
+	"todo-app/backend/internal/metrics"
+	"todo-app/backend/internal/middleware"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/ratelimit"
+	"todo-app/backend/internal/totp"
+	"todo-app/backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+)
+
+const qrCodeSize = 256
+
+type TwoFactorHandler struct {
+	DB      *sql.DB
+	Issuer  string
+	Limiter *ratelimit.Limiter
+}
+
+func NewTwoFactorHandler(db *sql.DB, issuer string, limiter *ratelimit.Limiter) *TwoFactorHandler {
+	return &TwoFactorHandler{DB: db, Issuer: issuer, Limiter: limiter}
+}
+
+// Setup starts enrollment by generating a new secret. The secret is stored
+// but not enforced until Enable confirms the user can produce a valid code.
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var email string
+	var enabled bool
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT email, totp_enabled FROM users WHERE id = $1",
+		userCtx.UserID,
+	).Scan(&email, &enabled)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	if enabled {
+		apperror.Respond(c, errTwoFactorEnabled)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate secret"))
+		return
+	}
+
+	_, err = h.DB.ExecContext(ctx,
+		`UPDATE users SET totp_secret = $1, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2`,
+		secret, userCtx.UserID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to start two-factor setup"))
+		return
+	}
+
+	uri := totp.URI(h.Issuer, email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate QR code"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: uri,
+		QRCode:     base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// QRCode serves the pending enrollment as a PNG for clients that would
+// rather use an <img> tag than decode the base64 payload from Setup.
+func (h *TwoFactorHandler) QRCode(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var email string
+	var secret sql.NullString
+	var enabled bool
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT email, totp_secret, totp_enabled FROM users WHERE id = $1",
+		userCtx.UserID,
+	).Scan(&email, &secret, &enabled)
+	if err != nil && err != sql.ErrNoRows {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	if err == sql.ErrNoRows || enabled || !secret.Valid {
+		apperror.Respond(c, errNoPendingTwoFactor)
+		return
+	}
+
+	png, err := qrcode.Encode(totp.URI(h.Issuer, email, secret.String), qrcode.Medium, qrCodeSize)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate QR code"))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// Enable confirms enrollment with a code from the authenticator and returns
+// the recovery codes. They are only ever shown here.
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	var secret sql.NullString
+	var enabled bool
+	var lastStep int64
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = $1",
+		userCtx.UserID,
+	).Scan(&secret, &enabled, &lastStep)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	if enabled {
+		apperror.Respond(c, errTwoFactorEnabled)
+		return
+	}
+	if !secret.Valid {
+		apperror.Respond(c, errTwoFactorNotStarted)
+		return
+	}
+
+	step, ok := totp.Validate(secret.String, req.Code, time.Now(), lastStep)
+	if !ok {
+		apperror.Respond(c, errInvalidCode)
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate recovery codes"))
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE users SET totp_enabled = TRUE, totp_last_step = $1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2`,
+		step, userCtx.UserID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to enable two-factor authentication"))
+		return
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userCtx.UserID, codes); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to store recovery codes"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to enable two-factor authentication"))
+		return
+	}
+
+	token, err := middleware.GenerateToken(userCtx.UserID, userCtx.IsAdmin, middleware.WithMFA())
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+		"token":          token,
+	})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	if !h.verifyCode(c, userCtx.UserID, req.Code) {
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate recovery codes"))
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userCtx.UserID, codes); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to store recovery codes"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to store recovery codes"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	ctx := c.Request.Context()
+	userCtx, ok := middleware.GetUserFromGinContext(c)
+	if !ok {
+		apperror.Respond(c, apperror.ErrUnauthorized)
+		return
+	}
+
+	var req models.TwoFactorDisableRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	var password string
+	err := h.DB.QueryRowContext(ctx, "SELECT password FROM users WHERE id = $1", userCtx.UserID).Scan(&password)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errUserNotFound)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+
+	if !middleware.CheckPassword(req.Password, password) {
+		apperror.Respond(c, errIncorrectPassword)
+		return
+	}
+
+	if !h.verifyCode(c, userCtx.UserID, req.Code) {
+		return
+	}
+
+	if err := resetTwoFactor(ctx, h.DB, userCtx.UserID); err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to disable two-factor authentication"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// Login completes a login that AuthHandler.Login answered with a challenge.
+// Either a TOTP code or an unused recovery code is accepted.
+func (h *TwoFactorHandler) Login(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.TwoFactorLoginRequest
+	if err := validation.Bind(c, &req); err != nil {
+		apperror.Respond(c, err)
+		return
+	}
+
+	userID, err := middleware.ParseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		apperror.Respond(c, errInvalidChallenge)
+		return
+	}
+
+	var verified bool
+	if req.RecoveryCode != "" {
+		verified = h.useRecoveryCode(c, userID, req.RecoveryCode)
+	} else {
+		verified = h.verifyCode(c, userID, req.Code)
+	}
+	if !verified {
+		metrics.Login("totp", metrics.LoginFailed)
+		return
+	}
+
+	var user models.User
+	err = h.DB.QueryRowContext(ctx,
+		"SELECT id, email, is_admin, must_change_password, totp_enabled, suspended_at FROM users WHERE id = $1",
+		userID,
+	).Scan(&user.ID, &user.Email, &user.IsAdmin, &user.MustChangePassword, &user.TwoFactorEnabled, &user.SuspendedAt)
+	if err == sql.ErrNoRows {
+		apperror.Respond(c, errInvalidChallenge)
+		return
+	}
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return
+	}
+	// The account may have been suspended after the challenge was issued.
+	if user.SuspendedAt != nil {
+		metrics.Login("totp", metrics.LoginFailed)
+		apperror.Respond(c, errAccountSuspended)
+		return
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.IsAdmin, middleware.WithMFA())
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Failed to generate token"))
+		return
+	}
+
+	metrics.Login("totp", metrics.LoginSucceeded)
+	c.JSON(http.StatusOK, models.LoginResponse{
+		Token: token,
+		User:  user,
+	})
+}
+
+// verifyCode checks a TOTP code for an enrolled user and records the matched
+// step. It writes the error response itself and reports whether to continue.
+// Six-digit codes are only safe against guessing if attempts are capped, so
+// failures count towards a per-user lockout.
+func (h *TwoFactorHandler) verifyCode(c *gin.Context, userID int, code string) bool {
+	ctx := c.Request.Context()
+	if !h.checkLockout(c, userID) {
+		return false
+	}
+
+	var secret sql.NullString
+	var enabled bool
+	var lastStep int64
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = $1",
+		userID,
+	).Scan(&secret, &enabled, &lastStep)
+	if err != nil && err != sql.ErrNoRows {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return false
+	}
+	if err == sql.ErrNoRows || !enabled || !secret.Valid {
+		apperror.Respond(c, errTwoFactorNotEnabled)
+		return false
+	}
+
+	step, ok := totp.Validate(secret.String, code, time.Now(), lastStep)
+	if !ok {
+		h.recordResult(c, userID, false)
+		apperror.Respond(c, errInvalidCode)
+		return false
+	}
+
+	// The guard on totp_last_step makes concurrent use of the same code fail.
+	result, err := h.DB.ExecContext(ctx,
+		"UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1",
+		step, userID,
+	)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return false
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		apperror.Respond(c, errInvalidCode)
+		return false
+	}
+
+	h.recordResult(c, userID, true)
+	return true
+}
+
+func (h *TwoFactorHandler) useRecoveryCode(c *gin.Context, userID int, code string) bool {
+	if !h.checkLockout(c, userID) {
+		return false
+	}
+
+	used, err := useRecoveryCode(c.Request.Context(), h.DB, userID, code)
+	if err != nil {
+		apperror.Respond(c, apperror.Wrap(err, "Internal server error"))
+		return false
+	}
+	if !used {
+		h.recordResult(c, userID, false)
+		apperror.Respond(c, errInvalidRecoveryCode)
+		return false
+	}
+
+	h.recordResult(c, userID, true)
+	return true
+}
+
+func (h *TwoFactorHandler) checkLockout(c *gin.Context, userID int) bool {
+	wait, err := h.Limiter.CheckAccount(c.Request.Context(), ratelimit.TwoFactorKey(userID))
+	if err != nil {
+		logging.FromContext(c).Error("Rate limit store error", "error", err)
+	}
+	if wait > 0 {
+		middleware.AbortTooManyRequests(c, wait)
+		return false
+	}
+	return true
+}
+
+func (h *TwoFactorHandler) recordResult(c *gin.Context, userID int, success bool) {
+	ctx := c.Request.Context()
+	key := ratelimit.TwoFactorKey(userID)
+
+	var err error
+	if success {
+		err = h.Limiter.Success(ctx, key)
+	} else {
+		_, err = h.Limiter.Failure(ctx, key)
+	}
+	if err != nil {
+		logging.FromContext(c).Error("Rate limit store error", "error", err)
+	}
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userID, totp.HashRecoveryCode(code),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func useRecoveryCode(ctx context.Context, db *sql.DB, userID int, code string) (bool, error) {
+	result, err := db.ExecContext(ctx,
+		`UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, totp.HashRecoveryCode(code),
+	)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func resetTwoFactor(ctx context.Context, db *sql.DB, userID int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE users
+		 SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $1`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"todo-app/backend/internal/sqltest"
+	"todo-app/backend/internal/totp"
+)
+
+func TestUseRecoveryCodeIsSingleUse(t *testing.T) {
+	fake, db := sqltest.New(t)
+	codes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// user_recovery_codes for user 1: hash -> used.
+	used := map[string]bool{totp.HashRecoveryCode(codes[0]): false}
+	fake.On("UPDATE user_recovery_codes SET used_at", func(args []driver.Value) sqltest.Result {
+		hash := args[1].(string)
+		if args[0].(int64) != 1 {
+			return sqltest.Result{}
+		}
+		if wasUsed, ok := used[hash]; !ok || wasUsed {
+			return sqltest.Result{}
+		}
+		used[hash] = true
+		return sqltest.Result{RowsAffected: 1}
+	})
+
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		userID int
+		code   string
+		want   bool
+	}{
+		{"another user's code", 2, codes[0], false},
+		{"first use", 1, codes[0], true},
+		{"second use", 1, codes[0], false},
+		{"unknown code", 1, codes[1], false},
+	}
+	for _, tt := range tests {
+		ok, err := useRecoveryCode(ctx, db, tt.userID, tt.code)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ok != tt.want {
+			t.Errorf("%s: useRecoveryCode = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"todo-app/backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// CheckFunc reports whether a dependency is usable. It must respect ctx.
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of one check as served to the public. Errors
+// can name hosts, ports and SQL states, so they are only logged.
+type CheckResult struct {
+	Status string `json:"status"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker serves the liveness and readiness endpoints. Liveness only shows
+// that the process can answer requests; readiness runs every registered
+// check and fails while the server is starting or shutting down.
+type Checker struct {
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []check
+	ready  atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{Timeout: timeout}
+}
+
+func (h *Checker) Register(name string, fn CheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, check{name: name, fn: fn})
+}
+
+// SetReady marks whether the server should receive traffic. It is cleared at
+// the start of a graceful shutdown so load balancers stop routing here before
+// in-flight requests are drained.
+func (h *Checker) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Check runs all registered checks concurrently, each bounded by Timeout.
+func (h *Checker) Check(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+func (h *Checker) run(ctx context.Context, c check) CheckResult {
+	checkCtx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := c.fn(checkCtx); err != nil {
+		logging.FromContext(ctx).Warn("Health check failed",
+			"check", c.name, "error", err, "duration_ms", time.Since(start).Milliseconds())
+		return CheckResult{Status: StatusUnavailable}
+	}
+	return CheckResult{Status: StatusOK}
+}
+
+func (h *Checker) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, Report{Status: StatusOK})
+}
+
+func (h *Checker) Readiness(c *gin.Context) {
+	if !h.ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, Report{
+			Status: StatusUnavailable,
+			Checks: map[string]CheckResult{
+				"server": {Status: StatusUnavailable},
+			},
+		})
+		return
+	}
+
+	report := h.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// Ping checks that the database accepts connections.
+func Ping(db *sql.DB) CheckFunc {
+	return db.PingContext
+}
+
+// HTTP checks that url answers GET with a 2xx status.
+func HTTP(client *http.Client, url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
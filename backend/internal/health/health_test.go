@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(h *Checker) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/healthz", h.Liveness)
+	r.GET("/readyz", h.Readiness)
+	return r
+}
+
+func get(r http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestChecksRunConcurrently(t *testing.T) {
+	h := NewChecker(time.Second)
+	// Each check waits for the other to start, so they only pass when run
+	// at the same time.
+	a, b := make(chan struct{}), make(chan struct{})
+	wait := func(started, other chan struct{}) CheckFunc {
+		return func(ctx context.Context) error {
+			close(started)
+			select {
+			case <-other:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	h.Register("a", wait(a, b))
+	h.Register("b", wait(b, a))
+
+	report := h.Check(context.Background())
+	if report.Status != StatusOK {
+		t.Errorf("status = %s, want ok: %+v", report.Status, report.Checks)
+	}
+}
+
+func TestCheckTimesOut(t *testing.T) {
+	h := NewChecker(50 * time.Millisecond)
+	h.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h.Register("fast", func(ctx context.Context) error { return nil })
+
+	start := time.Now()
+	report := h.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Check took %v with a 50ms timeout", elapsed)
+	}
+	if report.Status != StatusUnavailable {
+		t.Errorf("status = %s, want unavailable", report.Status)
+	}
+	if got := report.Checks["slow"].Status; got != StatusUnavailable {
+		t.Errorf("slow check = %s, want unavailable", got)
+	}
+	if got := report.Checks["fast"].Status; got != StatusOK {
+		t.Errorf("fast check = %s, want ok", got)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	secret := "dial tcp 10.0.0.5:5432: connection refused"
+	tests := []struct {
+		name   string
+		ready  bool
+		err    error
+		status int
+	}{
+		{"ready", true, nil, http.StatusOK},
+		{"failing check", true, errors.New(secret), http.StatusServiceUnavailable},
+		{"shutting down", false, nil, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewChecker(time.Second)
+			h.Register("database", func(ctx context.Context) error { return tt.err })
+			h.SetReady(tt.ready)
+
+			w := get(newTestRouter(h), "/readyz")
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if body := w.Body.String(); strings.Contains(body, "10.0.0.5") || strings.Contains(body, "refused") {
+				t.Errorf("error detail leaked: %s", body)
+			}
+		})
+	}
+}
+
+func TestLivenessIgnoresChecks(t *testing.T) {
+	h := NewChecker(time.Second)
+	h.Register("database", func(ctx context.Context) error { return errors.New("down") })
+
+	if w := get(newTestRouter(h), "/healthz"); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	if err := HTTP(srv.Client(), srv.URL+"/healthz")(ctx); err != nil {
+		t.Errorf("healthy endpoint: %v", err)
+	}
+	if err := HTTP(srv.Client(), srv.URL+"/broken")(ctx); err == nil {
+		t.Error("500 reported as healthy")
+	}
+}
@@ -0,0 +1,189 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Hook is a component with startup and shutdown steps. Either may be nil.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Manager starts hooks in the order they were appended and stops them in
+// reverse, so a component is always stopped before the things it depends on.
+type Manager struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started int
+	errc    chan error
+}
+
+func New() *Manager {
+	return &Manager{errc: make(chan error, 1)}
+}
+
+func (m *Manager) Append(h Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, h)
+}
+
+// Start runs each OnStart in order. If one fails, the hooks already started
+// are stopped before the error is returned.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	for i, h := range hooks {
+		if h.OnStart != nil {
+			if err := h.OnStart(ctx); err != nil {
+				m.setStarted(i)
+				stopErr := m.Stop(ctx)
+				return errors.Join(fmt.Errorf("start %s: %w", h.Name, err), stopErr)
+			}
+		}
+		slog.Info("Started", "component", h.Name)
+	}
+	m.setStarted(len(hooks))
+	return nil
+}
+
+// Stop runs OnStop for every started hook in reverse order, continuing past
+// failures. Each hook shares the deadline on ctx.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := m.hooks[:m.started]
+	m.started = 0
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if h.OnStop == nil {
+			continue
+		}
+		if err := h.OnStop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", h.Name, err))
+			continue
+		}
+		slog.Info("Stopped", "component", h.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// Fail reports that a running component has stopped unexpectedly, which
+// makes Run shut everything down.
+func (m *Manager) Fail(err error) {
+	select {
+	case m.errc <- err:
+	default:
+	}
+}
+
+// Run starts all hooks, blocks until ctx is cancelled (typically by a
+// signal) or a component fails, then stops everything within
+// shutdownTimeout.
+func (m *Manager) Run(ctx context.Context, shutdownTimeout time.Duration) error {
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutting down", "timeout", shutdownTimeout.String())
+	case runErr = <-m.errc:
+		slog.Error("Shutting down after component failure", "error", runErr)
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return errors.Join(runErr, m.Stop(stopCtx))
+}
+
+func (m *Manager) setStarted(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = n
+}
+
+// Server returns a hook that serves srv. The listener is opened in OnStart so
+// that bind errors stop startup; OnStop waits for in-flight requests to
+// finish until the shutdown deadline.
+func Server(m *Manager, name string, srv *http.Server) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					m.Fail(fmt.Errorf("%s: %w", name, err))
+				}
+			}()
+			slog.Info("Listening", "component", name, "addr", ln.Addr().String())
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return srv.Shutdown(ctx)
+		},
+	}
+}
+
+// Worker returns a hook that calls fn on its own goroutine, once right away
+// and then every interval, so that a deploy does not hold up the work for a
+// whole interval. OnStop cancels the context passed to fn and waits for the
+// current run to return.
+func Worker(name string, interval time.Duration, fn func(ctx context.Context) error) Hook {
+	var cancel context.CancelFunc
+	done := make(chan struct{})
+
+	return Hook{
+		Name: name,
+		OnStart: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			run := func() {
+				if err := fn(ctx); err != nil && ctx.Err() == nil {
+					slog.Error("Worker run failed", "component", name, "error", err)
+				}
+			}
+			go func() {
+				defer close(done)
+				run()
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						run()
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
@@ -0,0 +1,219 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recorder keeps the order in which hooks ran.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) hook(name string, startErr error) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(context.Context) error {
+			r.add("start " + name)
+			return startErr
+		},
+		OnStop: func(context.Context) error {
+			r.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestStartAndStopOrder(t *testing.T) {
+	var r recorder
+	m := New()
+	m.Append(r.hook("db", nil))
+	m.Append(r.hook("worker", nil))
+	m.Append(Hook{Name: "no-op"})
+	m.Append(r.hook("server", nil))
+
+	ctx := context.Background()
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"start db", "start worker", "start server", "stop server", "stop worker", "stop db"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %v, want %v", r.events, want)
+	}
+
+	// A second Stop has nothing left to stop.
+	r.events = nil
+	if err := m.Stop(ctx); err != nil || len(r.events) != 0 {
+		t.Errorf("second Stop ran %v, err %v", r.events, err)
+	}
+}
+
+func TestStartRollsBackOnFailure(t *testing.T) {
+	var r recorder
+	errBind := errors.New("address in use")
+	m := New()
+	m.Append(r.hook("db", nil))
+	m.Append(r.hook("worker", nil))
+	m.Append(r.hook("server", errBind))
+	m.Append(r.hook("metrics", nil))
+
+	err := m.Start(context.Background())
+	if !errors.Is(err, errBind) {
+		t.Fatalf("Start = %v, want %v", err, errBind)
+	}
+
+	// The failed hook is not stopped, and later hooks never start.
+	want := []string{"start db", "start worker", "start server", "stop worker", "stop db"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %v, want %v", r.events, want)
+	}
+}
+
+func TestStopContinuesPastFailures(t *testing.T) {
+	var r recorder
+	errStop := errors.New("flush failed")
+	m := New()
+	m.Append(r.hook("db", nil))
+	m.Append(Hook{Name: "tracer", OnStop: func(context.Context) error { return errStop }})
+	m.Append(r.hook("server", nil))
+
+	ctx := context.Background()
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(ctx); !errors.Is(err, errStop) {
+		t.Errorf("Stop = %v, want %v", err, errStop)
+	}
+	want := []string{"start db", "start server", "stop server", "stop db"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %v, want %v", r.events, want)
+	}
+}
+
+func TestRunStopsOnComponentFailure(t *testing.T) {
+	var r recorder
+	errCrash := errors.New("listener closed")
+	m := New()
+	m.Append(r.hook("db", nil))
+	m.Append(Hook{Name: "server", OnStart: func(context.Context) error {
+		m.Fail(errCrash)
+		return nil
+	}})
+
+	if err := m.Run(context.Background(), time.Second); !errors.Is(err, errCrash) {
+		t.Errorf("Run = %v, want %v", err, errCrash)
+	}
+	if want := []string{"start db", "stop db"}; !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %v, want %v", r.events, want)
+	}
+}
+
+func TestWorkerRunsAtStart(t *testing.T) {
+	ran := make(chan struct{}, 1)
+	h := Worker("test", time.Hour, func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+
+	ctx := context.Background()
+	if err := h.OnStart(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer h.OnStop(ctx)
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not run before its first interval")
+	}
+}
+
+func TestWorkerRunsEveryInterval(t *testing.T) {
+	var runs atomic.Int32
+	ran := make(chan struct{}, 10)
+	h := Worker("test", 10*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	if err := h.OnStart(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		<-ran
+	}
+
+	if err := h.OnStop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	n := runs.Load()
+	time.Sleep(50 * time.Millisecond)
+	if runs.Load() != n {
+		t.Error("worker ran after OnStop returned")
+	}
+}
+
+func TestWorkerStopCancelsRun(t *testing.T) {
+	started := make(chan struct{})
+	var cancelled atomic.Bool
+	h := Worker("test", time.Hour, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		cancelled.Store(true)
+		return ctx.Err()
+	})
+
+	ctx := context.Background()
+	if err := h.OnStart(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := h.OnStop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !cancelled.Load() {
+		t.Error("OnStop returned before the run finished")
+	}
+}
+
+func TestWorkerStopHonoursDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	h := Worker("test", time.Hour, func(ctx context.Context) error {
+		close(started)
+		// Ignores cancellation.
+		<-release
+		return nil
+	})
+
+	if err := h.OnStart(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := h.OnStop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("OnStop = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
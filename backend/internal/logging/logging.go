@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never written. Matching
+// is case-insensitive and on substrings, so "new_password" and
+// "Authorization" are both caught.
+var sensitiveKeys = []string{
+	"password", "authorization", "cookie", "secret", "token", "recovery_code",
+}
+
+type Config struct {
+	// Level is debug, info, warn or error.
+	Level string
+	// Format is FormatJSON or FormatText.
+	Format string
+}
+
+// New builds a logger writing to w. Values of sensitive attributes are
+// replaced with [REDACTED], including inside groups.
+func New(w io.Writer, cfg Config) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+	switch cfg.Format {
+	case FormatJSON, "":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// IsSensitive reports whether values under key must not be logged.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// loggerKey is the gin context key holding the per-request logger.
+const loggerKey = "logger"
+
+// WithContext returns a copy of ctx carrying logger.
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or the default logger. A
+// *gin.Context is accepted too, in which case the per-request logger set by
+// RequestID is returned.
+func FromContext(ctx context.Context) *slog.Logger {
+	if c, ok := ctx.(*gin.Context); ok {
+		if logger, ok := c.Get(loggerKey); ok {
+			return logger.(*slog.Logger)
+		}
+		ctx = c.Request.Context()
+	}
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With adds attributes to the per-request logger of c, so that every later
+// line for the request, including the access log, carries them.
+func With(c *gin.Context, args ...any) {
+	logger := FromContext(c).With(args...)
+	c.Set(loggerKey, logger)
+	c.Request = c.Request.WithContext(WithContext(c.Request.Context(), logger))
+}
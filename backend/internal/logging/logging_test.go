@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIsSensitive(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"password", true},
+		{"new_password", true},
+		{"Authorization", true},
+		{"X-Confirmation-Token", true},
+		{"token", true},
+		{"recovery_code", true},
+		{"jwt_secret", true},
+		{"Cookie", true},
+		{"email", false},
+		{"user_id", false},
+		{"request_id", false},
+		{"recovery", false},
+	}
+	for _, tt := range tests {
+		if got := IsSensitive(tt.key); got != tt.want {
+			t.Errorf("IsSensitive(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerRedacts(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatText} {
+		var buf bytes.Buffer
+		logger, err := New(&buf, Config{Level: "debug", Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("login",
+			"email", "alice@example.com",
+			"password", "hunter2",
+			slog.Group("request", "Authorization", "Bearer abc.def", "recovery_code", "abcde-fghjk"),
+			"token", "tok-123",
+		)
+
+		out := buf.String()
+		for _, secret := range []string{"hunter2", "abc.def", "abcde-fghjk", "tok-123"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%s: %q logged: %s", format, secret, out)
+			}
+		}
+		if !strings.Contains(out, "alice@example.com") {
+			t.Errorf("%s: non-sensitive value missing: %s", format, out)
+		}
+		if strings.Count(out, redacted) != 4 {
+			t.Errorf("%s: want 4 redacted values: %s", format, out)
+		}
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	for _, cfg := range []Config{{Level: "verbose", Format: FormatJSON}, {Level: "info", Format: "xml"}} {
+		if _, err := New(&bytes.Buffer{}, cfg); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"abc-123_DEF.456", true},
+		{"0b0e4bcf-0c4f-4d3c-9d0f-3a6a2f0d7c1e", true},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{`quote"`, false},
+		{"ユニコード", false},
+	}
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewJSONHandler(&buf, nil))
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(base))
+	r.GET("/", func(c *gin.Context) {
+		FromContext(c).Info("handled")
+		c.String(http.StatusOK, c.GetString(RequestIDKey))
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"valid id reused", "upstream-id.1", true},
+		{"invalid id replaced", "bad id\r\nX-Injected: 1", false},
+		{"missing id generated", "", false},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		if tt.keep && id != tt.header {
+			t.Errorf("%s: id = %q, want %q", tt.name, id, tt.header)
+		}
+		if !tt.keep && (id == tt.header || len(id) != 32 || !validRequestID(id)) {
+			t.Errorf("%s: id = %q, want a new 32-character id", tt.name, id)
+		}
+		if w.Body.String() != id {
+			t.Errorf("%s: context id = %q, header %q", tt.name, w.Body.String(), id)
+		}
+
+		var line struct {
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil || line.RequestID != id {
+			t.Errorf("%s: log line %s does not carry request_id %q", tt.name, buf.String(), id)
+		}
+	}
+}
+
+func TestRedactRequestDump(t *testing.T) {
+	dump := "GET /api/me HTTP/1.1\r\nHost: example.com\r\nAuthorization: Bearer abc.def\r\nCookie: session=xyz\r\n\r\n"
+	got := redactRequestDump([]byte(dump))
+	if strings.Contains(got, "abc.def") || strings.Contains(got, "xyz") {
+		t.Errorf("credentials in dump: %s", got)
+	}
+	if !strings.Contains(got, "Host: example.com") {
+		t.Errorf("other headers dropped: %s", got)
+	}
+}
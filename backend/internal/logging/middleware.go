@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"runtime/debug"
+	"strings"
+	"time"
+	"todo-app/backend/internal/apperror"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the gin context key holding the request id.
+const RequestIDKey = "request_id"
+
+const maxRequestIDLength = 128
+
+// RequestID assigns every request an id, reusing a well-formed X-Request-ID
+// from the client or an upstream proxy, and echoes it on the response. It
+// stores a logger tagged with the request and trace ids in the gin context
+// and in the request context for FromContext.
+func RequestID(base *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+
+		logger := base.With("request_id", id)
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+		c.Set(loggerKey, logger)
+		c.Request = c.Request.WithContext(WithContext(c.Request.Context(), logger))
+
+		c.Next()
+	}
+}
+
+// AccessLog writes one line per request once the response is complete.
+// Request headers are only logged at debug level, with credentials redacted.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		logger := FromContext(c)
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		if logger.Enabled(c.Request.Context(), slog.LevelDebug) {
+			attrs = append(attrs, headersAttr(c.Request.Header))
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panic into a 500 response and logs it with the stack.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		request, _ := httputil.DumpRequest(c.Request, false)
+		FromContext(c).Error("panic recovered",
+			"panic", recovered,
+			"request", redactRequestDump(request),
+			"stack", string(debug.Stack()),
+		)
+		apperror.Respond(c, apperror.ErrInternal)
+	})
+}
+
+func headersAttr(h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(h))
+	for name, values := range h {
+		attrs = append(attrs, slog.Any(name, values))
+	}
+	return slog.Group("headers", attrs...)
+}
+
+// redactRequestDump masks sensitive header values in a request dump.
+func redactRequestDump(dump []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(dump)), "\r\n")
+	for i, line := range lines {
+		if name, _, ok := strings.Cut(line, ":"); ok && IsSensitive(name) {
+			lines[i] = name + ": " + redacted
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,224 @@
+// Package markdown renders the Markdown subset used in todo comments to
+// HTML that is safe to insert into a page. All input is escaped and only
+// the markup below is produced, so raw HTML in the source always shows as
+// text:
+//
+//	paragraphs and line breaks  <p>, <br>
+//	# headings                  <h1> to <h6>
+//	- and * bullets, 1. items   <ul>, <ol>, <li>
+//	> quotes                    <blockquote>
+//	``` fenced code             <pre><code>
+//	`code`, **bold**, *italic*  <code>, <strong>, <em>
+//	[text](url)                 <a>, for http, https and mailto URLs only
+//	@user@example.com           <span class="mention">, for known users
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// mentionPattern matches the address after an @. A trailing dot is left
+// out, so a mention can end a sentence.
+var mentionPattern = regexp.MustCompile(`^[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6}) +(.*)$`)
+	bulletPattern  = regexp.MustCompile(`^[-*] +(.*)$`)
+	orderedPattern = regexp.MustCompile(`^\d{1,9}[.)] +(.*)$`)
+)
+
+// Render converts src to HTML. isMention is called with the lower-cased
+// address of every @mention outside code; only addresses it accepts are
+// highlighted. A nil isMention highlights none.
+func Render(src string, isMention func(email string) bool) string {
+	if isMention == nil {
+		isMention = func(string) bool { return false }
+	}
+	r := &renderer{isMention: isMention}
+	r.blocks(strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n"))
+	return r.out.String()
+}
+
+// Mentions returns the distinct lower-cased addresses mentioned in src,
+// in order of first appearance. Mentions in code are ignored.
+func Mentions(src string) []string {
+	var emails []string
+	seen := make(map[string]bool)
+	Render(src, func(email string) bool {
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+		return false
+	})
+	return emails
+}
+
+type renderer struct {
+	out       strings.Builder
+	isMention func(string) bool
+}
+
+func (r *renderer) blocks(lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			// An unclosed fence runs to the end of the text.
+			end := i + 1
+			for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "```") {
+				end++
+			}
+			r.out.WriteString("<pre><code>")
+			r.out.WriteString(html.EscapeString(strings.Join(lines[i+1:end], "\n")))
+			r.out.WriteString("</code></pre>")
+			i = end + 1
+
+		case headingPattern.MatchString(trimmed):
+			m := headingPattern.FindStringSubmatch(trimmed)
+			tag := "h" + string(rune('0'+len(m[1])))
+			r.out.WriteString("<" + tag + ">" + r.inline(m[2]) + "</" + tag + ">")
+			i++
+
+		case bulletPattern.MatchString(trimmed):
+			i = r.list(lines, i, "ul", bulletPattern)
+
+		case orderedPattern.MatchString(trimmed):
+			i = r.list(lines, i, "ol", orderedPattern)
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(t, ">") {
+					break
+				}
+				quoted = append(quoted, strings.TrimPrefix(strings.TrimPrefix(t, ">"), " "))
+			}
+			r.out.WriteString("<blockquote>")
+			r.blocks(quoted)
+			r.out.WriteString("</blockquote>")
+
+		default:
+			var para []string
+			for ; i < len(lines) && startsParagraph(lines[i]); i++ {
+				para = append(para, r.inline(strings.TrimSpace(lines[i])))
+			}
+			r.out.WriteString("<p>" + strings.Join(para, "<br>") + "</p>")
+		}
+	}
+}
+
+// startsParagraph reports whether line continues a paragraph rather than
+// ending it or starting another block.
+func startsParagraph(line string) bool {
+	t := strings.TrimSpace(line)
+	return t != "" && !strings.HasPrefix(t, "```") && !strings.HasPrefix(t, ">") &&
+		!headingPattern.MatchString(t) && !bulletPattern.MatchString(t) && !orderedPattern.MatchString(t)
+}
+
+func (r *renderer) list(lines []string, i int, tag string, item *regexp.Regexp) int {
+	r.out.WriteString("<" + tag + ">")
+	for ; i < len(lines); i++ {
+		m := item.FindStringSubmatch(strings.TrimSpace(lines[i]))
+		if m == nil {
+			break
+		}
+		r.out.WriteString("<li>" + r.inline(m[1]) + "</li>")
+	}
+	r.out.WriteString("</" + tag + ">")
+	return i
+}
+
+// inline renders the spans of one line. Unmatched markers are kept as text.
+func (r *renderer) inline(s string) string {
+	var b strings.Builder
+	plain := 0
+	flush := func(i int) {
+		b.WriteString(html.EscapeString(s[plain:i]))
+	}
+
+	for i := 0; i < len(s); {
+		var rendered string
+		next := -1
+
+		switch {
+		case s[i] == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				rendered = "<code>" + html.EscapeString(s[i+1:i+1+end]) + "</code>"
+				next = i + end + 2
+			}
+		case strings.HasPrefix(s[i:], "**"):
+			if end := strings.Index(s[i+2:], "**"); end > 0 {
+				rendered = "<strong>" + r.inline(s[i+2:i+2+end]) + "</strong>"
+				next = i + end + 4
+			}
+		case s[i] == '*':
+			if end := strings.IndexByte(s[i+1:], '*'); end > 0 {
+				rendered = "<em>" + r.inline(s[i+1:i+1+end]) + "</em>"
+				next = i + end + 2
+			}
+		case s[i] == '[':
+			rendered, next = r.link(s, i)
+		case s[i] == '@' && (i == 0 || !isWordByte(s[i-1])):
+			if m := mentionPattern.FindString(s[i+1:]); m != "" {
+				if email := strings.ToLower(m); r.isMention(email) {
+					rendered = `<span class="mention">@` + html.EscapeString(m) + "</span>"
+				} else {
+					rendered = html.EscapeString("@" + m)
+				}
+				next = i + 1 + len(m)
+			}
+		}
+
+		if next < 0 {
+			i++
+			continue
+		}
+		flush(i)
+		b.WriteString(rendered)
+		i, plain = next, next
+	}
+	flush(len(s))
+	return b.String()
+}
+
+// link renders [text](url) starting at s[i]. Links with other schemes, such
+// as javascript:, are left as text.
+func (r *renderer) link(s string, i int) (string, int) {
+	closeText := strings.Index(s[i:], "](")
+	if closeText < 0 {
+		return "", -1
+	}
+	closeURL := strings.IndexByte(s[i+closeText+2:], ')')
+	if closeURL < 0 {
+		return "", -1
+	}
+	text := s[i+1 : i+closeText]
+	raw := strings.TrimSpace(s[i+closeText+2 : i+closeText+2+closeURL])
+	next := i + closeText + 2 + closeURL + 1
+
+	u, err := url.Parse(raw)
+	if err != nil || text == "" {
+		return "", -1
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+	default:
+		return "", -1
+	}
+	return `<a href="` + html.EscapeString(u.String()) + `" rel="nofollow noopener noreferrer" target="_blank">` +
+		r.inline(text) + "</a>", next
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
@@ -0,0 +1,79 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func known(emails ...string) func(string) bool {
+	return func(email string) bool {
+		for _, e := range emails {
+			if e == email {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one<br>two</p><p>three</p>"},
+		{"inline", "**bold** *it* `a<b`", "<p><strong>bold</strong> <em>it</em> <code>a&lt;b</code></p>"},
+		{"unmatched markers", "2 ** 3 and `x", "<p>2 ** 3 and `x</p>"},
+		{"heading", "## Plan", "<h2>Plan</h2>"},
+		{"bullets", "- a\n* b\nafter", "<ul><li>a</li><li>b</li></ul><p>after</p>"},
+		{"ordered", "1. a\n2) b", "<ol><li>a</li><li>b</li></ol>"},
+		{"quote", "> quoted\n> more", "<blockquote><p>quoted<br>more</p></blockquote>"},
+		{"fence", "```\n<b>x</b>\n**y**\n```", "<pre><code>&lt;b&gt;x&lt;/b&gt;\n**y**</code></pre>"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)",
+			`<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer" target="_blank">docs</a></p>`},
+		{"mention", "ping @Alice@Example.com.", `<p>ping <span class="mention">@Alice@Example.com</span>.</p>`},
+		{"unknown mention", "ping @bob@example.com", "<p>ping @bob@example.com</p>"},
+		{"not a mention", "mail me at carol@example.com", "<p>mail me at carol@example.com</p>"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.src, known("alice@example.com")); got != tt.want {
+			t.Errorf("%s: Render(%q) =\n%s\nwant\n%s", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	for _, src := range []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`[x](data:text/html,<script>alert(1)</script>)`,
+		"**<b>**",
+		"- <i>item</i>",
+		"# <h1>",
+		"> <q>",
+	} {
+		got := Render(src, nil)
+		for _, bad := range []string{"<script", "<img", "<b>", "<i>", "<h1><h1>", "<q>", "<a", `"onmouseover`} {
+			if strings.Contains(got, bad) {
+				t.Errorf("Render(%q) = %q contains %q", src, got, bad)
+			}
+		}
+	}
+}
+
+func TestRenderLinkQuotes(t *testing.T) {
+	got := Render(`[x](https://example.com/"onmouseover="alert(1))`, nil)
+	if strings.Contains(got, `"onmouseover`) {
+		t.Errorf("quote in URL was not escaped: %s", got)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	src := "@Alice@example.com and @bob@example.org, again @alice@EXAMPLE.com\n`@carol@example.com`\n```\n@dave@example.com\n```"
+	want := []string{"alice@example.com", "bob@example.org"}
+	if got := Mentions(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("Mentions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGinMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/api/todos/:id", func(c *gin.Context) {
+		if c.Param("id") == "404" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	ok := httpRequests.WithLabelValues("GET", "/api/todos/:id", "200")
+	notFound := httpRequests.WithLabelValues("GET", "/api/todos/:id", "404")
+	unmatched := httpRequests.WithLabelValues("GET", "unmatched", "404")
+	before := []float64{testutil.ToFloat64(ok), testutil.ToFloat64(notFound), testutil.ToFloat64(unmatched)}
+
+	for _, path := range []string{"/api/todos/1", "/api/todos/2", "/api/todos/404", "/api/nothing/here", "/favicon.ico"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"200 on the template", testutil.ToFloat64(ok) - before[0], 2},
+		{"404 from the handler", testutil.ToFloat64(notFound) - before[1], 1},
+		{"no route", testutil.ToFloat64(unmatched) - before[2], 2},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %v requests, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Request paths never become labels.
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "route" && (strings.Contains(label.GetValue(), "/1") || strings.Contains(label.GetValue(), "/nothing")) {
+					t.Errorf("%s has route label %q", family.GetName(), label.GetValue())
+				}
+			}
+		}
+	}
+	if n := testutil.CollectAndCount(httpDuration, "todoapp_http_request_duration_seconds"); n == 0 {
+		t.Error("no latency observed")
+	}
+}
+
+func TestDomainCounters(t *testing.T) {
+	counters := []struct {
+		name   string
+		record func()
+		value  func() float64
+	}{
+		{"failed password login", func() { Login("password", LoginFailed) }, func() float64 {
+			return testutil.ToFloat64(logins.WithLabelValues("password", LoginFailed))
+		}},
+		{"passkey login", func() { Login("passkey", LoginSucceeded) }, func() float64 {
+			return testutil.ToFloat64(logins.WithLabelValues("passkey", LoginSucceeded))
+		}},
+		{"admin action", func() { AdminAction("suspend_user") }, func() float64 {
+			return testutil.ToFloat64(adminActions.WithLabelValues("suspend_user"))
+		}},
+		{"todo created", TodosCreated.Inc, func() float64 { return testutil.ToFloat64(TodosCreated) }},
+		{"todo completed", TodosCompleted.Inc, func() float64 { return testutil.ToFloat64(TodosCompleted) }},
+	}
+	for _, c := range counters {
+		before := c.value()
+		c.record()
+		c.record()
+		if got := c.value() - before; got != 2 {
+			t.Errorf("%s: counted %v, want 2", c.name, got)
+		}
+	}
+
+	// A login method or result does not leak into the other label.
+	if got := testutil.ToFloat64(logins.WithLabelValues("passkey", LoginFailed)); got != 0 {
+		t.Errorf("passkey failures = %v, want 0", got)
+	}
+}
+
+func TestRegisterDB(t *testing.T) {
+	_, db := sqltest.New(t)
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	RegisterDB(db, name)
+	db.SetMaxOpenConns(7)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`go_sql_max_open_connections{db_name="` + name + `"} 7`,
+		`go_sql_open_connections{db_name="` + name + `"}`,
+		`go_sql_in_use_connections{db_name="` + name + `"}`,
+		`go_sql_idle_connections{db_name="` + name + `"}`,
+		`go_sql_wait_count_total{db_name="` + name + `"}`,
+		`go_sql_wait_duration_seconds_total{db_name="` + name + `"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
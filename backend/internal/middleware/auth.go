@@ -3,32 +3,88 @@ package middleware
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/audit"
+	"todo-app/backend/internal/logging"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key-change-in-production")
+var jwtSecret []byte
+
+var (
+	errMissingAuthorization   = apperror.New(http.StatusUnauthorized, "missing_authorization", "Authorization header required")
+	errInvalidAuthorization   = apperror.New(http.StatusUnauthorized, "invalid_authorization", "Invalid authorization format")
+	errInvalidToken           = apperror.New(http.StatusUnauthorized, "invalid_token", "Invalid token")
+	errTokenRevoked           = apperror.New(http.StatusUnauthorized, "token_revoked", "Token has been revoked")
+	errAdminRequired          = apperror.New(http.StatusForbidden, "admin_required", "Admin access required")
+	errPasswordChangeRequired = apperror.New(http.StatusForbidden, "password_change_required", "Password change required")
+	errTwoFactorRequired      = apperror.New(http.StatusForbidden, "two_factor_required", "Two-factor authentication required")
+	errAccountSuspended       = apperror.New(http.StatusForbidden, "account_suspended", "This account has been suspended")
+	errImpersonating          = apperror.New(http.StatusForbidden, "impersonation_not_allowed", "Not allowed while impersonating a user")
+)
+
+// SetJWTSecret sets the HMAC key used to sign and verify tokens. It must be
+// called before the server starts handling requests.
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
 
 type Claims struct {
 	UserID  int  `json:"user_id"`
 	IsAdmin bool `json:"is_admin"`
+	// MFA records that the session was established with a second factor.
+	MFA bool `json:"mfa,omitempty"`
+	// Purpose is set on short-lived tokens that are only valid for a single
+	// step of a flow, such as a pending two-factor login. Session tokens
+	// leave it empty.
+	Purpose string `json:"purpose,omitempty"`
+	// ImpersonatedBy is the admin who obtained this token to act as UserID.
+	ImpersonatedBy int `json:"impersonated_by,omitempty"`
 	jwt.RegisteredClaims
 }
 
-type contextKey string
+const (
+	purposeTwoFactor    = "2fa"
+	purposeConfirmation = "confirm"
+	purposeDownload     = "download"
 
-const UserContextKey contextKey = "user"
+	challengeTokenTTL    = 5 * time.Minute
+	confirmationTokenTTL = 2 * time.Minute
+)
+
+type TokenOption func(*Claims)
+
+func WithMFA() TokenOption {
+	return func(c *Claims) {
+		c.MFA = true
+	}
+}
+
+// WithImpersonator marks the token as issued to adminID for acting as the
+// user, and shortens its lifetime to ttl.
+func WithImpersonator(adminID int, ttl time.Duration) TokenOption {
+	return func(c *Claims) {
+		c.ImpersonatedBy = adminID
+		c.ExpiresAt = jwt.NewNumericDate(c.IssuedAt.Add(ttl))
+	}
+}
 
 type UserContext struct {
-	UserID  int
-	IsAdmin bool
+	UserID             int
+	IsAdmin            bool
+	MFA                bool
+	MustChangePassword bool
+	// ImpersonatedBy is the admin acting as this user, or 0.
+	ImpersonatedBy int
 }
 
-func GenerateToken(userID int, isAdmin bool) (string, error) {
+func GenerateToken(userID int, isAdmin bool, opts ...TokenOption) (string, error) {
 	claims := Claims{
 		UserID:  userID,
 		IsAdmin: isAdmin,
@@ -37,44 +93,232 @@ func GenerateToken(userID int, isAdmin bool) (string, error) {
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
+	for _, opt := range opts {
+		opt(&claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+var ErrInvalidChallenge = errors.New("invalid or expired challenge token")
+
+// GenerateChallengeToken issues a token proving the password step of a login
+// succeeded. It cannot be used as a session token.
+func GenerateChallengeToken(userID int) (string, error) {
+	claims := Claims{
+		UserID:  userID,
+		Purpose: purposeTwoFactor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(challengeTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
 
-func GinAuthMiddleware() gin.HandlerFunc {
+func ParseChallengeToken(tokenString string) (int, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != purposeTwoFactor {
+		return 0, ErrInvalidChallenge
+	}
+	return claims.UserID, nil
+}
+
+var ErrInvalidConfirmation = errors.New("invalid or expired confirmation token")
+
+// confirmationClaims bind a confirmation to one admin, action and target so
+// that it cannot be replayed for anything else.
+type confirmationClaims struct {
+	AdminID  int    `json:"admin_id"`
+	Action   string `json:"action"`
+	TargetID int    `json:"target_id"`
+	Purpose  string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// GenerateConfirmationToken issues a short-lived token that adminID must
+// present to perform action on targetID. It cannot be used as a session
+// token.
+func GenerateConfirmationToken(adminID int, action string, targetID int) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(confirmationTokenTTL)
+	claims := confirmationClaims{
+		AdminID:  adminID,
+		Action:   action,
+		TargetID: targetID,
+		Purpose:  purposeConfirmation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	return token, expiresAt.Truncate(time.Second), err
+}
+
+func VerifyConfirmationToken(tokenString string, adminID int, action string, targetID int) error {
+	claims := &confirmationClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != purposeConfirmation ||
+		claims.AdminID != adminID || claims.Action != action || claims.TargetID != targetID {
+		return ErrInvalidConfirmation
+	}
+	return nil
+}
+
+var ErrInvalidDownload = errors.New("invalid or expired download token")
+
+// downloadClaims grant a download of one attachment to whoever holds the
+// token, so that it can be put in a link.
+type downloadClaims struct {
+	AttachmentID int    `json:"attachment_id"`
+	Purpose      string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// GenerateDownloadToken issues a token that downloads attachmentID until
+// expiresAt. It cannot be used as a session token.
+func GenerateDownloadToken(attachmentID int, expiresAt time.Time) (string, error) {
+	claims := downloadClaims{
+		AttachmentID: attachmentID,
+		Purpose:      purposeDownload,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+func VerifyDownloadToken(tokenString string, attachmentID int) error {
+	claims := &downloadClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != purposeDownload || claims.AttachmentID != attachmentID {
+		return ErrInvalidDownload
+	}
+	return nil
+}
+
+// lastSeenInterval is how stale users.last_seen_at may get before a request
+// updates it, which keeps the write off most requests. Active user counts
+// are only as precise as this.
+const lastSeenInterval = 5 * time.Minute
+
+// touchLastSeen records activity for the admin statistics. A failure only
+// costs precision there, so it is logged and the request goes on.
+func touchLastSeen(ctx context.Context, db *sql.DB, userID int) {
+	_, err := db.ExecContext(ctx,
+		"UPDATE users SET last_seen_at = CURRENT_TIMESTAMP WHERE id = $1", userID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to update last seen time", "user_id", userID, "error", err)
+	}
+}
+
+// authenticate checks the session token in authHeader against the user's
+// current row, for GinAuthMiddleware and AuthMiddleware alike.
+func authenticate(ctx context.Context, db *sql.DB, authHeader string) (UserContext, error) {
+	if authHeader == "" {
+		return UserContext{}, errMissingAuthorization
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return UserContext{}, errInvalidAuthorization
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+
+	if err != nil || !token.Valid || claims.Purpose != "" {
+		return UserContext{}, errInvalidToken
+	}
+
+	var passwordChangedAt time.Time
+	var isAdmin, mustChangePassword bool
+	var suspendedAt, lastSeenAt sql.NullTime
+	err = db.QueryRowContext(ctx,
+		"SELECT password_changed_at, is_admin, must_change_password, suspended_at, last_seen_at FROM users WHERE id = $1",
+		claims.UserID,
+	).Scan(&passwordChangedAt, &isAdmin, &mustChangePassword, &suspendedAt, &lastSeenAt)
+	if err == sql.ErrNoRows {
+		return UserContext{}, errInvalidToken
+	}
+	if err != nil {
+		return UserContext{}, apperror.Wrap(err, "Internal server error")
+	}
+
+	// JWT timestamps have second precision, so compare at that granularity.
+	if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(passwordChangedAt.Truncate(time.Second)) {
+		return UserContext{}, errTokenRevoked
+	}
+
+	// Checked on every request so that suspending an account ends its
+	// existing sessions immediately.
+	if suspendedAt.Valid {
+		return UserContext{}, errAccountSuspended
+	}
+
+	// Impersonated requests are not the user's own activity.
+	if claims.ImpersonatedBy == 0 && (!lastSeenAt.Valid || time.Since(lastSeenAt.Time) > lastSeenInterval) {
+		touchLastSeen(ctx, db, claims.UserID)
+	}
+
+	// The admin flag is taken from the database rather than the token,
+	// so that demoting an admin takes effect immediately.
+	return UserContext{
+		UserID:             claims.UserID,
+		IsAdmin:            isAdmin,
+		MFA:                claims.MFA,
+		MustChangePassword: mustChangePassword,
+		ImpersonatedBy:     claims.ImpersonatedBy,
+	}, nil
+}
+
+func GinAuthMiddleware(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+		user, err := authenticate(c.Request.Context(), db, c.GetHeader("Authorization"))
+		if err != nil {
+			apperror.Respond(c, err)
 			return
 		}
+		c.Set("user", user)
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
-			c.Abort()
+		if user.ImpersonatedBy == 0 {
+			c.Next()
 			return
 		}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
+		logging.With(c, "impersonated_by", user.ImpersonatedBy)
+		c.Next()
 
-		c.Set("user", UserContext{
-			UserID:  claims.UserID,
-			IsAdmin: claims.IsAdmin,
+		// Recorded even if the client has gone away, so that nothing done
+		// through an impersonation token is missing from the audit log.
+		err = audit.Record(context.WithoutCancel(c.Request.Context()), db, audit.Entry{
+			ActorID:        user.UserID,
+			ImpersonatedBy: user.ImpersonatedBy,
+			Action:         audit.ActionImpersonatedRequest,
+			TargetUserID:   user.UserID,
+			Method:         c.Request.Method,
+			Route:          c.FullPath(),
+			Status:         c.Writer.Status(),
+			RequestID:      c.GetString(logging.RequestIDKey),
 		})
-
-		c.Next()
+		if err != nil {
+			logging.FromContext(c).Error("Failed to record impersonated request", "error", err)
+		}
 	}
 }
 
@@ -82,15 +326,17 @@ func GinAdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userCtx, exists := c.Get("user")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+			apperror.Respond(c, apperror.ErrUnauthorized)
 			return
 		}
 
 		user, ok := userCtx.(UserContext)
 		if !ok || !user.IsAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			c.Abort()
+			apperror.Respond(c, errAdminRequired)
+			return
+		}
+		if user.ImpersonatedBy != 0 {
+			apperror.Respond(c, errImpersonating)
 			return
 		}
 
@@ -98,64 +344,99 @@ func GinAdminMiddleware() gin.HandlerFunc {
 	}
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+// GinPasswordRotationMiddleware blocks users who have been flagged for a
+// forced password change from everything except the routes needed to do so.
+func GinPasswordRotationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetUserFromGinContext(c)
+		if !ok {
+			apperror.Respond(c, apperror.ErrUnauthorized)
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+		if user.MustChangePassword {
+			apperror.Respond(c, errPasswordChangeRequired)
 			return
 		}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
+		c.Next()
+	}
+}
 
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+// GinRequireMFAMiddleware rejects sessions that were not established with a
+// second factor. It is applied to the admin routes.
+func GinRequireMFAMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetUserFromGinContext(c)
+		if !ok {
+			apperror.Respond(c, apperror.ErrUnauthorized)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), UserContextKey, UserContext{
-			UserID:  claims.UserID,
-			IsAdmin: claims.IsAdmin,
-		})
+		if !user.MFA {
+			apperror.Respond(c, errTwoFactorRequired)
+			return
+		}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+		c.Next()
+	}
 }
 
-func AdminMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userCtx, ok := r.Context().Value(UserContextKey).(UserContext)
+// GinDenyImpersonationMiddleware keeps impersonation tokens away from
+// credential management, so an admin acting as a user cannot take over the
+// account.
+func GinDenyImpersonationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetUserFromGinContext(c)
 		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			apperror.Respond(c, apperror.ErrUnauthorized)
 			return
 		}
 
-		if !userCtx.IsAdmin {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+		if user.ImpersonatedBy != 0 {
+			apperror.Respond(c, errImpersonating)
 			return
 		}
 
-		next.ServeHTTP(w, r)
-	})
+		c.Next()
+	}
 }
 
-func CORS(next http.Handler) http.Handler {
+type contextKey string
+
+const UserContextKey contextKey = "user"
+
+// AuthMiddleware is GinAuthMiddleware for plain net/http handlers. It
+// rejects impersonation tokens, since requests made through it are not
+// recorded in the audit log.
+func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := authenticate(r.Context(), db, r.Header.Get("Authorization"))
+			if err == nil && user.ImpersonatedBy != 0 {
+				err = errImpersonating
+			}
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func AdminMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		userCtx, ok := GetUserFromContext(r)
+		if !ok {
+			writeError(w, r, apperror.ErrUnauthorized)
+			return
+		}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+		if !userCtx.IsAdmin {
+			writeError(w, r, errAdminRequired)
 			return
 		}
 
@@ -163,6 +444,15 @@ func CORS(next http.Handler) http.Handler {
 	})
 }
 
+// writeError answers a net/http request with the status and message of err.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := apperror.Wrap(err, "Internal server error")
+	if appErr.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error(appErr.Message, "error", err)
+	}
+	http.Error(w, appErr.Message, appErr.Status)
+}
+
 func GetUserFromContext(r *http.Request) (UserContext, bool) {
 	userCtx, ok := r.Context().Value(UserContextKey).(UserContext)
 	return userCtx, ok
@@ -177,25 +467,35 @@ func GetUserFromGinContext(c *gin.Context) (UserContext, bool) {
 	return user, ok
 }
 
-func CreateDefaultAdmin(db *sql.DB) error {
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM users WHERE is_admin = true").Scan(&count)
+var ErrNoAdmin = errors.New("no admin account exists; promote a user with SQL, the default admin is only seeded into an empty database")
+
+// CreateDefaultAdmin seeds the default admin into an empty database. The API
+// never lets the last admin go, so a database with users but no admin has
+// been changed by hand; recreating a well-known account there would hand it
+// to anyone who knows the default credentials, so ErrNoAdmin is returned
+// instead.
+func CreateDefaultAdmin(db *sql.DB, email, password string) error {
+	var users, admins int
+	err := db.QueryRow("SELECT COUNT(*), COUNT(*) FILTER (WHERE is_admin) FROM users").Scan(&users, &admins)
 	if err != nil {
 		return err
 	}
 
-	if count > 0 {
+	if admins > 0 {
 		return nil
 	}
+	if users > 0 {
+		return ErrNoAdmin
+	}
 
-	hashedPassword, err := HashPassword("admin123")
+	hashedPassword, err := HashPassword(password)
 	if err != nil {
 		return err
 	}
 
 	_, err = db.Exec(
-		"INSERT INTO users (email, password, is_admin) VALUES ($1, $2, $3)",
-		"admin@example.com", hashedPassword, true,
+		"INSERT INTO users (email, password, is_admin, must_change_password) VALUES ($1, $2, $3, $4)",
+		email, hashedPassword, true, true,
 	)
 	return err
 }
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"todo-app/backend/internal/sqltest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var userColumns = []string{"password_changed_at", "is_admin", "must_change_password", "suspended_at", "last_seen_at"}
+
+func newUserDB(t *testing.T, isAdmin bool) *sql.DB {
+	t.Helper()
+
+	SetJWTSecret("test-secret")
+	fake, db := sqltest.New(t)
+	fake.On("FROM users WHERE id = $1", func(args []driver.Value) sqltest.Result {
+		return sqltest.Row(userColumns, time.Now().Add(-time.Hour), isAdmin, false, nil, time.Now())
+	})
+	return db
+}
+
+func newAdminRouter(t *testing.T, isAdmin bool) *gin.Engine {
+	t.Helper()
+
+	db := newUserDB(t, isAdmin)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	admin := r.Group("/api/admin", GinAuthMiddleware(db), GinAdminMiddleware())
+	admin.GET("/users", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"users": []string{}})
+	})
+	return r
+}
+
+// newHTTPAdminRouter is newAdminRouter built from the net/http middleware.
+func newHTTPAdminRouter(t *testing.T, isAdmin bool) http.Handler {
+	t.Helper()
+
+	db := newUserDB(t, isAdmin)
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/admin/users", AuthMiddleware(db)(AdminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetUserFromContext(r); !ok {
+			t.Error("no user in the request context")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))))
+	return mux
+}
+
+func getAdminUsers(t *testing.T, r http.Handler, token string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAdminMiddlewareUsesDatabaseRole(t *testing.T) {
+	tests := []struct {
+		name         string
+		tokenIsAdmin bool
+		dbIsAdmin    bool
+		want         int
+	}{
+		{"admin", true, true, http.StatusOK},
+		// Demoted after the token was issued.
+		{"demoted", true, false, http.StatusForbidden},
+		// Promoted after the token was issued.
+		{"promoted", false, true, http.StatusOK},
+		{"user", false, false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, h := newAdminRouter(t, tt.dbIsAdmin), newHTTPAdminRouter(t, tt.dbIsAdmin)
+			token, err := GenerateToken(1, tt.tokenIsAdmin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := getAdminUsers(t, r, token); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+			if got := getAdminUsers(t, h, token); got != tt.want {
+				t.Errorf("net/http status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareRejectsImpersonation(t *testing.T) {
+	r := newHTTPAdminRouter(t, true)
+	token, err := GenerateToken(1, true, WithImpersonator(2, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getAdminUsers(t, r, token); got != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", got)
+	}
+}
+
+func TestPasswordChangeRevokesEarlierTokens(t *testing.T) {
+	SetJWTSecret("test-secret")
+	token, err := GenerateToken(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &Claims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return jwtSecret, nil }); err != nil {
+		t.Fatal(err)
+	}
+	issuedAt := claims.IssuedAt.Time
+	// The driver returns TIMESTAMPTZ values in the session time zone.
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name      string
+		changedAt time.Time
+		want      int
+	}{
+		{"changed before the token", issuedAt.Add(-time.Minute).In(tokyo), http.StatusOK},
+		{"changed in the same second", issuedAt.Add(500 * time.Millisecond).In(tokyo), http.StatusOK},
+		{"changed after the token", issuedAt.Add(time.Second).In(tokyo), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := sqltest.New(t)
+			fake.On("FROM users WHERE id = $1", func(args []driver.Value) sqltest.Result {
+				return sqltest.Row(userColumns, tt.changedAt, false, false, nil, time.Now())
+			})
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.GET("/api/todos", GinAuthMiddleware(db), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(BodyLimit(16, map[string]int64{"/upload": 64}))
+	handler := func(c *gin.Context) {
+		var body struct {
+			Title string `json:"title"`
+		}
+		if err := validation.Decode(c.Request, &body); err != nil {
+			apperror.Respond(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+	r.POST("/todos", handler)
+	r.POST("/upload", handler)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		chunked bool
+		status  int
+	}{
+		{"under the limit", "/todos", `{"title":"abc"}`, false, http.StatusNoContent},
+		{"declared length over the limit", "/todos", `{"title":"abcdefgh"}`, false, http.StatusRequestEntityTooLarge},
+		{"chunked body over the limit", "/todos", `{"title":"abcdefgh"}`, true, http.StatusRequestEntityTooLarge},
+		{"route override", "/upload", `{"title":"abcdefgh"}`, false, http.StatusNoContent},
+		{"over the route override", "/upload", `{"title":"` + strings.Repeat("a", 64) + `"}`, true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				// Hide the length so that only the reader enforces the limit.
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeCorpus(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newBreachedPasswords loads a corpus holding the given passwords.
+func newBreachedPasswords(t *testing.T, passwords ...string) *BreachedPasswords {
+	t.Helper()
+
+	var lines []string
+	for _, p := range passwords {
+		lines = append(lines, strings.ToUpper(sha1Hex(p))+":1")
+	}
+	b, err := LoadBreachedPasswords(writeCorpus(t, strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBreachedPasswordsContains(t *testing.T) {
+	// The SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	// The second line shares its five-character prefix, so both land in the
+	// same bucket.
+	b, err := LoadBreachedPasswords(writeCorpus(t, `# breached passwords
+5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004
+5BAA60000000000000000000000000000000000A:1
+
+`+sha1Hex("letmein2024")+`
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		// Hashes are compared case-insensitively.
+		{"letmein2024", true},
+		{"Password", false},
+		{"kx7qpmzv", false},
+	}
+	for _, tt := range tests {
+		if got := b.Contains(tt.password); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+	if n := len(b.ranges["5BAA6"]); n != 2 {
+		t.Errorf("bucket 5BAA6 holds %d suffixes, want 2", n)
+	}
+}
+
+func TestLoadBreachedPasswordsRejectsInvalidLines(t *testing.T) {
+	for _, content := range []string{
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FDZ:1",
+		"password",
+	} {
+		if _, err := LoadBreachedPasswords(writeCorpus(t, content)); err == nil {
+			t.Errorf("LoadBreachedPasswords accepted %q", content)
+		}
+	}
+	if _, err := LoadBreachedPasswords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadBreachedPasswords accepted a missing file")
+	}
+}
@@ -1,6 +1,13 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -13,3 +20,207 @@ func CheckPassword(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordTooWeak  = errors.New("password is too weak")
+	ErrPasswordBreached = errors.New("password has appeared in a data breach")
+)
+
+// bcrypt silently truncates input beyond 72 bytes.
+const maxPasswordBytes = 72
+
+type PasswordPolicy struct {
+	MinLength int
+	// MinScore is the lowest accepted strength score on zxcvbn's 0-4 scale.
+	MinScore int
+	Breached *BreachedPasswords
+}
+
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength: 8,
+		MinScore:  2,
+	}
+}
+
+// Validate checks password against the policy. userInputs are values such as
+// the account email that should not make a password look stronger than it is.
+func (p *PasswordPolicy) Validate(password string, userInputs ...string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordTooShort, p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: must be at most %d bytes", ErrPasswordTooLong, maxPasswordBytes)
+	}
+	if PasswordStrength(password, userInputs...) < p.MinScore {
+		return ErrPasswordTooWeak
+	}
+	if p.Breached != nil && p.Breached.Contains(password) {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+var commonPasswords = map[string]bool{
+	"password": true, "passw0rd": true, "123456": true, "12345678": true,
+	"123456789": true, "qwerty": true, "abc123": true, "letmein": true,
+	"welcome": true, "monkey": true, "dragon": true, "iloveyou": true,
+	"admin": true, "admin123": true, "login": true, "master": true,
+	"sunshine": true, "football": true, "baseball": true, "princess": true,
+	"trustno1": true, "secret": true, "changeme": true, "todoapp": true,
+}
+
+var keyboardRows = []string{
+	"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890",
+	"abcdefghijklmnopqrstuvwxyz",
+}
+
+// PasswordStrength returns a zxcvbn-style score from 0 (trivially guessable)
+// to 4 (very unguessable). It estimates the number of guesses needed by
+// discounting dictionary words, user inputs, repeats and sequences before
+// applying the brute-force charset size to what remains.
+func PasswordStrength(password string, userInputs ...string) int {
+	lower := strings.ToLower(password)
+	if lower == "" || commonPasswords[lower] || commonPasswords[strings.TrimRight(lower, "0123456789!")] {
+		return 0
+	}
+
+	remaining := lower
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if at := strings.IndexByte(input, '@'); at > 0 {
+			input = input[:at]
+		}
+		if len(input) >= 3 {
+			remaining = strings.ReplaceAll(remaining, input, "\x00")
+		}
+	}
+	for word := range commonPasswords {
+		if len(word) >= 4 {
+			remaining = strings.ReplaceAll(remaining, word, "\x00")
+		}
+	}
+	remaining = collapseSequences(remaining)
+	remaining = collapseRepeats(remaining)
+
+	// Each collapsed token still costs a small dictionary's worth of guesses.
+	tokens := strings.Count(remaining, "\x00")
+	remaining = strings.ReplaceAll(remaining, "\x00", "")
+
+	guesses := math.Pow(float64(charsetSize(password)), float64(utf8.RuneCountInString(remaining)))
+	guesses *= math.Pow(100, float64(tokens))
+
+	switch log := math.Log10(guesses); {
+	case log < 3:
+		return 0
+	case log < 6:
+		return 1
+	case log < 8:
+		return 2
+	case log < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func charsetSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return size
+}
+
+// collapseSequences replaces runs of three or more characters that follow a
+// keyboard row or the alphabet, in either direction, with a single token.
+func collapseSequences(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		n := sequenceLength(s[i:])
+		if n >= 3 {
+			b.WriteByte(0)
+			i += n
+			continue
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+func sequenceLength(s string) int {
+	best := 1
+	for _, row := range keyboardRows {
+		for _, seq := range []string{row, reverse(row)} {
+			start := strings.IndexByte(seq, s[0])
+			if start < 0 {
+				continue
+			}
+			n := 1
+			for n < len(s) && start+n < len(seq) && s[n] == seq[start+n] {
+				n++
+			}
+			if n > best {
+				best = n
+			}
+		}
+	}
+	return best
+}
+
+// collapseRepeats replaces runs of the same character with a single token.
+func collapseRepeats(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		j := i + 1
+		for j < len(s) && s[j] == s[i] {
+			j++
+		}
+		if j-i >= 3 {
+			b.WriteByte(0)
+		} else {
+			b.WriteString(s[i:j])
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"", 0},
+		{"password", 0},
+		{"Password1!", 0},
+		{"passw0rd", 0},
+		{"aaaaaaaaaaaa", 0},
+		{"abcdefgh", 0},
+		{"qwertyuiop", 1},
+		{"zxcvbnm123", 1},
+		{"kx7qpmzv", 4},
+		{"Tr0ub4dor&3", 4},
+		{"correct horse battery staple", 4},
+	}
+	for _, tt := range tests {
+		if got := PasswordStrength(tt.password); got != tt.want {
+			t.Errorf("PasswordStrength(%q) = %d, want %d", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordStrengthDiscountsUserInputs(t *testing.T) {
+	without := PasswordStrength("alice2024")
+	with := PasswordStrength("alice2024", "alice@example.com")
+	if with >= without {
+		t.Errorf("score with the email's local part = %d, want below %d", with, without)
+	}
+	// Inputs shorter than three characters are ignored.
+	if got := PasswordStrength("kx7qpmzv", "kx@example.com"); got != PasswordStrength("kx7qpmzv") {
+		t.Errorf("short input changed the score to %d", got)
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	breached := newBreachedPasswords(t, "Tr0ub4dor&3")
+	policy := &PasswordPolicy{MinLength: 8, MinScore: 2, Breached: breached}
+
+	tests := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{"strong", "kx7qpmzv", nil},
+		{"too short", "kx7qpm", ErrPasswordTooShort},
+		// Length is counted in characters, not bytes.
+		{"short in bytes only", "ぱすわーどです", ErrPasswordTooShort},
+		{"weak", "qwertyuiop", ErrPasswordTooWeak},
+		{"breached", "Tr0ub4dor&3", ErrPasswordBreached},
+		{"72 bytes", strings.Repeat("kx7qpmzv", 9), nil},
+		{"73 bytes", strings.Repeat("kx7qpmzv", 9) + "a", ErrPasswordTooLong},
+		// 37 characters but 74 bytes, which bcrypt would truncate.
+		{"multibyte over 72 bytes", strings.Repeat("é", 37), ErrPasswordTooLong},
+	}
+	for _, tt := range tests {
+		if err := policy.Validate(tt.password); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Validate = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyMinScore(t *testing.T) {
+	// "qwertyuiop" scores 1.
+	for minScore, want := range map[int]error{0: nil, 1: nil, 2: ErrPasswordTooWeak, 4: ErrPasswordTooWeak} {
+		policy := &PasswordPolicy{MinLength: 8, MinScore: minScore}
+		if err := policy.Validate("qwertyuiop"); !errors.Is(err, want) {
+			t.Errorf("MinScore %d: Validate = %v, want %v", minScore, err, want)
+		}
+	}
+
+	policy := &PasswordPolicy{MinLength: 8, MinScore: 2}
+	if err := policy.Validate("alice2024", "alice@example.com"); err != nil {
+		t.Errorf("Validate with user input = %v", err)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("kx7qpmzv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword("kx7qpmzv", hash) {
+		t.Error("correct password rejected")
+	}
+	if CheckPassword("kx7qpmzw", hash) {
+		t.Error("wrong password accepted")
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/logging"
+	"todo-app/backend/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GinRateLimitMiddleware applies the per-IP bucket. If the store is
+// unavailable requests are let through rather than locking everyone out.
+func GinRateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, wait, err := limiter.AllowIP(c.Request.Context(), c.ClientIP())
+		if err != nil {
+			logging.FromContext(c).Error("Rate limit store error", "error", err)
+			c.Next()
+			return
+		}
+
+		if !allowed {
+			AbortTooManyRequests(c, wait)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func AbortTooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter)))
+	apperror.Respond(c, apperror.ErrTooManyRequests)
+}
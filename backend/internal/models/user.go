@@ -1,36 +1,50 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
 	"time"
 )
 
 type User struct {
-	ID        int       `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	IsAdmin   bool      `json:"is_admin"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                 int        `json:"id"`
+	Email              string     `json:"email"`
+	Password           string     `json:"-"`
+	IsAdmin            bool       `json:"is_admin"`
+	MustChangePassword bool       `json:"must_change_password"`
+	TwoFactorEnabled   bool       `json:"two_factor_enabled"`
+	SuspendedAt        *time.Time `json:"suspended_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
 
 type Todo struct {
-	ID          int       `json:"id"`
-	UserID      int       `json:"user_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Status       string     `json:"status"`
+	Completed    bool       `json:"completed"`
+	DueDate      *string    `json:"due_date"`
+	Position     *string    `json:"position"`
+	CommentCount int        `json:"comment_count"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
+// Request structs are decoded and checked by validation.Bind according to
+// their validate tags. Passwords are never trimmed or normalized because
+// existing hashes were computed over the raw input.
+
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"trim,nfc,required,max=255,email"`
+	Password string `json:"password" validate:"required"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"trim,nfc,required"`
+	Password string `json:"password" validate:"required"`
 }
 
 type LoginResponse struct {
@@ -38,8 +52,291 @@ type LoginResponse struct {
 	User    User   `json:"user"`
 }
 
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+	// QRCode is a base64-encoded PNG of OTPAuthURL.
+	QRCode string `json:"qr_code_png"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type Passkey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Credential fields carry the PublicKeyCredential produced by the browser,
+// serialized as JSON by the client unchanged.
+type PasskeyRegistrationRequest struct {
+	SessionID  string          `json:"session_id"`
+	Name       string          `json:"name" validate:"trim,nfc,max=255"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+type PasskeyLoginBeginRequest struct {
+	Email string `json:"email" validate:"trim,nfc"`
+}
+
+type PasskeyLoginFinishRequest struct {
+	SessionID  string          `json:"session_id"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type CreateUserRequest struct {
+	Email   string `json:"email" validate:"trim,nfc,required,max=255,email"`
+	IsAdmin bool   `json:"is_admin"`
+}
+
+// InvitationResponse carries the link an admin passes on to a new user. The
+// token in it is not stored and cannot be retrieved again.
+type InvitationResponse struct {
+	User          User      `json:"user"`
+	InvitationURL string    `json:"invitation_url"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type UpdateEmailRequest struct {
+	Email string `json:"email" validate:"trim,nfc,required,max=255,email"`
+}
+
+type ImpersonationResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
+
+type ConfirmationRequest struct {
+	Action string `json:"action" validate:"required"`
+	UserID int    `json:"user_id"`
+}
+
+type ConfirmationResponse struct {
+	ConfirmationToken string    `json:"confirmation_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// TodoRequest replaces a todo. Status takes precedence over Completed,
+// which older clients send alone. DueDate is the exception: an update
+// without due_date keeps the current one, and null clears it.
 type TodoRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
+	Title       string  `json:"title" validate:"trim,nfc,required,max=255"`
+	Description string  `json:"description" validate:"trim,nfc,max=10000"`
+	Completed   bool    `json:"completed"`
+	Status      *string `json:"status" validate:"trim,oneof=todo in_progress blocked done cancelled"`
+	DueDate     *string `json:"due_date" validate:"trim,date"`
+	// DueDateSet reports whether the body had due_date, which DueDate alone
+	// cannot tell apart from null.
+	DueDateSet bool `json:"-"`
+}
+
+// UnmarshalJSON decodes like the default, rejecting unknown fields as
+// validation.Decode does, and records whether due_date was present.
+func (r *TodoRequest) UnmarshalJSON(data []byte) error {
+	type fields TodoRequest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode((*fields)(r)); err != nil {
+		return err
+	}
+
+	var present struct {
+		DueDate json.RawMessage `json:"due_date"`
+	}
+	if err := json.Unmarshal(data, &present); err != nil {
+		return err
+	}
+	r.DueDateSet = present.DueDate != nil
+	return nil
+}
+
+type TodoStatusChange struct {
+	ID         int64     `json:"id"`
+	FromStatus *string   `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// MoveTodoRequest places a todo right after AfterID, right before BeforeID,
+// or between the two. At least one of them is required.
+type MoveTodoRequest struct {
+	AfterID  *int `json:"after_id"`
+	BeforeID *int `json:"before_id"`
+}
+
+// Board is a kanban view of the user's todos. Each column shows the todos
+// whose status is the column's status, in the manual todo order.
+type Board struct {
+	ID        int           `json:"id"`
+	Name      string        `json:"name"`
+	Columns   []BoardColumn `json:"columns"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// BoardSummary is a board without its columns, as listed by GET /api/boards.
+type BoardSummary struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BoardColumn has no WIP limit when WIPLimit is nil.
+type BoardColumn struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	WIPLimit *int   `json:"wip_limit"`
+	Position int    `json:"position"`
+	Cards    []Todo `json:"cards"`
+}
+
+type BoardRequest struct {
+	Name string `json:"name" validate:"trim,nfc,required,max=255"`
+}
+
+// BoardColumnRequest creates or replaces a column. A nil Position appends a
+// new column and keeps an existing one in place.
+type BoardColumnRequest struct {
+	Name     string `json:"name" validate:"trim,nfc,required,max=255"`
+	Status   string `json:"status" validate:"trim,required,oneof=todo in_progress blocked done cancelled"`
+	WIPLimit *int   `json:"wip_limit"`
+	Position *int   `json:"position"`
+}
+
+// MoveCardRequest moves a card to ColumnID, placed like MoveTodoRequest
+// among the cards of that column, or last when no anchor is given.
+type MoveCardRequest struct {
+	ColumnID int  `json:"column_id"`
+	AfterID  *int `json:"after_id"`
+	BeforeID *int `json:"before_id"`
+}
+
+// Comment is a Markdown comment on a todo. BodyHTML is Body rendered and
+// sanitized when the comment is read. AuthorID and AuthorEmail are nil once
+// the author's account is deleted.
+type Comment struct {
+	ID          int        `json:"id"`
+	TodoID      int        `json:"todo_id"`
+	AuthorID    *int       `json:"author_id"`
+	AuthorEmail *string    `json:"author_email"`
+	Body        string     `json:"body"`
+	BodyHTML    string     `json:"body_html"`
+	Mentions    []Mention  `json:"mentions"`
+	CreatedAt   time.Time  `json:"created_at"`
+	EditedAt    *time.Time `json:"edited_at"`
+}
+
+type Mention struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body" validate:"trim,nfc,required,max=10000"`
+}
+
+// CommentRevision is an earlier body of an edited comment, as it was until
+// ReplacedAt.
+type CommentRevision struct {
+	ID         int       `json:"id"`
+	Body       string    `json:"body"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// MentionNotice is an entry of the GET /api/me/mentions feed.
+type MentionNotice struct {
+	Comment   Comment `json:"comment"`
+	TodoTitle string  `json:"todo_title"`
+}
+
+// Collaborator is a user who takes part in a todo's comments besides its
+// owner.
+type Collaborator struct {
+	UserID  int       `json:"user_id"`
+	Email   string    `json:"email"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type CollaboratorRequest struct {
+	Email string `json:"email" validate:"trim,nfc,required,max=255,email"`
+}
+
+type UserSettings struct {
+	Timezone string `json:"timezone" validate:"trim,required,timezone"`
+}
+
+// Attachment is a file attached to a todo. URL downloads it until
+// URLExpiresAt; list the attachments again for a fresh one.
+type Attachment struct {
+	ID           int       `json:"id"`
+	TodoID       int       `json:"todo_id"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// StorageUsage is how much of their attachment quota a user has used.
+type StorageUsage struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+}
+
+// Notification is an entry of the in-app notification center. TodoID is set
+// for notifications about a todo.
+type Notification struct {
+	ID        int64      `json:"id"`
+	Kind      string     `json:"kind"`
+	TodoID    *int       `json:"todo_id"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type NotificationList struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+}
+
+// NotificationPreferences choose which notifications a user gets.
+// DueSoonDays is how many days before the due date a todo is announced,
+// from 0 (on the day) to 7.
+type NotificationPreferences struct {
+	DueSoon      bool `json:"due_soon"`
+	DueSoonDays  int  `json:"due_soon_days"`
+	AdminActions bool `json:"admin_actions"`
 }
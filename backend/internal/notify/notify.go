@@ -0,0 +1,79 @@
+// Package notify writes the in-app notifications users read from GET
+// /api/me/notifications. Notifications are plain rows in the notifications
+// table; each kind can be turned off in the user's preferences, which
+// default to on.
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Kinds of notification. Stored as text, so new ones need no migration.
+const (
+	// KindDueSoon reminds the owner of an open todo that is due within the
+	// number of days set in their preferences.
+	KindDueSoon = "due_soon"
+	// KindAdminAction tells a user that an administrator changed their
+	// account.
+	KindAdminAction = "admin_action"
+)
+
+// DefaultDueSoonDays is how many days ahead of a due date users are reminded
+// until they choose otherwise.
+const DefaultDueSoonDays = 1
+
+// Execer is satisfied by both *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// AdminAction notifies userID that an administrator changed their account,
+// unless they turned these notifications off.
+func AdminAction(ctx context.Context, db Execer, userID int, message string) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO notifications (user_id, kind, message)
+		 SELECT $1, $2, $3
+		 WHERE COALESCE((SELECT admin_actions FROM notification_preferences WHERE user_id = $1), TRUE)`,
+		userID, KindAdminAction, message,
+	)
+	return err
+}
+
+// DueSoon notifies the owners of open todos due between today and their
+// reminder horizon, with today taken in each owner's time zone. A todo is
+// announced once per due date, so running it again only picks up todos that
+// came into range since, including ones whose due date moved.
+func DueSoon(ctx context.Context, db *sql.DB) (int64, error) {
+	result, err := db.ExecContext(ctx,
+		`INSERT INTO notifications (user_id, kind, todo_id, message, dedup_key)
+		 SELECT t.user_id, $1, t.id,
+		        CASE WHEN t.due_date = d.today THEN 'Due today: ' ELSE 'Due ' || to_char(t.due_date, 'YYYY-MM-DD') || ': ' END || t.title,
+		        'due_soon:' || t.id || ':' || to_char(t.due_date, 'YYYY-MM-DD')
+		 FROM todos t
+		 JOIN users u ON u.id = t.user_id
+		 LEFT JOIN notification_preferences p ON p.user_id = t.user_id
+		 CROSS JOIN LATERAL (SELECT (CURRENT_TIMESTAMP AT TIME ZONE u.timezone)::date AS today) d
+		 WHERE t.due_date IS NOT NULL
+		   AND t.status NOT IN ('done', 'cancelled')
+		   AND u.suspended_at IS NULL
+		   AND COALESCE(p.due_soon, TRUE)
+		   AND t.due_date BETWEEN d.today AND d.today + COALESCE(p.due_soon_days, $2)
+		 ON CONFLICT (user_id, dedup_key) DO NOTHING`,
+		KindDueSoon, DefaultDueSoonDays,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Prune deletes notifications older than retention, read or not.
+func Prune(ctx context.Context, db *sql.DB, retention time.Duration) error {
+	_, err := db.ExecContext(ctx,
+		"DELETE FROM notifications WHERE created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'",
+		int64(retention.Seconds()),
+	)
+	return err
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"todo-app/backend/internal/sqltest"
+)
+
+// The queries run in PostgreSQL, so these tests check what the fake can see:
+// the arguments, and that each rule the doc comments promise is in the SQL.
+
+func TestDueSoon(t *testing.T) {
+	fake, db := sqltest.New(t)
+	var args []driver.Value
+	fake.On("INSERT INTO notifications", func(a []driver.Value) sqltest.Result {
+		args = a
+		return sqltest.Result{RowsAffected: 3}
+	})
+
+	n, err := DueSoon(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("DueSoon = %d, want 3", n)
+	}
+	query := fake.Calls()[0]
+	if len(args) != 2 || args[0] != KindDueSoon || args[1] != int64(DefaultDueSoonDays) {
+		t.Errorf("args = %v, want [%s %d]", args, KindDueSoon, DefaultDueSoonDays)
+	}
+
+	rules := []struct {
+		name     string
+		fragment string
+	}{
+		{"today is the owner's date", "(CURRENT_TIMESTAMP AT TIME ZONE u.timezone)::date AS today"},
+		{"window runs from today to the horizon", "t.due_date BETWEEN d.today AND d.today + COALESCE(p.due_soon_days, $2)"},
+		{"reminders default to on", "COALESCE(p.due_soon, TRUE)"},
+		{"closed todos are skipped", "t.status NOT IN ('done', 'cancelled')"},
+		{"suspended owners are skipped", "u.suspended_at IS NULL"},
+		{"dedup key holds the due date", "'due_soon:' || t.id || ':' || to_char(t.due_date, 'YYYY-MM-DD')"},
+		{"repeats are dropped", "ON CONFLICT (user_id, dedup_key) DO NOTHING"},
+	}
+	for _, rule := range rules {
+		if !strings.Contains(query, rule.fragment) {
+			t.Errorf("%s: query lacks %q", rule.name, rule.fragment)
+		}
+	}
+}
+
+func TestDueSoonError(t *testing.T) {
+	fake, db := sqltest.New(t)
+	errDown := errors.New("connection refused")
+	fake.On("INSERT INTO notifications", func([]driver.Value) sqltest.Result {
+		return sqltest.Result{Err: errDown}
+	})
+
+	if n, err := DueSoon(context.Background(), db); !errors.Is(err, errDown) || n != 0 {
+		t.Errorf("DueSoon = %d, %v, want 0, %v", n, err, errDown)
+	}
+}
+
+func TestAdminActionRespectsPreference(t *testing.T) {
+	fake, db := sqltest.New(t)
+	var args []driver.Value
+	fake.On("INSERT INTO notifications", func(a []driver.Value) sqltest.Result {
+		args = a
+		return sqltest.Result{RowsAffected: 1}
+	})
+
+	if err := AdminAction(context.Background(), db, 7, "Your account was suspended"); err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 3 || args[0] != int64(7) || args[1] != KindAdminAction || args[2] != "Your account was suspended" {
+		t.Errorf("args = %v", args)
+	}
+	if q := fake.Calls()[0]; !strings.Contains(q, "COALESCE((SELECT admin_actions FROM notification_preferences WHERE user_id = $1), TRUE)") {
+		t.Errorf("query does not check the preference: %s", q)
+	}
+}
+
+func TestPruneRetention(t *testing.T) {
+	tests := []struct {
+		retention time.Duration
+		seconds   int64
+	}{
+		{90 * 24 * time.Hour, 90 * 24 * 60 * 60},
+		{time.Hour, 3600},
+		{1500 * time.Millisecond, 1},
+	}
+	for _, tt := range tests {
+		fake, db := sqltest.New(t)
+		var got driver.Value
+		fake.On("DELETE FROM notifications WHERE created_at <", func(args []driver.Value) sqltest.Result {
+			got = args[0]
+			return sqltest.Result{}
+		})
+
+		if err := Prune(context.Background(), db, tt.retention); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.seconds {
+			t.Errorf("Prune(%s) deleted older than %v seconds, want %d", tt.retention, got, tt.seconds)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "todo-app"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "http://localhost:8081/api/oidc/callback"
+)
+
+// fakeProvider is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that enforces PKCE and client authentication.
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	// codes maps an issued authorization code to its PKCE challenge.
+	codes map[string]string
+	// claims are merged into every ID token the provider issues.
+	claims jwt.MapClaims
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fp := &fakeProvider{t: t, key: key, kid: "key-1", codes: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", fp.discovery)
+	mux.HandleFunc("/jwks", fp.jwks)
+	mux.HandleFunc("/token", fp.token)
+	fp.server = httptest.NewServer(mux)
+	t.Cleanup(fp.server.Close)
+	return fp
+}
+
+func (fp *fakeProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 fp.server.URL,
+		"authorization_endpoint": fp.server.URL + "/authorize",
+		"token_endpoint":         fp.server.URL + "/token",
+		"jwks_uri":               fp.server.URL + "/jwks",
+	})
+}
+
+func (fp *fakeProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": fp.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(fp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(fp.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (fp *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	code := r.PostFormValue("code")
+	challenge, ok := fp.codes[code]
+	if !ok || CodeChallenge(r.PostFormValue("code_verifier")) != challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	delete(fp.codes, code)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     fp.idToken(nil),
+	})
+}
+
+// authorize simulates the user approving the login at the provider and
+// returns the code that would be passed to the redirect URL.
+func (fp *fakeProvider) authorize(authURL string) (code, state string) {
+	fp.t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		fp.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		fp.t.Fatalf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+	}
+
+	code = "code-" + q.Get("state")
+	fp.codes[code] = q.Get("code_challenge")
+	fp.claims["nonce"] = q.Get("nonce")
+	return code, q.Get("state")
+}
+
+func (fp *fakeProvider) idToken(override jwt.MapClaims) string {
+	fp.t.Helper()
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            fp.server.URL,
+		"sub":            "user-123",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"email":          "alice@example.com",
+		"email_verified": true,
+	}
+	for k, v := range fp.claims {
+		claims[k] = v
+	}
+	for k, v := range override {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = fp.kid
+	signed, err := token.SignedString(fp.key)
+	if err != nil {
+		fp.t.Fatal(err)
+	}
+	return signed
+}
+
+func newTestProvider(t *testing.T, fp *fakeProvider, adminGroups ...string) *Provider {
+	t.Helper()
+
+	fp.claims = jwt.MapClaims{}
+	p, err := NewProvider(context.Background(), Config{
+		IssuerURL:    fp.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		AdminGroups:  adminGroups,
+	}, fp.server.Client())
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return p
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := newTestProvider(t, fp, "todo-admins")
+	fp.claims["groups"] = []string{"staff", "todo-admins"}
+	fp.claims["amr"] = []string{"pwd", "mfa"}
+
+	verifier, _ := RandomString()
+	nonce, _ := RandomString()
+	authURL := p.AuthCodeURL("state-1", nonce, verifier)
+	if !strings.HasPrefix(authURL, fp.server.URL+"/authorize?") {
+		t.Fatalf("AuthCodeURL = %q", authURL)
+	}
+
+	code, state := fp.authorize(authURL)
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+
+	claims, err := p.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	if claims.Subject != "user-123" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if isAdmin, managed := p.IsAdmin(claims); !isAdmin || !managed {
+		t.Errorf("IsAdmin = %v, %v; want true, true", isAdmin, managed)
+	}
+	if !claims.MFA() {
+		t.Error("MFA() = false, want true")
+	}
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := newTestProvider(t, fp)
+
+	verifier, _ := RandomString()
+	code, _ := fp.authorize(p.AuthCodeURL("state", "nonce", verifier))
+
+	if _, err := p.Exchange(context.Background(), code, "wrong-verifier", "nonce"); err == nil {
+		t.Fatal("Exchange succeeded with the wrong PKCE verifier")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := newTestProvider(t, fp)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": fp.server.URL, "sub": "user-123", "aud": testClientID, "nonce": "n",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = fp.kid
+	forgedToken, err := forged.SignedString(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		nonce   string
+		wantErr error
+	}{
+		{"valid", fp.idToken(jwt.MapClaims{"nonce": "n"}), "n", nil},
+		{"nonce mismatch", fp.idToken(jwt.MapClaims{"nonce": "other"}), "n", ErrNonceMismatch},
+		{"missing nonce", fp.idToken(nil), "n", ErrNonceMismatch},
+		{"wrong audience", fp.idToken(jwt.MapClaims{"nonce": "n", "aud": "someone-else"}), "n", ErrInvalidIDToken},
+		{"wrong issuer", fp.idToken(jwt.MapClaims{"nonce": "n", "iss": "https://evil.example"}), "n", ErrInvalidIDToken},
+		{"expired", fp.idToken(jwt.MapClaims{"nonce": "n", "exp": time.Now().Add(-time.Minute).Unix()}), "n", ErrInvalidIDToken},
+		{"bad signature", forgedToken, "n", ErrInvalidIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.VerifyIDToken(context.Background(), tt.token, tt.nonce)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyIDToken error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenAfterKeyRotation(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := newTestProvider(t, fp)
+
+	if _, err := p.VerifyIDToken(context.Background(), fp.idToken(jwt.MapClaims{"nonce": "n"}), "n"); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.key, fp.kid = newKey, "key-2"
+
+	if _, err := p.VerifyIDToken(context.Background(), fp.idToken(jwt.MapClaims{"nonce": "n"}), "n"); err != nil {
+		t.Fatalf("VerifyIDToken after rotation: %v", err)
+	}
+}
+
+func TestNewProviderRejectsIssuerMismatch(t *testing.T) {
+	fp := newFakeProvider(t)
+
+	_, err := NewProvider(context.Background(), Config{
+		IssuerURL: fp.server.URL + "/",
+		ClientID:  testClientID,
+	}, fp.server.Client())
+	if err == nil {
+		t.Fatal("NewProvider accepted a discovery document for a different issuer")
+	}
+}
+
+func TestIsAdminWithoutMapping(t *testing.T) {
+	fp := newFakeProvider(t)
+	p := newTestProvider(t, fp)
+
+	if _, managed := p.IsAdmin(&Claims{Groups: []string{"todo-admins"}}); managed {
+		t.Error("IsAdmin reported a managed flag with no admin groups configured")
+	}
+}
@@ -0,0 +1,544 @@
+// Package codegen generates a typed Go client from the OpenAPI document.
+//
+// It supports the subset of OpenAPI used by openapi.json: component schemas
+// that are objects, arrays or a oneOf of object references, path parameters,
+// optional scalar query parameters, string header parameters, JSON
+// request bodies and JSON or binary responses. Operations marked with
+// x-codegen-skip are left out. Anything else is reported as an error rather
+// than generated incorrectly.
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description"`
+	Parameters  []parameter          `json:"parameters"`
+	RequestBody *requestBody         `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+	Skip        bool                 `json:"x-codegen-skip"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 schemaType         `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	OneOf                []*schema          `json:"oneOf"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+}
+
+// schemaType is "type", which OpenAPI 3.1 allows to be a string or a list
+// such as ["string", "null"].
+type schemaType struct {
+	Name     string
+	Nullable bool
+}
+
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &t.Name); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if name == "null" {
+			t.Nullable = true
+		} else if t.Name == "" {
+			t.Name = name
+		} else {
+			return fmt.Errorf("unsupported union type %v", names)
+		}
+	}
+	return nil
+}
+
+const schemaPrefix = "#/components/schemas/"
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+// Generate returns gofmt-formatted Go source for package pkg.
+func Generate(spec []byte, pkg string) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse OpenAPI document: %w", err)
+	}
+
+	g := &generator{doc: &doc}
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := g.schemaType(name, doc.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, method := range methodOrder {
+			op, ok := doc.Paths[path][method]
+			if !ok || op.Skip {
+				continue
+			}
+			if err := g.operation(path, method, op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+
+	body := g.buf.String()
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by cmd/openapi-client from internal/openapi/openapi.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", pkg)
+	for _, imp := range []struct{ path, use string }{
+		{"context", "context."},
+		{"encoding/json", "json."},
+		{"fmt", "fmt."},
+		{"net/http", "http."},
+		{"net/url", "url."},
+		{"time", "time."},
+	} {
+		if strings.Contains(body, imp.use) {
+			fmt.Fprintf(&out, "%q\n", imp.path)
+		}
+	}
+	fmt.Fprintf(&out, ")\n\n%s", body)
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	doc *document
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) comment(text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		g.printf("// %s\n", line)
+	}
+}
+
+func (g *generator) schemaType(name string, s *schema) error {
+	typeName := exported(name)
+	if s.Description != "" {
+		g.comment(typeName + " is " + lowerFirst(s.Description))
+	}
+
+	switch {
+	case len(s.OneOf) > 0:
+		// Variants are embedded so that whichever one the server returned is
+		// populated and the others stay zero.
+		g.printf("type %s struct {\n", typeName)
+		for _, variant := range s.OneOf {
+			if !strings.HasPrefix(variant.Ref, schemaPrefix) {
+				return fmt.Errorf("oneOf variants must be schema references")
+			}
+			g.printf("%s\n", exported(strings.TrimPrefix(variant.Ref, schemaPrefix)))
+		}
+		g.printf("}\n\n")
+		return nil
+	case s.Type.Name == "object" && len(s.Properties) > 0:
+		required := make(map[string]bool, len(s.Required))
+		for _, r := range s.Required {
+			required[r] = true
+		}
+		props := make([]string, 0, len(s.Properties))
+		for prop := range s.Properties {
+			props = append(props, prop)
+		}
+		sort.Strings(props)
+
+		g.printf("type %s struct {\n", typeName)
+		for _, prop := range props {
+			ps := s.Properties[prop]
+			goType, err := g.goType(ps)
+			if err != nil {
+				return fmt.Errorf("property %s: %w", prop, err)
+			}
+			if ps.Description != "" {
+				g.comment(ps.Description)
+			}
+			tag := prop
+			if !required[prop] {
+				tag += ",omitempty"
+			}
+			g.printf("%s %s `json:%q`\n", exported(prop), goType, tag)
+		}
+		g.printf("}\n\n")
+		return nil
+	default:
+		goType, err := g.goType(s)
+		if err != nil {
+			return err
+		}
+		g.printf("type %s = %s\n\n", typeName, goType)
+		return nil
+	}
+}
+
+func (g *generator) goType(s *schema) (string, error) {
+	if s.Ref != "" {
+		if !strings.HasPrefix(s.Ref, schemaPrefix) {
+			return "", fmt.Errorf("unsupported reference %q", s.Ref)
+		}
+		name := strings.TrimPrefix(s.Ref, schemaPrefix)
+		if _, ok := g.doc.Components.Schemas[name]; !ok {
+			return "", fmt.Errorf("unresolved reference %q", s.Ref)
+		}
+		return exported(name), nil
+	}
+
+	var t string
+	switch s.Type.Name {
+	case "string":
+		t = "string"
+		if s.Format == "date-time" {
+			t = "time.Time"
+		}
+	case "integer":
+		t = "int"
+		if s.Format == "int64" {
+			t = "int64"
+		}
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(s.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		switch {
+		case s.AdditionalProperties != nil:
+			value, err := g.goType(s.AdditionalProperties)
+			if err != nil {
+				return "", err
+			}
+			t = "map[string]" + value
+		case len(s.Properties) == 0:
+			// Free-form objects such as WebAuthn options are passed through.
+			t = "json.RawMessage"
+		default:
+			return "", fmt.Errorf("inline objects must be component schemas")
+		}
+	default:
+		return "", fmt.Errorf("unsupported type %q", s.Type.Name)
+	}
+	if s.Type.Nullable {
+		t = "*" + t
+	}
+	return t, nil
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func (g *generator) operation(path, method string, op *operation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("missing operationId")
+	}
+
+	var args []string
+	var pathArgs []string
+	var queryParams, headerParams []parameter
+	params := make(map[string]parameter)
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			params[p.Name] = p
+		case "query":
+			queryParams = append(queryParams, p)
+		case "header":
+			headerParams = append(headerParams, p)
+		default:
+			return fmt.Errorf("unsupported %s parameter %q", p.In, p.Name)
+		}
+	}
+	pathFormat := pathParam.ReplaceAllStringFunc(path, func(m string) string {
+		return "%s"
+	})
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		p, ok := params[m[1]]
+		if !ok {
+			return fmt.Errorf("path parameter %q is not declared", m[1])
+		}
+		name := unexported(p.Name)
+		goType, err := g.goType(p.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+		args = append(args, name+" "+goType)
+		if goType == "string" {
+			pathArgs = append(pathArgs, "url.PathEscape("+name+")")
+		} else {
+			pathArgs = append(pathArgs, "url.PathEscape(fmt.Sprint("+name+"))")
+		}
+	}
+
+	name := exported(op.OperationID)
+	query, err := g.queryParams(name, queryParams)
+	if err != nil {
+		return err
+	}
+	if query != "" {
+		args = append(args, "params *"+name+"Params")
+	}
+
+	header := "nil"
+	var headerCode strings.Builder
+	for i, p := range headerParams {
+		goType, err := g.goType(p.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+		if goType != "string" {
+			return fmt.Errorf("header parameter %q must be a string", p.Name)
+		}
+		// X-Confirmation-Token becomes confirmationToken. Optional headers
+		// are left out when the argument is empty.
+		arg := unexported(strings.TrimPrefix(p.Name, "X-"))
+		args = append(args, arg+" string")
+		if i == 0 {
+			headerCode.WriteString("header := http.Header{}\n")
+			header = "header"
+		}
+		if p.Required {
+			fmt.Fprintf(&headerCode, "header.Set(%q, %s)\n", p.Name, arg)
+		} else {
+			fmt.Fprintf(&headerCode, "if %s != \"\" {\nheader.Set(%q, %s)\n}\n", arg, p.Name, arg)
+		}
+	}
+
+	body := "nil"
+	if op.RequestBody != nil {
+		media, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			return fmt.Errorf("request body must be application/json")
+		}
+		goType, err := g.goType(media.Schema)
+		if err != nil {
+			return fmt.Errorf("request body: %w", err)
+		}
+		args = append(args, "body *"+goType)
+		body = "body"
+		if !op.RequestBody.Required {
+			// A nil optional body is sent as no body at all.
+			body = "optionalBody(body)"
+		}
+	}
+
+	status, resp := successResponse(op)
+	if resp == nil {
+		return fmt.Errorf("no 2xx response")
+	}
+
+	var result, call string
+	switch {
+	case len(resp.Content) == 0:
+		call = fmt.Sprintf("return c.do(ctx, %q, path, %s, %s, nil)", strings.ToUpper(method), header, body)
+		result = "error"
+	case resp.Content["application/json"].Schema != nil:
+		goType, err := g.goType(resp.Content["application/json"].Schema)
+		if err != nil {
+			return fmt.Errorf("response: %w", err)
+		}
+		if !strings.HasPrefix(goType, "[]") && !strings.HasPrefix(goType, "map[") {
+			goType = "*" + goType
+		}
+		result = "(" + goType + ", error)"
+		call = fmt.Sprintf("var out %s\nif err := c.do(ctx, %q, path, %s, %s, &out); err != nil {\nreturn nil, err\n}\nreturn out, nil",
+			strings.TrimPrefix(goType, "*"), strings.ToUpper(method), header, body)
+		if strings.HasPrefix(goType, "*") {
+			call = strings.Replace(call, "return out, nil", "return &out, nil", 1)
+		}
+	default:
+		result = "([]byte, error)"
+		call = fmt.Sprintf("return c.doRaw(ctx, %q, path, %s, %s)", strings.ToUpper(method), header, body)
+	}
+
+	g.printf("// %s calls %s %s and expects %s.\n", name, strings.ToUpper(method), path, status)
+	if op.Summary != "" {
+		g.printf("//\n")
+		g.comment(strings.TrimSuffix(op.Summary, ".") + ".")
+	}
+	if op.Description != "" {
+		g.printf("//\n")
+		g.comment(op.Description)
+	}
+	g.printf("func (c *Client) %s(ctx context.Context%s) %s {\n", name, prefixComma(args), result)
+	if len(pathArgs) > 0 {
+		g.printf("path := fmt.Sprintf(%q, %s)\n", pathFormat, strings.Join(pathArgs, ", "))
+	} else {
+		g.printf("path := %q\n", path)
+	}
+	g.printf("%s%s%s\n}\n\n", query, headerCode.String(), call)
+	return nil
+}
+
+// queryParams emits a struct holding the query parameters of the operation
+// and returns the code that appends them to path. Zero values are not sent,
+// so every query parameter must be optional.
+func (g *generator) queryParams(opName string, params []parameter) (string, error) {
+	if len(params) == 0 {
+		return "", nil
+	}
+
+	typeName := opName + "Params"
+	var code strings.Builder
+	g.printf("// %s holds the query parameters of %s. Zero values are not sent.\n", typeName, opName)
+	g.printf("type %s struct {\n", typeName)
+	code.WriteString("if params != nil {\nquery := url.Values{}\n")
+	for _, p := range params {
+		if p.Required {
+			return "", fmt.Errorf("query parameter %q must be optional", p.Name)
+		}
+		goType, err := g.goType(p.Schema)
+		if err != nil {
+			return "", fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+		field := exported(p.Name)
+		if p.Description != "" {
+			g.comment(p.Description)
+		}
+		g.printf("%s %s\n", field, goType)
+
+		var cond, value string
+		switch goType {
+		case "string":
+			cond, value = "params."+field+` != ""`, "params."+field
+		case "int", "int64", "float64":
+			cond, value = "params."+field+" != 0", "fmt.Sprint(params."+field+")"
+		case "bool":
+			cond, value = "params."+field, `"true"`
+		case "time.Time":
+			cond, value = "!params."+field+".IsZero()", "params."+field+".Format(time.RFC3339)"
+		default:
+			return "", fmt.Errorf("unsupported query parameter type %s", goType)
+		}
+		fmt.Fprintf(&code, "if %s {\nquery.Set(%q, %s)\n}\n", cond, p.Name, value)
+	}
+	g.printf("}\n\n")
+	code.WriteString("if len(query) > 0 {\npath += \"?\" + query.Encode()\n}\n}\n")
+	return code.String(), nil
+}
+
+func successResponse(op *operation) (string, *response) {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return "", nil
+	}
+	sort.Strings(codes)
+	return codes[0], op.Responses[codes[0]]
+}
+
+func prefixComma(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(args, ", ")
+}
+
+// initialisms are upper-cased in Go identifiers, following Go naming style.
+var initialisms = map[string]string{
+	"api": "API", "id": "ID", "ip": "IP", "json": "JSON", "jwt": "JWT", "ms": "MS",
+	"oidc": "OIDC", "png": "PNG", "qr": "QR", "totp": "TOTP", "url": "URL", "uri": "URI",
+}
+
+// exported converts snake_case or an operationId to an exported identifier.
+func exported(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == '.' })
+	var b strings.Builder
+	for _, part := range parts {
+		if up, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(up)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// lowerFirst lower-cases the first letter of a sentence unless it starts
+// an acronym such as "RFC".
+func lowerFirst(s string) string {
+	if len(s) > 1 && s[1] >= 'A' && s[1] <= 'Z' {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func unexported(name string) string {
+	id := exported(name)
+	if up, ok := initialisms[strings.ToLower(name)]; ok && up == id {
+		return strings.ToLower(id)
+	}
+	return strings.ToLower(id[:1]) + id[1:]
+}
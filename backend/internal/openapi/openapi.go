@@ -0,0 +1,29 @@
+// Package openapi serves the OpenAPI 3.1 description of the REST API.
+//
+// openapi.json is maintained by hand next to the routes in cmd/api. The
+// contract test there fails when the two drift, and the typed client in
+// package client is generated from it with go generate.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.json
+var Document []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// Spec serves the OpenAPI document.
+func Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", Document)
+}
+
+// Docs serves a Redoc page rendering the document.
+func Docs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
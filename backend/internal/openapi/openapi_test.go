@@ -0,0 +1,239 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/apperror"
+	"todo-app/backend/internal/audit"
+	"todo-app/backend/internal/health"
+	"todo-app/backend/internal/models"
+	"todo-app/backend/internal/ratelimit"
+	"todo-app/backend/internal/stats"
+)
+
+// goTypes maps the x-go-type of a component schema to the Go type whose JSON
+// encoding it describes.
+var goTypes = map[string]reflect.Type{
+	"models.User":                       reflect.TypeOf(models.User{}),
+	"models.Todo":                       reflect.TypeOf(models.Todo{}),
+	"models.TodoRequest":                reflect.TypeOf(models.TodoRequest{}),
+	"models.TodoStatusChange":           reflect.TypeOf(models.TodoStatusChange{}),
+	"models.MoveTodoRequest":            reflect.TypeOf(models.MoveTodoRequest{}),
+	"models.Board":                      reflect.TypeOf(models.Board{}),
+	"models.BoardSummary":               reflect.TypeOf(models.BoardSummary{}),
+	"models.BoardColumn":                reflect.TypeOf(models.BoardColumn{}),
+	"models.BoardRequest":               reflect.TypeOf(models.BoardRequest{}),
+	"models.BoardColumnRequest":         reflect.TypeOf(models.BoardColumnRequest{}),
+	"models.MoveCardRequest":            reflect.TypeOf(models.MoveCardRequest{}),
+	"models.Comment":                    reflect.TypeOf(models.Comment{}),
+	"models.Mention":                    reflect.TypeOf(models.Mention{}),
+	"models.CommentRequest":             reflect.TypeOf(models.CommentRequest{}),
+	"models.CommentRevision":            reflect.TypeOf(models.CommentRevision{}),
+	"models.MentionNotice":              reflect.TypeOf(models.MentionNotice{}),
+	"models.Collaborator":               reflect.TypeOf(models.Collaborator{}),
+	"models.CollaboratorRequest":        reflect.TypeOf(models.CollaboratorRequest{}),
+	"models.Attachment":                 reflect.TypeOf(models.Attachment{}),
+	"models.StorageUsage":               reflect.TypeOf(models.StorageUsage{}),
+	"models.Notification":               reflect.TypeOf(models.Notification{}),
+	"models.NotificationList":           reflect.TypeOf(models.NotificationList{}),
+	"models.NotificationPreferences":    reflect.TypeOf(models.NotificationPreferences{}),
+	"models.RegisterRequest":            reflect.TypeOf(models.RegisterRequest{}),
+	"models.LoginRequest":               reflect.TypeOf(models.LoginRequest{}),
+	"models.LoginResponse":              reflect.TypeOf(models.LoginResponse{}),
+	"models.TwoFactorChallengeResponse": reflect.TypeOf(models.TwoFactorChallengeResponse{}),
+	"models.TwoFactorLoginRequest":      reflect.TypeOf(models.TwoFactorLoginRequest{}),
+	"models.TwoFactorSetupResponse":     reflect.TypeOf(models.TwoFactorSetupResponse{}),
+	"models.TwoFactorCodeRequest":       reflect.TypeOf(models.TwoFactorCodeRequest{}),
+	"models.TwoFactorDisableRequest":    reflect.TypeOf(models.TwoFactorDisableRequest{}),
+	"models.ChangePasswordRequest":      reflect.TypeOf(models.ChangePasswordRequest{}),
+	"models.Passkey":                    reflect.TypeOf(models.Passkey{}),
+	"models.PasskeyRegistrationRequest": reflect.TypeOf(models.PasskeyRegistrationRequest{}),
+	"models.PasskeyLoginBeginRequest":   reflect.TypeOf(models.PasskeyLoginBeginRequest{}),
+	"models.PasskeyLoginFinishRequest":  reflect.TypeOf(models.PasskeyLoginFinishRequest{}),
+	"models.CreateUserRequest":          reflect.TypeOf(models.CreateUserRequest{}),
+	"models.InvitationResponse":         reflect.TypeOf(models.InvitationResponse{}),
+	"models.AcceptInvitationRequest":    reflect.TypeOf(models.AcceptInvitationRequest{}),
+	"models.UpdateEmailRequest":         reflect.TypeOf(models.UpdateEmailRequest{}),
+	"models.ImpersonationResponse":      reflect.TypeOf(models.ImpersonationResponse{}),
+	"models.ConfirmationRequest":        reflect.TypeOf(models.ConfirmationRequest{}),
+	"models.ConfirmationResponse":       reflect.TypeOf(models.ConfirmationResponse{}),
+	"stats.Dashboard":                   reflect.TypeOf(stats.Dashboard{}),
+	"stats.Totals":                      reflect.TypeOf(stats.Totals{}),
+	"stats.ActiveUsers":                 reflect.TypeOf(stats.ActiveUsers{}),
+	"stats.Point":                       reflect.TypeOf(stats.Point{}),
+	"stats.Band":                        reflect.TypeOf(stats.Band{}),
+	"stats.Account":                     reflect.TypeOf(stats.Account{}),
+	"stats.UserStats":                   reflect.TypeOf(stats.UserStats{}),
+	"stats.Streaks":                     reflect.TypeOf(stats.Streaks{}),
+	"stats.BurndownPoint":               reflect.TypeOf(stats.BurndownPoint{}),
+	"stats.WeekdayCount":                reflect.TypeOf(stats.WeekdayCount{}),
+	"models.UserSettings":               reflect.TypeOf(models.UserSettings{}),
+	"audit.Entry":                       reflect.TypeOf(audit.Entry{}),
+	"ratelimit.Lockout":                 reflect.TypeOf(ratelimit.Lockout{}),
+	"health.Report":                     reflect.TypeOf(health.Report{}),
+	"health.CheckResult":                reflect.TypeOf(health.CheckResult{}),
+	"apperror.Problem":                  reflect.TypeOf(apperror.Problem{}),
+	"apperror.FieldError":               reflect.TypeOf(apperror.FieldError{}),
+}
+
+type testSchema struct {
+	GoType     string                     `json:"x-go-type"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	Required   []string                   `json:"required"`
+}
+
+func TestSchemasMatchGoTypes(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]testSchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(Document, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range doc.Components.Schemas {
+		if s.GoType == "" {
+			continue
+		}
+		typ, ok := goTypes[s.GoType]
+		if !ok {
+			t.Errorf("schema %s: x-go-type %s is not listed in goTypes", name, s.GoType)
+			continue
+		}
+
+		fields := jsonFields(typ)
+		for field := range fields {
+			if _, ok := s.Properties[field]; !ok {
+				t.Errorf("schema %s: %s.%s is not documented", name, s.GoType, field)
+			}
+		}
+		for prop := range s.Properties {
+			if !fields[prop] {
+				t.Errorf("schema %s: property %s does not exist on %s", name, prop, s.GoType)
+			}
+		}
+		for _, req := range s.Required {
+			if _, ok := s.Properties[req]; !ok {
+				t.Errorf("schema %s: required property %s is not defined", name, req)
+			}
+		}
+	}
+}
+
+func jsonFields(typ reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+var pathTemplateParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func TestDocumentIsConsistent(t *testing.T) {
+	var raw map[string]any
+	if err := json.Unmarshal(Document, &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every $ref must resolve within the document.
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok && resolve(raw, ref) == nil {
+				t.Errorf("unresolved $ref %s", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(raw)
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			Responses map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(Document, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]string)
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		var templated []string
+		for _, m := range pathTemplateParam.FindAllStringSubmatch(path, -1) {
+			templated = append(templated, m[1])
+		}
+		for method, op := range doc.Paths[path] {
+			where := strings.ToUpper(method) + " " + path
+			if op.OperationID == "" {
+				t.Errorf("%s: missing operationId", where)
+			} else if prev, dup := seen[op.OperationID]; dup {
+				t.Errorf("%s: operationId %s already used by %s", where, op.OperationID, prev)
+			}
+			seen[op.OperationID] = where
+
+			var declared []string
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					declared = append(declared, p.Name)
+				}
+			}
+			sort.Strings(declared)
+			want := append([]string(nil), templated...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(declared, want) {
+				t.Errorf("%s: path parameters %v, template has %v", where, declared, want)
+			}
+
+			if len(op.Responses) == 0 {
+				t.Errorf("%s: no responses", where)
+			}
+		}
+	}
+}
+
+// resolve follows a local JSON pointer such as #/components/schemas/User.
+func resolve(doc map[string]any, ref string) any {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var cur any = doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
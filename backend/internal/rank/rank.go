@@ -0,0 +1,113 @@
+// Package rank generates fractional position keys. A key is a base-62
+// fraction written without its leading "0.", so keys compare as plain
+// strings (byte order, as with COLLATE "C") and a key can always be made
+// between two others. Moving an item therefore only rewrites its own key.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+// digits are in byte order, which is also their value order.
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// MaxLength is the longest key worth storing. Repeated inserts at the same
+// spot grow keys by about one digit every six inserts; longer keys are a
+// sign that the list should be spread out again with Spread.
+const MaxLength = 32
+
+// ErrNoRoom is returned by Between when lo is not below hi or either key is
+// malformed, which happens when two items share a key.
+var ErrNoRoom = errors.New("rank: no key between the given keys")
+
+// Between returns a key that sorts after lo and before hi. An empty lo is
+// the start of the key space and an empty hi its end.
+func Between(lo, hi string) (string, error) {
+	if !valid(lo) || !valid(hi) || (hi != "" && lo >= hi) {
+		return "", ErrNoRoom
+	}
+	return midpoint(lo, hi), nil
+}
+
+// Spread returns n keys in ascending order, evenly spaced and as short as
+// possible while leaving room between neighbours.
+func Spread(n int) []string {
+	width, space := 1, int64(len(digits))
+	for space < 2*int64(n+1) {
+		width++
+		space *= int64(len(digits))
+	}
+
+	keys := make([]string, n)
+	buf := make([]byte, width)
+	for i := range keys {
+		v := int64(i+1) * space / int64(n+1)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = digits[v%int64(len(digits))]
+			v /= int64(len(digits))
+		}
+		// Trailing zeros do not change the value and would leave no room
+		// below the key.
+		keys[i] = strings.TrimRight(string(buf), "0")
+	}
+	return keys
+}
+
+func valid(key string) bool {
+	if strings.HasSuffix(key, "0") {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// midpoint assumes lo < hi (or hi == "") and that neither ends in zero.
+func midpoint(lo, hi string) string {
+	if hi != "" {
+		// Keep the digits both share, treating lo as padded with zeros.
+		n := 0
+		for n < len(hi) && digitAt(lo, n) == hi[n] {
+			n++
+		}
+		if n > 0 {
+			return hi[:n] + midpoint(tail(lo, n), hi[n:])
+		}
+	}
+
+	a := 0
+	if lo != "" {
+		a = strings.IndexByte(digits, lo[0])
+	}
+	b := len(digits)
+	if hi != "" {
+		b = strings.IndexByte(digits, hi[0])
+	}
+	if b-a > 1 {
+		return string(digits[(a+b)/2])
+	}
+	// The first digits are adjacent. A longer hi can be cut short; otherwise
+	// the key continues after lo's first digit.
+	if len(hi) > 1 {
+		return hi[:1]
+	}
+	return string(digits[a]) + midpoint(tail(lo, 1), "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return digits[0]
+}
+
+func tail(key string, n int) string {
+	if n >= len(key) {
+		return ""
+	}
+	return key[n:]
+}
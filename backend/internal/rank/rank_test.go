@@ -0,0 +1,104 @@
+package rank
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		lo, hi string
+	}{
+		{"", ""},
+		{"", "1"},
+		{"", "01"},
+		{"V", ""},
+		{"z", ""},
+		{"zz", ""},
+		{"1", "2"},
+		{"1", "11"},
+		{"0V", "1"},
+		{"A", "B"},
+		{"az", "b"},
+		{"a1", "a2"},
+		{"a", "az"},
+	}
+	for _, tt := range tests {
+		got, err := Between(tt.lo, tt.hi)
+		if err != nil {
+			t.Errorf("Between(%q, %q): %v", tt.lo, tt.hi, err)
+			continue
+		}
+		if !valid(got) || got <= tt.lo || (tt.hi != "" && got >= tt.hi) {
+			t.Errorf("Between(%q, %q) = %q", tt.lo, tt.hi, got)
+		}
+	}
+}
+
+func TestBetweenNoRoom(t *testing.T) {
+	for _, tt := range [][2]string{{"a", "a"}, {"b", "a"}, {"a0", "b"}, {"a", "b-"}} {
+		if _, err := Between(tt[0], tt[1]); err != ErrNoRoom {
+			t.Errorf("Between(%q, %q) error = %v, want ErrNoRoom", tt[0], tt[1], err)
+		}
+	}
+}
+
+func TestRepeatedInsertsStayOrdered(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 2000; i++ {
+		at := rng.Intn(len(keys) + 1)
+		var lo, hi string
+		if at > 0 {
+			lo = keys[at-1]
+		}
+		if at < len(keys) {
+			hi = keys[at]
+		}
+		key, err := Between(lo, hi)
+		if err != nil {
+			t.Fatalf("Between(%q, %q): %v", lo, hi, err)
+		}
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatal("keys are not sorted")
+	}
+}
+
+func TestInsertsAtFrontGrowSlowly(t *testing.T) {
+	first := ""
+	for i := 0; i < 100; i++ {
+		key, err := Between("", first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first = key
+	}
+	if len(first) > 20 {
+		t.Errorf("key after 100 inserts at the front has %d digits", len(first))
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 30, 31, 1000, 100000} {
+		keys := Spread(n)
+		if len(keys) != n {
+			t.Fatalf("Spread(%d) returned %d keys", n, len(keys))
+		}
+		for i, key := range keys {
+			if !valid(key) || key == "" {
+				t.Fatalf("Spread(%d)[%d] = %q", n, i, key)
+			}
+			if i > 0 {
+				if _, err := Between(keys[i-1], key); err != nil {
+					t.Fatalf("Spread(%d): no room between %q and %q", n, keys[i-1], key)
+				}
+			}
+		}
+		if n > 0 && len(keys[n-1]) > 4 {
+			t.Errorf("Spread(%d) keys have up to %d digits", n, len(keys[n-1]))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type MemoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	lockouts map[string]*Lockout
+	now      func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  make(map[string]*bucket),
+		lockouts: make(map[string]*Lockout),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	tokens, allowed, wait := refill(b.tokens, now.Sub(b.updatedAt), rate)
+	b.tokens, b.updatedAt = tokens, now
+	return allowed, wait, nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, policy LockoutPolicy) (Lockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	l, ok := s.lockouts[key]
+	if !ok {
+		l = &Lockout{Key: key}
+		s.lockouts[key] = l
+	}
+	l.Failures++
+	l.LastFailureAt = now
+	if d := policy.duration(l.Failures); d > 0 {
+		lockedUntil := now.Add(d)
+		l.LockedUntil = &lockedUntil
+	}
+	return *l, nil
+}
+
+func (s *MemoryStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.lockouts[key]
+	if !ok || l.LockedUntil == nil {
+		return 0, nil
+	}
+	if d := l.LockedUntil.Sub(s.now()); d > 0 {
+		return d, nil
+	}
+	return 0, nil
+}
+
+func (s *MemoryStore) ClearFailures(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lockouts, key)
+	return nil
+}
+
+func (s *MemoryStore) Lockouts(ctx context.Context) ([]Lockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lockouts := make([]Lockout, 0, len(s.lockouts))
+	for _, l := range s.lockouts {
+		lockouts = append(lockouts, *l)
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LastFailureAt.After(lockouts[j].LastFailureAt)
+	})
+	return lockouts, nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context, maxIdle time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-maxIdle)
+	for key, b := range s.buckets {
+		if b.updatedAt.Before(cutoff) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, l := range s.lockouts {
+		if l.LastFailureAt.Before(cutoff) && (l.LockedUntil == nil || l.LockedUntil.Before(s.now())) {
+			delete(s.lockouts, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PostgresStore keeps rate limit state in the rate_limit_buckets and
+// auth_lockouts tables. Elapsed time is measured with the database clock so
+// replicas with skewed clocks agree.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+		 VALUES ($1, $2, CURRENT_TIMESTAMP)
+		 ON CONFLICT (key) DO NOTHING`,
+		key, float64(rate.Burst),
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var tokens, elapsed float64
+	err = tx.QueryRowContext(ctx,
+		`SELECT tokens, GREATEST(EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - updated_at)), 0)
+		 FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`,
+		key,
+	).Scan(&tokens, &elapsed)
+	if err != nil {
+		return false, 0, err
+	}
+
+	tokens, allowed, wait := refill(tokens, time.Duration(elapsed*float64(time.Second)), rate)
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE rate_limit_buckets SET tokens = $1, updated_at = CURRENT_TIMESTAMP WHERE key = $2",
+		tokens, key,
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return allowed, wait, tx.Commit()
+}
+
+func (s *PostgresStore) RecordFailure(ctx context.Context, key string, policy LockoutPolicy) (Lockout, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Lockout{}, err
+	}
+	defer tx.Rollback()
+
+	l := Lockout{Key: key}
+	var lockedUntil sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO auth_lockouts (key, failures, last_failure_at)
+		 VALUES ($1, 1, CURRENT_TIMESTAMP)
+		 ON CONFLICT (key) DO UPDATE
+		 SET failures = auth_lockouts.failures + 1, last_failure_at = CURRENT_TIMESTAMP
+		 RETURNING failures, locked_until, last_failure_at`,
+		key,
+	).Scan(&l.Failures, &lockedUntil, &l.LastFailureAt)
+	if err != nil {
+		return Lockout{}, err
+	}
+
+	if d := policy.duration(l.Failures); d > 0 {
+		err = tx.QueryRowContext(ctx,
+			`UPDATE auth_lockouts SET locked_until = CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond'
+			 WHERE key = $2 RETURNING locked_until`,
+			d.Milliseconds(), key,
+		).Scan(&lockedUntil)
+		if err != nil {
+			return Lockout{}, err
+		}
+	}
+	l.LockedUntil = nullTime(lockedUntil)
+
+	return l, tx.Commit()
+}
+
+func (s *PostgresStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	var remaining float64
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT EXTRACT(EPOCH FROM (locked_until - CURRENT_TIMESTAMP))
+		 FROM auth_lockouts WHERE key = $1 AND locked_until > CURRENT_TIMESTAMP`,
+		key,
+	).Scan(&remaining)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(remaining * float64(time.Second)), nil
+}
+
+func (s *PostgresStore) ClearFailures(ctx context.Context, key string) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM auth_lockouts WHERE key = $1", key)
+	return err
+}
+
+func (s *PostgresStore) Lockouts(ctx context.Context) ([]Lockout, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT key, failures, locked_until, last_failure_at
+		 FROM auth_lockouts ORDER BY last_failure_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lockouts := []Lockout{}
+	for rows.Next() {
+		var l Lockout
+		var lockedUntil sql.NullTime
+		if err := rows.Scan(&l.Key, &l.Failures, &lockedUntil, &l.LastFailureAt); err != nil {
+			return nil, err
+		}
+		l.LockedUntil = nullTime(lockedUntil)
+		lockouts = append(lockouts, l)
+	}
+	return lockouts, rows.Err()
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, maxIdle time.Duration) error {
+	cutoff := int64(maxIdle.Seconds())
+	_, err := s.DB.ExecContext(ctx,
+		"DELETE FROM rate_limit_buckets WHERE updated_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'",
+		cutoff,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`DELETE FROM auth_lockouts
+		 WHERE last_failure_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+		   AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)`,
+		cutoff,
+	)
+	return err
+}
@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate describes a token bucket: it holds at most Burst tokens and refills
+// at PerMinute tokens per minute. Each request takes one token.
+type Rate struct {
+	PerMinute float64
+	Burst     int
+}
+
+func (r Rate) perSecond() float64 {
+	return r.PerMinute / 60
+}
+
+// LockoutPolicy locks a key once it accumulates Threshold consecutive
+// failures. The lock lasts Base and doubles with every further failure, up
+// to Max.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (p LockoutPolicy) duration(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+	if p.Base <= 0 {
+		return p.Max
+	}
+	// Doubled step by step rather than shifted, so that the lock stops
+	// growing at Max however many failures there are and never overflows.
+	d := p.Base
+	for n := p.Threshold; n < failures && d < p.Max; n++ {
+		d *= 2
+	}
+	return min(d, p.Max)
+}
+
+type Lockout struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+}
+
+// Store keeps token buckets and failure counters. The in-memory store suits a
+// single instance; the Postgres store lets replicas share state.
+type Store interface {
+	// Take removes a token from the bucket for key. When the bucket is empty
+	// it reports how long until a token is available.
+	Take(ctx context.Context, key string, rate Rate) (allowed bool, retryAfter time.Duration, err error)
+	// RecordFailure counts a failed attempt and returns the resulting lockout.
+	RecordFailure(ctx context.Context, key string, policy LockoutPolicy) (Lockout, error)
+	// LockedFor returns the remaining lockout for key, or zero.
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// ClearFailures resets the failure count for key, e.g. after a success or
+	// when an admin lifts a lockout.
+	ClearFailures(ctx context.Context, key string) error
+	// Lockouts lists keys with recorded failures.
+	Lockouts(ctx context.Context) ([]Lockout, error)
+	// Prune drops buckets and failure records idle for longer than maxIdle.
+	Prune(ctx context.Context, maxIdle time.Duration) error
+}
+
+// RetryAfterSeconds formats a wait as a Retry-After header value, rounding up
+// so clients never retry early.
+func RetryAfterSeconds(d time.Duration) int {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 1 {
+		return 1
+	}
+	return s
+}
+
+// refill applies the elapsed time to a bucket and tries to take a token.
+func refill(tokens float64, elapsed time.Duration, rate Rate) (float64, bool, time.Duration) {
+	tokens = math.Min(float64(rate.Burst), tokens+elapsed.Seconds()*rate.perSecond())
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+	if rate.perSecond() <= 0 {
+		return tokens, false, time.Hour
+	}
+	wait := time.Duration((1 - tokens) / rate.perSecond() * float64(time.Second))
+	return tokens, false, wait
+}
+
+// Limiter combines a store with the limits applied to authentication: a
+// bucket per client IP, a bucket per account and progressive lockout of
+// accounts that keep failing.
+type Limiter struct {
+	Store   Store
+	IP      Rate
+	Account Rate
+	Lockout LockoutPolicy
+}
+
+// AllowIP takes a token from the per-IP bucket.
+func (l *Limiter) AllowIP(ctx context.Context, ip string) (bool, time.Duration, error) {
+	return l.Store.Take(ctx, "ip:"+ip, l.IP)
+}
+
+// CheckAccount returns how long the caller must wait before attempting to
+// authenticate as key, or zero if the attempt may proceed.
+func (l *Limiter) CheckAccount(ctx context.Context, key string) (time.Duration, error) {
+	locked, err := l.Store.LockedFor(ctx, key)
+	if err != nil || locked > 0 {
+		return locked, err
+	}
+
+	allowed, wait, err := l.Store.Take(ctx, key, l.Account)
+	if err != nil || allowed {
+		return 0, err
+	}
+	return wait, nil
+}
+
+func (l *Limiter) Failure(ctx context.Context, key string) (Lockout, error) {
+	return l.Store.RecordFailure(ctx, key, l.Lockout)
+}
+
+func (l *Limiter) Success(ctx context.Context, key string) error {
+	return l.Store.ClearFailures(ctx, key)
+}
+
+func AccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func TwoFactorKey(userID int) string {
+	return "2fa:" + strconv.Itoa(userID)
+}
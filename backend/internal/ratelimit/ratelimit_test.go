@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{now: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore()
+	s.now = c.Now
+	return s, c
+}
+
+func TestTakeRefillsBucket(t *testing.T) {
+	ctx := context.Background()
+	s, c := newTestStore()
+	// One token every 10 seconds, at most 3.
+	rate := Rate{PerMinute: 6, Burst: 3}
+
+	for i := 0; i < rate.Burst; i++ {
+		if allowed, _, _ := s.Take(ctx, "k", rate); !allowed {
+			t.Fatalf("request %d of the burst refused", i+1)
+		}
+	}
+	allowed, wait, _ := s.Take(ctx, "k", rate)
+	if allowed {
+		t.Fatal("request beyond the burst allowed")
+	}
+	if wait != 10*time.Second {
+		t.Errorf("wait = %v, want 10s", wait)
+	}
+
+	c.Advance(4 * time.Second)
+	// Token counts are floats, so the wait is only accurate to a rounding.
+	if _, wait, _ := s.Take(ctx, "k", rate); wait.Round(time.Millisecond) != 6*time.Second {
+		t.Errorf("wait after 4s = %v, want 6s", wait)
+	}
+	c.Advance(6 * time.Second)
+	if allowed, _, _ := s.Take(ctx, "k", rate); !allowed {
+		t.Error("request refused after a token was refilled")
+	}
+
+	// A long pause refills only up to the burst.
+	c.Advance(time.Hour)
+	for i := 0; i < rate.Burst; i++ {
+		if allowed, _, _ := s.Take(ctx, "k", rate); !allowed {
+			t.Fatalf("request %d after a pause refused", i+1)
+		}
+	}
+	if allowed, _, _ := s.Take(ctx, "k", rate); allowed {
+		t.Error("bucket refilled beyond its burst")
+	}
+
+	if allowed, _, _ := s.Take(ctx, "other", rate); !allowed {
+		t.Error("buckets are not kept per key")
+	}
+}
+
+func TestLockoutDuration(t *testing.T) {
+	p := LockoutPolicy{Threshold: 5, Base: time.Minute, Max: time.Hour}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{10, 32 * time.Minute},
+		{11, time.Hour},
+		{100, time.Hour},
+		{math.MaxInt, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := p.duration(tt.failures); got != tt.want {
+			t.Errorf("duration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	if got := (LockoutPolicy{Threshold: 0, Base: time.Minute, Max: time.Hour}).duration(100); got != 0 {
+		t.Errorf("disabled policy locked for %v", got)
+	}
+	if got := (LockoutPolicy{Threshold: 1, Base: 2 * time.Hour, Max: time.Hour}).duration(1); got != time.Hour {
+		t.Errorf("Base above Max locked for %v, want %v", got, time.Hour)
+	}
+}
+
+func TestRecordFailureLocksOutAndEscalates(t *testing.T) {
+	ctx := context.Background()
+	s, c := newTestStore()
+	p := LockoutPolicy{Threshold: 3, Base: time.Minute, Max: 4 * time.Minute}
+
+	for i := 1; i < p.Threshold; i++ {
+		l, _ := s.RecordFailure(ctx, "k", p)
+		if l.LockedUntil != nil {
+			t.Fatalf("locked after %d failures", i)
+		}
+	}
+	if d, _ := s.LockedFor(ctx, "k"); d != 0 {
+		t.Fatalf("locked for %v below the threshold", d)
+	}
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		l, _ := s.RecordFailure(ctx, "k", p)
+		if l.Failures != p.Threshold+i {
+			t.Errorf("failures = %d, want %d", l.Failures, p.Threshold+i)
+		}
+		if d, _ := s.LockedFor(ctx, "k"); d != w {
+			t.Errorf("after %d failures locked for %v, want %v", l.Failures, d, w)
+		}
+	}
+
+	c.Advance(4 * time.Minute)
+	if d, _ := s.LockedFor(ctx, "k"); d != 0 {
+		t.Errorf("still locked for %v after the lock ran out", d)
+	}
+}
+
+func TestSuccessClearsFailures(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore()
+	l := &Limiter{
+		Store:   s,
+		Account: Rate{PerMinute: 60, Burst: 100},
+		Lockout: LockoutPolicy{Threshold: 2, Base: time.Minute, Max: time.Hour},
+	}
+
+	l.Failure(ctx, "k")
+	l.Failure(ctx, "k")
+	if wait, _ := l.CheckAccount(ctx, "k"); wait != time.Minute {
+		t.Fatalf("CheckAccount = %v, want a 1m lock", wait)
+	}
+
+	if err := l.Success(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := l.CheckAccount(ctx, "k"); wait != 0 {
+		t.Errorf("CheckAccount = %v after success, want 0", wait)
+	}
+	// The count starts again from zero.
+	if lockout, _ := l.Failure(ctx, "k"); lockout.Failures != 1 || lockout.LockedUntil != nil {
+		t.Errorf("first failure after success = %+v", lockout)
+	}
+	if lockouts, _ := s.Lockouts(ctx); len(lockouts) != 1 {
+		t.Errorf("%d lockouts listed, want 1", len(lockouts))
+	}
+}
+
+func TestCheckAccountAppliesRate(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore()
+	l := &Limiter{Store: s, Account: Rate{PerMinute: 1, Burst: 1}}
+
+	if wait, _ := l.CheckAccount(ctx, "k"); wait != 0 {
+		t.Fatalf("first attempt must wait %v", wait)
+	}
+	if wait, _ := l.CheckAccount(ctx, "k"); wait != time.Minute {
+		t.Errorf("second attempt waits %v, want 1m", wait)
+	}
+}
+
+func TestPruneKeepsActiveLocks(t *testing.T) {
+	ctx := context.Background()
+	s, c := newTestStore()
+	p := LockoutPolicy{Threshold: 1, Base: time.Hour, Max: time.Hour}
+
+	s.Take(ctx, "bucket", Rate{PerMinute: 1, Burst: 1})
+	s.RecordFailure(ctx, "locked", p)
+	c.Advance(30 * time.Minute)
+	s.Prune(ctx, 10*time.Minute)
+
+	if len(s.buckets) != 0 {
+		t.Error("idle bucket kept")
+	}
+	if d, _ := s.LockedFor(ctx, "locked"); d != 30*time.Minute {
+		t.Errorf("lock pruned while active; locked for %v", d)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int
+	}{
+		{0, 1},
+		{time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Minute, 60},
+	}
+	for _, tt := range tests {
+		if got := RetryAfterSeconds(tt.d); got != tt.want {
+			t.Errorf("RetryAfterSeconds(%v) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package sqltest provides a database/sql driver that answers queries from a
+// script, so that handlers and middleware can be tested without PostgreSQL.
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Result is what a scripted query returns. Queries read Columns and Rows;
+// statements report RowsAffected. A non-nil Err fails the call.
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+	Err          error
+}
+
+// Row is a shorthand for a single-row result.
+func Row(columns []string, values ...driver.Value) Result {
+	return Result{Columns: columns, Rows: [][]driver.Value{values}, RowsAffected: 1}
+}
+
+// Handler answers a query given its arguments.
+type Handler func(args []driver.Value) Result
+
+type rule struct {
+	fragment string
+	handler  Handler
+}
+
+// DB is a scripted database. Each query is answered by the most recently
+// registered handler whose fragment it contains; a query no handler matches
+// fails the test.
+type DB struct {
+	t     testing.TB
+	mu    sync.Mutex
+	rules []rule
+	calls []string
+}
+
+var (
+	registry sync.Map
+	nextID   atomic.Int64
+)
+
+func init() {
+	sql.Register("sqltest", drv{})
+}
+
+// New returns a scripted database and a *sql.DB connected to it.
+func New(t testing.TB) (*DB, *sql.DB) {
+	t.Helper()
+
+	fake := &DB{t: t}
+	name := fmt.Sprintf("db%d", nextID.Add(1))
+	registry.Store(name, fake)
+	db, err := sql.Open("sqltest", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		registry.Delete(name)
+	})
+	return fake, db
+}
+
+// On answers queries containing fragment with handler.
+func (f *DB) On(fragment string, handler Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, rule{fragment: fragment, handler: handler})
+}
+
+// Calls returns the queries run so far, in order.
+func (f *DB) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *DB) answer(query string, args []driver.NamedValue) Result {
+	f.mu.Lock()
+	f.calls = append(f.calls, query)
+	var handler Handler
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		if strings.Contains(query, f.rules[i].fragment) {
+			handler = f.rules[i].handler
+			break
+		}
+	}
+	f.mu.Unlock()
+
+	if handler == nil {
+		f.t.Errorf("sqltest: unexpected query: %s", query)
+		return Result{Err: fmt.Errorf("sqltest: unexpected query")}
+	}
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return handler(values)
+}
+
+type drv struct{}
+
+func (drv) Open(name string) (driver.Conn, error) {
+	fake, ok := registry.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("sqltest: unknown database %q", name)
+	}
+	return &conn{db: fake.(*DB)}, nil
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("sqltest: prepared statements are not supported")
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) { return tx{}, nil }
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.db.answer(query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return &rows{columns: res.Columns, values: res.Rows}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.db.answer(query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return driver.RowsAffected(res.RowsAffected), nil
+}
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
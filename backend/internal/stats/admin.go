@@ -0,0 +1,248 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Dashboard is the response of GET /api/admin/stats. Totals, active users,
+// the distribution and the largest accounts cover all data; only Series is
+// limited to the requested range.
+type Dashboard struct {
+	From            string      `json:"from"`
+	To              string      `json:"to"`
+	Bucket          string      `json:"bucket"`
+	GeneratedAt     time.Time   `json:"generated_at"`
+	Totals          Totals      `json:"totals"`
+	ActiveUsers     ActiveUsers `json:"active_users"`
+	Series          []Point     `json:"series"`
+	TodosPerUser    []Band      `json:"todos_per_user"`
+	LargestAccounts []Account   `json:"largest_accounts"`
+}
+
+type Totals struct {
+	Users          int `json:"users"`
+	Admins         int `json:"admins"`
+	SuspendedUsers int `json:"suspended_users"`
+	Todos          int `json:"todos"`
+	CompletedTodos int `json:"completed_todos"`
+	// CompletionRate is CompletedTodos / Todos, or 0 without todos.
+	CompletionRate float64 `json:"completion_rate"`
+}
+
+// ActiveUsers counts users who made an authenticated API request recently.
+type ActiveUsers struct {
+	Last7Days  int `json:"last_7_days"`
+	Last30Days int `json:"last_30_days"`
+}
+
+// Point is one bucket of the time series, labelled with its first day.
+type Point struct {
+	Start          string `json:"start"`
+	Signups        int    `json:"signups"`
+	TodosCreated   int    `json:"todos_created"`
+	TodosCompleted int    `json:"todos_completed"`
+}
+
+// Band counts the users owning between Min and Max todos. Max is nil for
+// the last, open-ended band.
+type Band struct {
+	Label string `json:"label"`
+	Min   int    `json:"min"`
+	Max   *int   `json:"max"`
+	Users int    `json:"users"`
+}
+
+type Account struct {
+	UserID         int    `json:"user_id"`
+	Email          string `json:"email"`
+	Todos          int    `json:"todos"`
+	CompletedTodos int    `json:"completed_todos"`
+}
+
+// bandStarts are the lower bounds of every band after the first, which
+// starts at zero.
+var bandStarts = []int64{1, 6, 21, 101}
+
+const largestAccounts = 10
+
+// AdminDashboard computes the dashboard for r. Each part is one query; they
+// are not run in a single transaction, since a dashboard only needs to be
+// roughly consistent.
+func AdminDashboard(ctx context.Context, db *sql.DB, r Range) (*Dashboard, error) {
+	d := &Dashboard{
+		From:        r.From.Format(dateLayout),
+		To:          r.To().Format(dateLayout),
+		Bucket:      r.Bucket,
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	t := &d.Totals
+	err := db.QueryRowContext(ctx,
+		`SELECT
+		   (SELECT COUNT(*) FROM users),
+		   (SELECT COUNT(*) FROM users WHERE is_admin),
+		   (SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL),
+		   (SELECT COUNT(*) FROM users WHERE last_seen_at >= CURRENT_TIMESTAMP - INTERVAL '7 days'),
+		   (SELECT COUNT(*) FROM users WHERE last_seen_at >= CURRENT_TIMESTAMP - INTERVAL '30 days'),
+		   COUNT(*),
+		   COUNT(*) FILTER (WHERE completed)
+		 FROM todos`,
+	).Scan(
+		&t.Users, &t.Admins, &t.SuspendedUsers,
+		&d.ActiveUsers.Last7Days, &d.ActiveUsers.Last30Days,
+		&t.Todos, &t.CompletedTodos,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if t.Todos > 0 {
+		t.CompletionRate = float64(t.CompletedTodos) / float64(t.Todos)
+	}
+
+	if d.Series, err = series(ctx, db, r); err != nil {
+		return nil, err
+	}
+	if d.TodosPerUser, err = todosPerUser(ctx, db); err != nil {
+		return nil, err
+	}
+	if d.LargestAccounts, err = largest(ctx, db); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func series(ctx context.Context, db *sql.DB, r Range) ([]Point, error) {
+	starts := r.Starts()
+	points := make([]Point, len(starts))
+	index := make(map[string]*Point, len(starts))
+	for i, start := range starts {
+		points[i].Start = start.Format(dateLayout)
+		index[points[i].Start] = &points[i]
+	}
+
+	// Buckets without rows are absent from the result and stay zero.
+	rows, err := db.QueryContext(ctx,
+		`SELECT 'signups', date_trunc($1, created_at), COUNT(*)
+		 FROM users WHERE created_at >= $2 AND created_at < $3 GROUP BY 2
+		 UNION ALL
+		 SELECT 'created', date_trunc($1, created_at), COUNT(*)
+		 FROM todos WHERE created_at >= $2 AND created_at < $3 GROUP BY 2
+		 UNION ALL
+		 SELECT 'completed', date_trunc($1, completed_at), COUNT(*)
+		 FROM todos WHERE completed_at >= $2 AND completed_at < $3 GROUP BY 2`,
+		r.Bucket, r.From, r.End,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var start time.Time
+		var n int
+		if err := rows.Scan(&kind, &start, &n); err != nil {
+			return nil, err
+		}
+		p, ok := index[start.Format(dateLayout)]
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "signups":
+			p.Signups = n
+		case "created":
+			p.TodosCreated = n
+		case "completed":
+			p.TodosCompleted = n
+		}
+	}
+	return points, rows.Err()
+}
+
+func todosPerUser(ctx context.Context, db *sql.DB) ([]Band, error) {
+	bands := newBands()
+
+	// width_bucket returns 0 below the first bound and i after the i-th.
+	rows, err := db.QueryContext(ctx,
+		`SELECT width_bucket(n, $1::bigint[]), COUNT(*)
+		 FROM (
+		   SELECT COUNT(t.id) AS n
+		   FROM users u LEFT JOIN todos t ON t.user_id = u.id
+		   GROUP BY u.id
+		 ) per_user
+		 GROUP BY 1`,
+		pq.Array(bandStarts),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i, n int
+		if err := rows.Scan(&i, &n); err != nil {
+			return nil, err
+		}
+		if i >= 0 && i < len(bands) {
+			bands[i].Users = n
+		}
+	}
+	return bands, rows.Err()
+}
+
+func newBands() []Band {
+	bands := make([]Band, len(bandStarts)+1)
+	for i := range bands {
+		if i > 0 {
+			bands[i].Min = int(bandStarts[i-1])
+		}
+		if i < len(bandStarts) {
+			max := int(bandStarts[i]) - 1
+			bands[i].Max = &max
+		}
+		bands[i].Label = bandLabel(bands[i])
+	}
+	return bands
+}
+
+func bandLabel(b Band) string {
+	switch {
+	case b.Max == nil:
+		return strconv.Itoa(b.Min) + "+"
+	case *b.Max == b.Min:
+		return strconv.Itoa(b.Min)
+	default:
+		return strconv.Itoa(b.Min) + "-" + strconv.Itoa(*b.Max)
+	}
+}
+
+func largest(ctx context.Context, db *sql.DB) ([]Account, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT u.id, u.email, COUNT(*), COUNT(*) FILTER (WHERE t.completed)
+		 FROM todos t JOIN users u ON u.id = t.user_id
+		 GROUP BY u.id, u.email
+		 ORDER BY COUNT(*) DESC, u.id
+		 LIMIT $1`,
+		largestAccounts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		var a Account
+		if err := rows.Scan(&a.UserID, &a.Email, &a.Todos, &a.CompletedTodos); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
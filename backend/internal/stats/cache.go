@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps computed results for a short time, so that dashboards left
+// open in several tabs do not rerun the aggregates on every refresh.
+// Concurrent misses for the same key may each compute the value.
+type Cache[V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry[V]
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// NewCache returns a cache whose entries expire after ttl. A zero ttl
+// disables caching.
+func NewCache[V any](ttl time.Duration) *Cache[V] {
+	return &Cache[V]{ttl: ttl, now: time.Now, entries: make(map[string]cacheEntry[V])}
+}
+
+// Get returns the cached value for key, or calls compute and caches its
+// result. Errors are not cached.
+func (c *Cache[V]) Get(key string, compute func() (V, error)) (V, error) {
+	now := c.now()
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.value, nil
+	}
+
+	v, err := compute()
+	if err != nil || c.ttl <= 0 {
+		return v, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Expired entries are dropped here so that rarely repeated keys do not
+	// accumulate.
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry[V]{value: v, expires: now.Add(c.ttl)}
+	return v, nil
+}
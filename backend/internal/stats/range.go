@@ -0,0 +1,109 @@
+// Package stats computes the usage figures behind the admin dashboard and
+// each user's own statistics. Each figure comes from a grouped aggregate
+// query, so the cost depends on the size of the tables rather than on the
+// number of buckets requested.
+package stats
+
+import (
+	"errors"
+	"time"
+)
+
+// Bucket sizes of a time series. They are also the field names Postgres'
+// date_trunc takes, and weeks start on Monday in both.
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// MaxBuckets bounds the length of a time series.
+const MaxBuckets = 400
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrInvalidBucket  = errors.New("bucket must be day, week or month")
+	ErrInvalidRange   = errors.New("from must not be after to")
+	ErrTooManyBuckets = errors.New("range has too many buckets")
+)
+
+// Range is a period split into buckets. From is the start of the first
+// bucket and End the start of the bucket after the last one.
+type Range struct {
+	From   time.Time
+	End    time.Time
+	Bucket string
+}
+
+// NewRange widens the days from through to, both inclusive, to whole
+// buckets. Only the dates of from and to are used.
+func NewRange(from, to time.Time, bucket string) (Range, error) {
+	switch bucket {
+	case BucketDay, BucketWeek, BucketMonth:
+	default:
+		return Range{}, ErrInvalidBucket
+	}
+
+	from, to = date(from), date(to)
+	if from.After(to) {
+		return Range{}, ErrInvalidRange
+	}
+
+	r := Range{From: truncate(from, bucket), Bucket: bucket}
+	r.End = r.next(truncate(to, bucket))
+	if len(r.Starts()) > MaxBuckets {
+		return Range{}, ErrTooManyBuckets
+	}
+	return r, nil
+}
+
+// To is the last day of the range.
+func (r Range) To() time.Time {
+	return r.End.AddDate(0, 0, -1)
+}
+
+// Starts returns the start of every bucket in order.
+func (r Range) Starts() []time.Time {
+	var starts []time.Time
+	for t := r.From; t.Before(r.End); t = r.next(t) {
+		starts = append(starts, t)
+		if len(starts) > MaxBuckets {
+			break
+		}
+	}
+	return starts
+}
+
+// String identifies the range, for instance as a cache key.
+func (r Range) String() string {
+	return r.From.Format(dateLayout) + "/" + r.End.Format(dateLayout) + "/" + r.Bucket
+}
+
+func (r Range) next(t time.Time) time.Time {
+	switch r.Bucket {
+	case BucketWeek:
+		return t.AddDate(0, 0, 7)
+	case BucketMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func date(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func truncate(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketWeek:
+		// Weekday counts from Sunday; weeks start on Monday.
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	case BucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return t
+	}
+}
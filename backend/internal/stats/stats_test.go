@@ -0,0 +1,127 @@
+package stats
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestNewRangeWidensToWholeBuckets(t *testing.T) {
+	tests := []struct {
+		from, to, bucket string
+		wantFrom, wantTo string
+		buckets          int
+	}{
+		{"2026-10-01", "2026-10-07", BucketDay, "2026-10-01", "2026-10-07", 7},
+		// 2026-10-01 is a Thursday and 2026-10-19 a Monday.
+		{"2026-10-01", "2026-10-19", BucketWeek, "2026-09-28", "2026-10-25", 4},
+		{"2026-10-04", "2026-10-05", BucketWeek, "2026-09-28", "2026-10-11", 2},
+		{"2026-01-31", "2026-03-01", BucketMonth, "2026-01-01", "2026-03-31", 3},
+	}
+	for _, tt := range tests {
+		r, err := NewRange(day(tt.from), day(tt.to), tt.bucket)
+		if err != nil {
+			t.Fatalf("NewRange(%s, %s, %s): %v", tt.from, tt.to, tt.bucket, err)
+		}
+		starts := r.Starts()
+		if got := r.From.Format(dateLayout); got != tt.wantFrom {
+			t.Errorf("%s..%s by %s: from = %s, want %s", tt.from, tt.to, tt.bucket, got, tt.wantFrom)
+		}
+		if got := r.To().Format(dateLayout); got != tt.wantTo {
+			t.Errorf("%s..%s by %s: to = %s, want %s", tt.from, tt.to, tt.bucket, got, tt.wantTo)
+		}
+		if len(starts) != tt.buckets {
+			t.Errorf("%s..%s by %s: %d buckets, want %d", tt.from, tt.to, tt.bucket, len(starts), tt.buckets)
+		}
+	}
+}
+
+func TestNewRangeRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		from, to, bucket string
+		want             error
+	}{
+		{"2026-10-02", "2026-10-01", BucketDay, ErrInvalidRange},
+		{"2026-10-01", "2026-10-02", "hour", ErrInvalidBucket},
+		{"2020-01-01", "2026-10-01", BucketDay, ErrTooManyBuckets},
+	}
+	for _, tt := range tests {
+		if _, err := NewRange(day(tt.from), day(tt.to), tt.bucket); !errors.Is(err, tt.want) {
+			t.Errorf("NewRange(%s, %s, %s) = %v, want %v", tt.from, tt.to, tt.bucket, err, tt.want)
+		}
+	}
+}
+
+func TestBandLabels(t *testing.T) {
+	var labels []string
+	for _, b := range newBands() {
+		labels = append(labels, b.Label)
+	}
+	want := []string{"0", "1-5", "6-20", "21-100", "101+"}
+	if strings.Join(labels, " ") != strings.Join(want, " ") {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	now := day("2026-10-19")
+	c := NewCache[int](time.Minute)
+	c.now = func() time.Time { return now }
+
+	calls := 0
+	compute := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	for _, advance := range []time.Duration{0, 30 * time.Second, 31 * time.Second} {
+		now = now.Add(advance)
+		c.Get("k", compute)
+	}
+	if calls != 2 {
+		t.Errorf("compute called %d times, want 2", calls)
+	}
+
+	if _, err := c.Get("e", func() (int, error) { return 0, errors.New("boom") }); err == nil {
+		t.Error("error was not returned")
+	}
+	if _, ok := c.entries["e"]; ok {
+		t.Error("error was cached")
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	days := func(ss ...string) []time.Time {
+		var ts []time.Time
+		for _, s := range ss {
+			ts = append(ts, day(s))
+		}
+		return ts
+	}
+	tests := []struct {
+		name             string
+		days             []time.Time
+		current, longest int
+	}{
+		{"none", nil, 0, 0},
+		{"ends today", days("2026-10-10", "2026-10-17", "2026-10-18", "2026-10-19"), 3, 3},
+		{"ends yesterday", days("2026-10-17", "2026-10-18"), 2, 2},
+		{"broken", days("2026-10-01", "2026-10-02", "2026-10-03", "2026-10-17"), 0, 3},
+		{"across months", days("2026-09-30", "2026-10-01"), 0, 2},
+	}
+	for _, tt := range tests {
+		s := streaks(tt.days, day("2026-10-19"))
+		if s.Current != tt.current || s.Longest != tt.longest {
+			t.Errorf("%s: streaks = %d current, %d longest, want %d, %d", tt.name, s.Current, s.Longest, tt.current, tt.longest)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// UserStats is the response of GET /api/me/stats. Days start at midnight
+// in Timezone, and the range and buckets are in local dates. Streaks and
+// the open, overdue and due-today counts cover all of the user's todos;
+// cancelled todos are not open.
+type UserStats struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Bucket   string  `json:"bucket"`
+	Timezone string  `json:"timezone"`
+	Streaks  Streaks `json:"streaks"`
+	// AverageCompletionSeconds is the mean time from creation to completion
+	// of the todos completed in the range, or nil if there are none.
+	AverageCompletionSeconds *float64        `json:"average_completion_seconds"`
+	Open                     int             `json:"open"`
+	Overdue                  int             `json:"overdue"`
+	DueToday                 int             `json:"due_today"`
+	Burndown                 []BurndownPoint `json:"burndown"`
+	Weekdays                 []WeekdayCount  `json:"weekdays"`
+}
+
+// Streaks count consecutive days with at least one completed todo. The
+// current streak survives until a whole day passes without one.
+type Streaks struct {
+	Current         int     `json:"current"`
+	Longest         int     `json:"longest"`
+	LastCompletedOn *string `json:"last_completed_on"`
+}
+
+// BurndownPoint is one bucket of the burndown. Open is the number of todos
+// left open at the end of the bucket.
+type BurndownPoint struct {
+	Start     string `json:"start"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+	Open      int    `json:"open"`
+}
+
+type WeekdayCount struct {
+	Weekday   string `json:"weekday"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+}
+
+// weekdays are in ISO order, matching EXTRACT(ISODOW ...) - 1.
+var weekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// local converts a UTC timestamp column to wall-clock time in the zone
+// bound to $2, which every query below takes.
+func local(column string) string {
+	return "(" + column + " AT TIME ZONE 'UTC' AT TIME ZONE $2)"
+}
+
+// ForUser computes the statistics of userID for r, whose dates are local to
+// loc. Postgres and Go share the IANA zone names, so loc.String() is passed
+// to the queries as is.
+func ForUser(ctx context.Context, db *sql.DB, userID int, loc *time.Location, r Range) (*UserStats, error) {
+	tz := loc.String()
+	today := date(time.Now().In(loc))
+	from, end := r.From.Format(dateLayout), r.End.Format(dateLayout)
+
+	s := &UserStats{
+		From:     from,
+		To:       r.To().Format(dateLayout),
+		Bucket:   r.Bucket,
+		Timezone: tz,
+	}
+
+	var openAtStart int
+	var average sql.NullFloat64
+	err := db.QueryRowContext(ctx,
+		`SELECT
+		   COUNT(*) FILTER (WHERE status NOT IN ('done', 'cancelled')),
+		   COUNT(*) FILTER (WHERE status NOT IN ('done', 'cancelled') AND due_date < $3::date),
+		   COUNT(*) FILTER (WHERE status NOT IN ('done', 'cancelled') AND due_date = $3::date),
+		   COUNT(*) FILTER (WHERE `+local("created_at")+` < $4::timestamp
+		                      AND (completed_at IS NULL OR `+local("completed_at")+` >= $4::timestamp)),
+		   AVG(EXTRACT(EPOCH FROM completed_at - created_at))
+		     FILTER (WHERE `+local("completed_at")+` >= $4::timestamp AND `+local("completed_at")+` < $5::timestamp)
+		 FROM todos WHERE user_id = $1`,
+		userID, tz, today.Format(dateLayout), from, end,
+	).Scan(&s.Open, &s.Overdue, &s.DueToday, &openAtStart, &average)
+	if err != nil {
+		return nil, err
+	}
+	if average.Valid {
+		s.AverageCompletionSeconds = &average.Float64
+	}
+
+	if s.Streaks, err = userStreaks(ctx, db, userID, tz, today); err != nil {
+		return nil, err
+	}
+	if s.Burndown, err = burndown(ctx, db, userID, tz, r, openAtStart); err != nil {
+		return nil, err
+	}
+	if s.Weekdays, err = weekdayCounts(ctx, db, userID, tz, from, end); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func userStreaks(ctx context.Context, db *sql.DB, userID int, tz string, today time.Time) (Streaks, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT DISTINCT `+local("completed_at")+`::date
+		 FROM todos WHERE user_id = $1 AND completed_at IS NOT NULL
+		 ORDER BY 1`,
+		userID, tz,
+	)
+	if err != nil {
+		return Streaks{}, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var d time.Time
+		if err := rows.Scan(&d); err != nil {
+			return Streaks{}, err
+		}
+		days = append(days, date(d))
+	}
+	if err := rows.Err(); err != nil {
+		return Streaks{}, err
+	}
+	return streaks(days, today), nil
+}
+
+// streaks finds the runs of consecutive days in days, which must be sorted
+// and distinct dates.
+func streaks(days []time.Time, today time.Time) Streaks {
+	var s Streaks
+	if len(days) == 0 {
+		return s
+	}
+
+	run := 0
+	for i, d := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(d) {
+			run++
+		} else {
+			run = 1
+		}
+		s.Longest = max(s.Longest, run)
+	}
+
+	last := days[len(days)-1]
+	if !last.Before(today.AddDate(0, 0, -1)) {
+		s.Current = run
+	}
+	lastDay := last.Format(dateLayout)
+	s.LastCompletedOn = &lastDay
+	return s
+}
+
+func burndown(ctx context.Context, db *sql.DB, userID int, tz string, r Range, openAtStart int) ([]BurndownPoint, error) {
+	starts := r.Starts()
+	points := make([]BurndownPoint, len(starts))
+	index := make(map[string]*BurndownPoint, len(starts))
+	for i, start := range starts {
+		points[i].Start = start.Format(dateLayout)
+		index[points[i].Start] = &points[i]
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT 'created', date_trunc($3, `+local("created_at")+`), COUNT(*)
+		 FROM todos
+		 WHERE user_id = $1 AND `+local("created_at")+` >= $4::timestamp AND `+local("created_at")+` < $5::timestamp
+		 GROUP BY 2
+		 UNION ALL
+		 SELECT 'completed', date_trunc($3, `+local("completed_at")+`), COUNT(*)
+		 FROM todos
+		 WHERE user_id = $1 AND `+local("completed_at")+` >= $4::timestamp AND `+local("completed_at")+` < $5::timestamp
+		 GROUP BY 2`,
+		userID, tz, r.Bucket, r.From.Format(dateLayout), r.End.Format(dateLayout),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var start time.Time
+		var n int
+		if err := rows.Scan(&kind, &start, &n); err != nil {
+			return nil, err
+		}
+		p, ok := index[start.Format(dateLayout)]
+		if !ok {
+			continue
+		}
+		if kind == "created" {
+			p.Created = n
+		} else {
+			p.Completed = n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	open := openAtStart
+	for i := range points {
+		open += points[i].Created - points[i].Completed
+		points[i].Open = open
+	}
+	return points, nil
+}
+
+func weekdayCounts(ctx context.Context, db *sql.DB, userID int, tz, from, end string) ([]WeekdayCount, error) {
+	counts := make([]WeekdayCount, len(weekdays))
+	for i, name := range weekdays {
+		counts[i].Weekday = name
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT 'created', EXTRACT(ISODOW FROM `+local("created_at")+`)::int, COUNT(*)
+		 FROM todos
+		 WHERE user_id = $1 AND `+local("created_at")+` >= $3::timestamp AND `+local("created_at")+` < $4::timestamp
+		 GROUP BY 2
+		 UNION ALL
+		 SELECT 'completed', EXTRACT(ISODOW FROM `+local("completed_at")+`)::int, COUNT(*)
+		 FROM todos
+		 WHERE user_id = $1 AND `+local("completed_at")+` >= $3::timestamp AND `+local("completed_at")+` < $4::timestamp
+		 GROUP BY 2`,
+		userID, tz, from, end,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var dow, n int
+		if err := rows.Scan(&kind, &dow, &n); err != nil {
+			return nil, err
+		}
+		if dow < 1 || dow > len(counts) {
+			continue
+		}
+		if kind == "created" {
+			counts[dow-1].Created = n
+		} else {
+			counts[dow-1].Completed = n
+		}
+	}
+	return counts, rows.Err()
+}
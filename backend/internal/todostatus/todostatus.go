@@ -0,0 +1,59 @@
+// Package todostatus defines the lifecycle of a todo. The completed flag
+// and completed_at are derived from the status by the todos_sync_status
+// trigger, so clients that only know completed keep working.
+package todostatus
+
+const (
+	Todo       = "todo"
+	InProgress = "in_progress"
+	Blocked    = "blocked"
+	Done       = "done"
+	Cancelled  = "cancelled"
+)
+
+// All lists the statuses in workflow order.
+var All = []string{Todo, InProgress, Blocked, Done, Cancelled}
+
+// transitions maps each status to the statuses it may change to. Finished
+// todos have to be reopened before work resumes, and a blocked todo has to
+// be unblocked before it can be done.
+var transitions = map[string][]string{
+	Todo:       {InProgress, Blocked, Done, Cancelled},
+	InProgress: {Todo, Blocked, Done, Cancelled},
+	Blocked:    {Todo, InProgress, Cancelled},
+	Done:       {Todo, InProgress},
+	Cancelled:  {Todo},
+}
+
+func Valid(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransition reports whether a todo may change from one status to the
+// other. Keeping the same status is always allowed.
+func CanTransition(from, to string) bool {
+	if from == to {
+		return Valid(to)
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ForCompleted returns the status a request that only sets completed asks
+// for, given the current status: completing means done, and un-completing a
+// done todo reopens it while leaving other statuses alone.
+func ForCompleted(current string, completed bool) string {
+	switch {
+	case completed:
+		return Done
+	case current == Done:
+		return Todo
+	default:
+		return current
+	}
+}
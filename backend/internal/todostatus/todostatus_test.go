@@ -0,0 +1,57 @@
+package todostatus
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{Todo, InProgress, true},
+		{InProgress, Done, true},
+		{Blocked, Done, false},
+		{Done, Todo, true},
+		{Done, Cancelled, false},
+		{Cancelled, InProgress, false},
+		{Cancelled, Cancelled, true},
+		{Todo, "archived", false},
+		{"archived", "archived", false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestEveryTransitionTargetIsAStatus(t *testing.T) {
+	for from, targets := range transitions {
+		for _, to := range targets {
+			if !Valid(to) {
+				t.Errorf("%s -> %s: unknown status", from, to)
+			}
+		}
+	}
+	if len(All) != len(transitions) {
+		t.Errorf("All has %d statuses, transitions %d", len(All), len(transitions))
+	}
+}
+
+func TestForCompleted(t *testing.T) {
+	tests := []struct {
+		current   string
+		completed bool
+		want      string
+	}{
+		{Todo, true, Done},
+		{Blocked, true, Done},
+		{Done, false, Todo},
+		{InProgress, false, InProgress},
+		{Cancelled, false, Cancelled},
+	}
+	for _, tt := range tests {
+		if got := ForCompleted(tt.current, tt.completed); got != tt.want {
+			t.Errorf("ForCompleted(%q, %v) = %q, want %q", tt.current, tt.completed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	RecoveryCodeCount = 10
+
+	recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryHalf     = 5
+)
+
+// GenerateRecoveryCodes returns single-use codes formatted as "xxxxx-xxxxx".
+// They carry about 49 bits of entropy each.
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		var b strings.Builder
+		for j := 0; j < recoveryHalf*2; j++ {
+			if j == recoveryHalf {
+				b.WriteByte('-')
+			}
+			c, err := randomRecoveryChar()
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(c)
+		}
+		codes[i] = b.String()
+	}
+	return codes, nil
+}
+
+// recoveryLimit is the largest multiple of len(recoveryAlphabet) that fits
+// in a byte. Bytes from there up are drawn again, so that every character
+// is equally likely.
+const recoveryLimit = 256 - 256%len(recoveryAlphabet)
+
+func randomRecoveryChar() (byte, error) {
+	var buf [1]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, err
+		}
+		if int(buf[0]) < recoveryLimit {
+			return recoveryAlphabet[int(buf[0])%len(recoveryAlphabet)], nil
+		}
+	}
+}
+
+// HashRecoveryCode normalizes and hashes a recovery code for storage. The
+// codes are random rather than user-chosen, so a fast hash is sufficient.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+package totp
+
+import (
+	"regexp"
+	"testing"
+)
+
+var recoveryFormat = regexp.MustCompile(`^[` + recoveryAlphabet + `]{5}-[` + recoveryAlphabet + `]{5}$`)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !recoveryFormat.MatchString(code) {
+			t.Errorf("code %q has the wrong format", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRecoveryCharsAreUniform(t *testing.T) {
+	if recoveryLimit%len(recoveryAlphabet) != 0 || recoveryLimit > 256 || 256-recoveryLimit >= len(recoveryAlphabet) {
+		t.Fatalf("recoveryLimit = %d is not the largest multiple of %d in a byte", recoveryLimit, len(recoveryAlphabet))
+	}
+
+	// Each character is expected 1000 times. The bias removed by
+	// recoveryLimit is too small to show up here, so this only checks that
+	// every character is drawn at about the same rate.
+	const draws = 31000
+	counts := make(map[byte]int)
+	for i := 0; i < draws; i++ {
+		c, err := randomRecoveryChar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[c]++
+	}
+	if len(counts) != len(recoveryAlphabet) {
+		t.Errorf("drew %d distinct characters, want %d", len(counts), len(recoveryAlphabet))
+	}
+	for c, n := range counts {
+		// More than 6 standard deviations from the mean.
+		if n < 810 || n > 1190 {
+			t.Errorf("%q drawn %d times out of %d", c, n, draws)
+		}
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghjk")
+	for _, code := range []string{"ABCDE-FGHJK", " abcdefghjk ", "abcde fghjk", "Abcde-Fghjk\n"} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs from the canonical form", code)
+		}
+	}
+	if HashRecoveryCode("abcde-fghjm") == want {
+		t.Error("different codes hash the same")
+	}
+	if want == "abcdefghjk" || len(want) != 64 {
+		t.Errorf("HashRecoveryCode = %q, want a hex SHA-256 digest", want)
+	}
+}
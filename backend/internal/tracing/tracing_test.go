@@ -0,0 +1,235 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestExporter installs a tracer provider that records spans in memory
+// and restores the previous one when the test ends.
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	if _, err := Setup(context.Background(), Config{Exporter: ExporterNone}); err != nil {
+		t.Fatal(err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		tp.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestGinMiddlewareContinuesIncomingTrace(t *testing.T) {
+	exporter := newTestExporter(t)
+	gin.SetMode(gin.TestMode)
+
+	var handlerTraceID string
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/api/todos/:id", func(c *gin.Context) {
+		handlerTraceID = TraceID(c.Request.Context())
+		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+	})
+
+	const (
+		parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentSpanID  = "00f067aa0ba902b7"
+	)
+	req := httptest.NewRequest(http.MethodGet, "/api/todos/42", nil)
+	req.Header.Set("traceparent", "00-"+parentTraceID+"-"+parentSpanID+"-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /api/todos/:id" {
+		t.Errorf("span name = %q, want GET /api/todos/:id", span.Name)
+	}
+	if span.SpanKind != trace.SpanKindServer {
+		t.Errorf("span kind = %v, want server", span.SpanKind)
+	}
+	if got := span.SpanContext.TraceID().String(); got != parentTraceID {
+		t.Errorf("trace id = %s, want %s", got, parentTraceID)
+	}
+	if got := span.Parent.SpanID().String(); got != parentSpanID {
+		t.Errorf("parent span id = %s, want %s", got, parentSpanID)
+	}
+	if v, _ := attr(span, "http.response.status_code"); v.AsInt64() != http.StatusNotFound {
+		t.Errorf("http.response.status_code = %v, want 404", v.AsInt64())
+	}
+	if v, _ := attr(span, "http.route"); v.AsString() != "/api/todos/:id" {
+		t.Errorf("http.route = %q", v.AsString())
+	}
+	if got := w.Header().Get(TraceIDHeader); got != parentTraceID {
+		t.Errorf("%s = %q, want %s", TraceIDHeader, got, parentTraceID)
+	}
+	if handlerTraceID != parentTraceID {
+		t.Errorf("handler saw trace id %q, want %s", handlerTraceID, parentTraceID)
+	}
+}
+
+func TestGinMiddlewareMarksServerErrors(t *testing.T) {
+	exporter := newTestExporter(t)
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/boom", func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if spans[0].Status.Code != codes.Error {
+		t.Errorf("status = %v, want error", spans[0].Status.Code)
+	}
+	if spans[0].Parent.IsValid() {
+		t.Errorf("expected a root span without a traceparent header")
+	}
+}
+
+// fakeConnector is a driver that answers every exec with a fixed number of
+// affected rows and every query with a fixed number of single-column rows.
+type fakeConnector struct {
+	rowsAffected int64
+	rows         int
+}
+
+func (f *fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ f *fakeConnector }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (c *fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(c.f.rowsAffected), nil
+}
+
+func (c *fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{remaining: c.f.rows}, nil
+}
+
+type fakeRows struct{ remaining int }
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.remaining == 0 {
+		return io.EOF
+	}
+	r.remaining--
+	dest[0] = int64(r.remaining)
+	return nil
+}
+
+func TestWrapConnector(t *testing.T) {
+	exporter := newTestExporter(t)
+	db := sql.OpenDB(WrapConnector(&fakeConnector{rowsAffected: 3, rows: 2}))
+	defer db.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	const secret = "do-not-record-me"
+	if _, err := db.ExecContext(ctx, "UPDATE todos SET title = $1 WHERE id = $2", secret, 7); err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT id FROM todos WHERE title = $1", secret)
+	if err != nil {
+		t.Fatalf("QueryContext: %v", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	exec, query := spans[0], spans[1]
+
+	if exec.Name != "UPDATE todos" {
+		t.Errorf("exec span name = %q, want UPDATE todos", exec.Name)
+	}
+	if v, _ := attr(exec, rowsAffectedKey); v.AsInt64() != 3 {
+		t.Errorf("rows affected = %d, want 3", v.AsInt64())
+	}
+	if query.Name != "SELECT todos" {
+		t.Errorf("query span name = %q, want SELECT todos", query.Name)
+	}
+	if v, _ := attr(query, rowsReturnedKey); v.AsInt64() != 2 {
+		t.Errorf("rows returned = %d, want 2", v.AsInt64())
+	}
+
+	for _, span := range []tracetest.SpanStub{exec, query} {
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s: not a child of the request span", span.Name)
+		}
+		for _, kv := range span.Attributes {
+			if strings.Contains(kv.Value.Emit(), secret) {
+				t.Errorf("%s: attribute %s records a parameter value", span.Name, kv.Key)
+			}
+		}
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	tests := []struct {
+		query     string
+		operation string
+		table     string
+	}{
+		{"SELECT id, email FROM users WHERE id = $1", "SELECT", "users"},
+		{"SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", "SELECT", "users"},
+		{"SELECT COUNT(*) FROM users WHERE is_admin = true", "SELECT", "users"},
+		{"\n\t\tINSERT INTO todos (user_id, title) VALUES ($1, $2)", "INSERT", "todos"},
+		{"UPDATE todos t SET title = $1", "UPDATE", "todos"},
+		{"delete from oidc_states where state = $1", "DELETE", "oidc_states"},
+		{"CREATE TABLE IF NOT EXISTS users (id SERIAL)", "CREATE", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		operation, table := parseStatement(tt.query)
+		if operation != tt.operation || table != tt.table {
+			t.Errorf("parseStatement(%q) = %q, %q; want %q, %q", tt.query, operation, table, tt.operation, tt.table)
+		}
+	}
+}
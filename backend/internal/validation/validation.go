@@ -0,0 +1,245 @@
+// Package validation decodes JSON request bodies and checks them against
+// rules declared in `validate` struct tags.
+//
+// A tag is a comma-separated list applied in order. Normalizers rewrite the
+// field before any check runs:
+//
+//	trim      strip leading and trailing whitespace
+//	nfc       Unicode NFC normalization
+//
+// Checks report a field error and never modify the value:
+//
+//	required  must not be empty
+//	min=N     at least N characters (Unicode code points)
+//	max=N     at most N characters (Unicode code points)
+//	email     a bare address as accepted by net/mail
+//	date      a calendar date in YYYY-MM-DD format
+//	timezone  an IANA time zone name such as Asia/Tokyo
+//	oneof=A B one of the space-separated values
+//
+// Checks other than required are skipped for empty values. Only string and
+// *string fields may carry rules.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"todo-app/backend/internal/apperror"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Bind decodes the request body into v, a pointer to a struct, then
+// normalizes and validates it. The returned error is an *apperror.Error
+// ready to pass to apperror.Respond.
+func Bind(c *gin.Context, v any) error {
+	if err := Decode(c.Request, v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// Decode reads a single JSON value from the request body into v. Unknown
+// fields and trailing data are rejected.
+func Decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return apperror.ErrBodyTooLarge
+		}
+		return apperror.ErrInvalidBody.WithMessage("Request body must contain a single JSON object")
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var maxErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxErr):
+		return apperror.ErrBodyTooLarge
+	case errors.Is(err, io.EOF):
+		return apperror.ErrInvalidBody.WithMessage("Request body is required")
+	case errors.As(err, &syntaxErr):
+		return apperror.ErrInvalidBody.WithMessage(fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apperror.ErrInvalidBody.WithMessage("Malformed JSON: unexpected end of input")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return apperror.Validation(apperror.Field(typeErr.Field, "invalid_type", "Must be "+typeName(typeErr.Type)))
+	}
+
+	// encoding/json has no typed error for unknown fields.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		name, _ = strconv.Unquote(name)
+		return apperror.Validation(apperror.Field(name, "unknown_field", "Unknown field"))
+	}
+	return apperror.ErrInvalidBody
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// Validate applies the `validate` tags of v, a pointer to a struct, and
+// reports every failing field at once.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic("validation: Validate requires a pointer to a struct")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	var fields []apperror.FieldError
+	for i := 0; i < rt.NumField(); i++ {
+		tag, ok := rt.Field(i).Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				if hasRule(tag, "required") {
+					fields = append(fields, requiredError(jsonName(rt.Field(i))))
+				}
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() != reflect.String {
+			panic(fmt.Sprintf("validation: field %s.%s must be a string", rt.Name(), rt.Field(i).Name))
+		}
+		if fe, ok := checkString(fv, jsonName(rt.Field(i)), tag); !ok {
+			fields = append(fields, fe)
+		}
+	}
+
+	if len(fields) > 0 {
+		return apperror.Validation(fields...)
+	}
+	return nil
+}
+
+// checkString normalizes v in place and runs the checks in tag, stopping at
+// the first failure.
+func checkString(v reflect.Value, field, tag string) (apperror.FieldError, bool) {
+	s := v.String()
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "trim":
+			s = strings.TrimSpace(s)
+		case "nfc":
+			s = norm.NFC.String(s)
+		case "required":
+			if s == "" {
+				return requiredError(field), false
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validation: invalid %s rule on %s: %q", name, field, rule))
+			}
+			count := utf8.RuneCountInString(s)
+			if s != "" && name == "min" && count < n {
+				return apperror.Field(field, "too_short", fmt.Sprintf("Must be at least %d characters", n)), false
+			}
+			if s != "" && name == "max" && count > n {
+				return apperror.Field(field, "too_long", fmt.Sprintf("Must be at most %d characters", n)), false
+			}
+		case "email":
+			if s != "" && !validEmail(s) {
+				return apperror.Field(field, "invalid_email", "Must be a valid email address"), false
+			}
+		case "date":
+			if _, err := time.Parse("2006-01-02", s); s != "" && err != nil {
+				return apperror.Field(field, "invalid_date", "Must be a date in YYYY-MM-DD format"), false
+			}
+		case "oneof":
+			if s != "" && !slices.Contains(strings.Fields(arg), s) {
+				return apperror.Field(field, "invalid_choice", "Must be one of: "+strings.Join(strings.Fields(arg), ", ")), false
+			}
+		case "timezone":
+			if s != "" && !validTimezone(s) {
+				return apperror.Field(field, "invalid_timezone", "Must be an IANA time zone such as Asia/Tokyo"), false
+			}
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q on %s", rule, field))
+		}
+	}
+	v.SetString(s)
+	return apperror.FieldError{}, true
+}
+
+// validEmail accepts a bare addr-spec with a domain, rejecting display
+// names and angle brackets.
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return false
+	}
+	_, domain, _ := strings.Cut(s, "@")
+	return domain != ""
+}
+
+// validTimezone accepts names from the time zone database. "Local" is
+// rejected because it means the server's zone, which Postgres does not know.
+func validTimezone(s string) bool {
+	if s == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(s)
+	return err == nil
+}
+
+func requiredError(field string) apperror.FieldError {
+	return apperror.Field(field, "required", "This field is required")
+}
+
+func hasRule(tag, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
@@ -0,0 +1,144 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"todo-app/backend/internal/apperror"
+)
+
+type request struct {
+	Title    string  `json:"title" validate:"trim,nfc,required,max=5"`
+	Email    string  `json:"email" validate:"trim,email"`
+	Password string  `json:"password" validate:"min=3"`
+	DueDate  *string `json:"due_date" validate:"date"`
+	Timezone string  `json:"timezone" validate:"timezone"`
+	Status   string  `json:"status" validate:"oneof=todo done"`
+	Count    int     `json:"count"`
+}
+
+func decode(body string, limit int64) error {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, limit)
+	}
+	return Decode(r, &request{})
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		limit int64
+		want  *apperror.Error
+		field string
+	}{
+		{"valid", `{"title":"a"}`, 0, nil, ""},
+		{"empty body", ``, 0, apperror.ErrInvalidBody, ""},
+		{"malformed", `{"title":}`, 0, apperror.ErrInvalidBody, ""},
+		{"truncated", `{"title":"a"`, 0, apperror.ErrInvalidBody, ""},
+		{"trailing data", `{"title":"a"} {}`, 0, apperror.ErrInvalidBody, ""},
+		{"wrong type", `{"count":"three"}`, 0, apperror.ErrValidation, "count"},
+		{"unknown field", `{"titel":"a"}`, 0, apperror.ErrValidation, "titel"},
+		{"not an object", `[]`, 0, apperror.ErrInvalidBody, ""},
+		{"at the limit", `{"title":"a"}`, 13, nil, ""},
+		{"over the limit", `{"title":"abcdef"}`, 13, apperror.ErrBodyTooLarge, ""},
+		{"over the limit after the value", `{"title":"a"}     `, 13, apperror.ErrBodyTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decode(tt.body, tt.limit)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Decode = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Decode = %v, want %s", err, tt.want.Code)
+			}
+			if tt.field != "" {
+				var appErr *apperror.Error
+				if !errors.As(err, &appErr) || len(appErr.Fields) != 1 || appErr.Fields[0].Field != tt.field {
+					t.Errorf("Decode = %+v, want an error on %s", err, tt.field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	date := func(s string) *string { return &s }
+	valid := func() request {
+		return request{Title: "todo", Email: "a@example.com", Password: "abc", DueDate: date("2026-01-31"), Timezone: "Asia/Tokyo", Status: "done"}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*request)
+		field  string
+		code   string
+	}{
+		{"valid", func(*request) {}, "", ""},
+		{"optional fields empty", func(r *request) { *r = request{Title: "todo"} }, "", ""},
+		{"required", func(r *request) { r.Title = "" }, "title", "required"},
+		{"required after trim", func(r *request) { r.Title = "   " }, "title", "required"},
+		{"max counts characters", func(r *request) { r.Title = "あいうえお" }, "", ""},
+		{"too long", func(r *request) { r.Title = "abcdef" }, "title", "too_long"},
+		{"too short", func(r *request) { r.Password = "ab" }, "password", "too_short"},
+		{"display name", func(r *request) { r.Email = "Alice <a@example.com>" }, "email", "invalid_email"},
+		{"no domain", func(r *request) { r.Email = "alice" }, "email", "invalid_email"},
+		{"bad date", func(r *request) { r.DueDate = date("2026-02-30") }, "due_date", "invalid_date"},
+		{"date with time", func(r *request) { r.DueDate = date("2026-01-31T00:00:00Z") }, "due_date", "invalid_date"},
+		{"unknown timezone", func(r *request) { r.Timezone = "Mars/Olympus" }, "timezone", "invalid_timezone"},
+		{"local timezone", func(r *request) { r.Timezone = "Local" }, "timezone", "invalid_timezone"},
+		{"not one of", func(r *request) { r.Status = "doing" }, "status", "invalid_choice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.modify(&r)
+			err := Validate(&r)
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			var appErr *apperror.Error
+			if !errors.As(err, &appErr) {
+				t.Fatalf("Validate = %v, want a validation error", err)
+			}
+			want := []apperror.FieldError{{Field: tt.field, Code: tt.code}}
+			got := make([]apperror.FieldError, len(appErr.Fields))
+			for i, f := range appErr.Fields {
+				got[i] = apperror.FieldError{Field: f.Field, Code: f.Code}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("fields = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateNormalizes(t *testing.T) {
+	// "e" followed by a combining acute accent composes to a single "é".
+	r := request{Title: "  cafe\u0301 ", Email: " a@example.com "}
+	if err := Validate(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Title != "café" || r.Email != "a@example.com" {
+		t.Errorf("normalized to %q, %q", r.Title, r.Email)
+	}
+}
+
+func TestValidateReportsEveryField(t *testing.T) {
+	r := request{Email: "nope", Status: "doing"}
+	var appErr *apperror.Error
+	if err := Validate(&r); !errors.As(err, &appErr) || len(appErr.Fields) != 3 {
+		t.Errorf("Validate = %+v, want errors on title, email and status", err)
+	}
+}
@@ -4,11 +4,12 @@ import { useState, useEffect } from 'react';
 import { useRouter } from 'next/navigation';
 import { adminAPI } from '@/lib/api';
 import { isAuthenticated, isAdmin, logout, getUser } from '@/lib/auth';
-import { User } from '@/types';
+import { AdminStats, User } from '@/types';
 import Link from 'next/link';
 
 export default function Admin() {
   const [users, setUsers] = useState<User[]>([]);
+  const [stats, setStats] = useState<AdminStats | null>(null);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
   const router = useRouter();
@@ -24,6 +25,7 @@ export default function Admin() {
       return;
     }
     fetchUsers();
+    adminAPI.getStats().then(setStats).catch(() => setError('統計の取得に失敗しました'));
   }, [router]);
 
   const fetchUsers = async () => {
@@ -87,9 +89,47 @@ export default function Admin() {
       </nav>
 
       <div className="container">
-        <h2>ユーザー管理</h2>
         {error && <div className="error">{error}</div>}
 
+        {stats && (
+          <>
+            <h2>統計（{stats.from} 〜 {stats.to}）</h2>
+            <div className="stats-grid">
+              <div className="stat-card">
+                <span>ユーザー数</span>
+                <strong>{stats.totals.users}</strong>
+              </div>
+              <div className="stat-card">
+                <span>アクティブ（7日 / 30日）</span>
+                <strong>
+                  {stats.active_users.last_7_days} / {stats.active_users.last_30_days}
+                </strong>
+              </div>
+              <div className="stat-card">
+                <span>TODO数</span>
+                <strong>{stats.totals.todos}</strong>
+              </div>
+              <div className="stat-card">
+                <span>完了率</span>
+                <strong>{Math.round(stats.totals.completion_rate * 100)}%</strong>
+              </div>
+              <div className="stat-card">
+                <span>期間中の登録</span>
+                <strong>{stats.series.reduce((sum, p) => sum + p.signups, 0)}</strong>
+              </div>
+              <div className="stat-card">
+                <span>期間中の作成 / 完了</span>
+                <strong>
+                  {stats.series.reduce((sum, p) => sum + p.todos_created, 0)} /{' '}
+                  {stats.series.reduce((sum, p) => sum + p.todos_completed, 0)}
+                </strong>
+              </div>
+            </div>
+          </>
+        )}
+
+        <h2>ユーザー管理</h2>
+
         <div className="user-table">
           <table>
             <thead>
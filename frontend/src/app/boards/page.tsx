@@ -0,0 +1,175 @@
+'use client';
+
+import { useState, useEffect } from 'react';
+import { useRouter } from 'next/navigation';
+import { boardAPI } from '@/lib/api';
+import { isAuthenticated, logout, getUser } from '@/lib/auth';
+import { Board, BoardColumn, BoardSummary } from '@/types';
+import Link from 'next/link';
+
+export default function Boards() {
+  const [boards, setBoards] = useState<BoardSummary[]>([]);
+  const [board, setBoard] = useState<Board | null>(null);
+  const [name, setName] = useState('');
+  const [draggedId, setDraggedId] = useState<number | null>(null);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState('');
+  const router = useRouter();
+  const user = getUser();
+
+  useEffect(() => {
+    if (!isAuthenticated()) {
+      router.push('/login');
+      return;
+    }
+    fetchBoards();
+  }, [router]);
+
+  const fetchBoards = async () => {
+    try {
+      const data = await boardAPI.getBoards();
+      setBoards(data);
+      if (data.length > 0) {
+        setBoard(await boardAPI.getBoard(data[0].id));
+      }
+    } catch (err) {
+      setError('ボードの取得に失敗しました');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleSelect = async (id: number) => {
+    try {
+      setBoard(await boardAPI.getBoard(id));
+    } catch (err) {
+      setError('ボードの取得に失敗しました');
+    }
+  };
+
+  const handleCreate = async (e: React.FormEvent) => {
+    e.preventDefault();
+    try {
+      const created = await boardAPI.createBoard(name);
+      setBoards([...boards, created]);
+      setBoard(created);
+      setName('');
+    } catch (err) {
+      setError('ボードの作成に失敗しました');
+    }
+  };
+
+  // Dropping on a card places the dragged card before it; dropping on the
+  // column itself places it last.
+  const handleDrop = async (column: BoardColumn, beforeId?: number) => {
+    const id = draggedId;
+    setDraggedId(null);
+    if (!board || id === null || id === beforeId) return;
+
+    try {
+      setError('');
+      setBoard(
+        await boardAPI.moveCard(board.id, id, {
+          column_id: column.id,
+          ...(beforeId !== undefined && { before_id: beforeId }),
+        })
+      );
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'カードの移動に失敗しました');
+    }
+  };
+
+  if (loading) {
+    return <div className="loading">読み込み中...</div>;
+  }
+
+  return (
+    <>
+      <nav className="navbar">
+        <div className="navbar-content">
+          <h1>ボード</h1>
+          <div className="navbar-menu">
+            <span>{user?.email}</span>
+            <Link href="/todos">TODO一覧</Link>
+            <button onClick={logout} className="btn btn-secondary">
+              ログアウト
+            </button>
+          </div>
+        </div>
+      </nav>
+
+      <div className="container">
+        <form onSubmit={handleCreate} className="board-toolbar">
+          <select
+            value={board?.id ?? ''}
+            onChange={(e) => handleSelect(Number(e.target.value))}
+            disabled={boards.length === 0}
+          >
+            {boards.map((b) => (
+              <option key={b.id} value={b.id}>
+                {b.name}
+              </option>
+            ))}
+          </select>
+          <input
+            type="text"
+            placeholder="新しいボード名"
+            value={name}
+            onChange={(e) => setName(e.target.value)}
+            required
+          />
+          <button type="submit" className="btn btn-primary">
+            作成
+          </button>
+        </form>
+
+        {error && <div className="error">{error}</div>}
+
+        {board ? (
+          <div className="board">
+            {board.columns.map((column) => {
+              const full = column.wip_limit !== null && column.cards.length >= column.wip_limit;
+              return (
+                <div
+                  key={column.id}
+                  className={`board-column ${full ? 'full' : ''}`}
+                  onDragOver={(e) => e.preventDefault()}
+                  onDrop={() => handleDrop(column)}
+                >
+                  <h3>
+                    {column.name}
+                    <span>
+                      {column.cards.length}
+                      {column.wip_limit !== null && ` / ${column.wip_limit}`}
+                    </span>
+                  </h3>
+                  {column.cards.map((card) => (
+                    <div
+                      key={card.id}
+                      className={`board-card ${draggedId === card.id ? 'dragging' : ''}`}
+                      draggable
+                      onDragStart={() => setDraggedId(card.id)}
+                      onDragEnd={() => setDraggedId(null)}
+                      onDrop={(e) => {
+                        e.stopPropagation();
+                        handleDrop(column, card.id);
+                      }}
+                    >
+                      <strong>{card.title}</strong>
+                      {card.due_date && <p>期限: {card.due_date}</p>}
+                    </div>
+                  ))}
+                </div>
+              );
+            })}
+          </div>
+        ) : (
+          <div className="empty-state">
+            <h3>ボードがありません</h3>
+            <p>上のフォームから新しいボードを作成してください</p>
+          </div>
+        )}
+      </div>
+    </>
+  );
+}
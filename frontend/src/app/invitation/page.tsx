@@ -0,0 +1,86 @@
+'use client';
+
+import { useState, useEffect } from 'react';
+import { useRouter } from 'next/navigation';
+import { authAPI } from '@/lib/api';
+import { setToken, setUser } from '@/lib/auth';
+import Link from 'next/link';
+
+export default function Invitation() {
+  const [token, setInvitationToken] = useState('');
+  const [password, setPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [error, setError] = useState('');
+  const [loading, setLoading] = useState(false);
+  const router = useRouter();
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.search);
+    const value = params.get('token');
+    if (!value) {
+      setError('招待リンクが正しくありません');
+      return;
+    }
+    setInvitationToken(value);
+  }, []);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    if (password !== confirmPassword) {
+      setError('パスワードが一致しません');
+      return;
+    }
+
+    setLoading(true);
+
+    try {
+      const response = await authAPI.acceptInvitation(token, password);
+      setToken(response.token);
+      setUser(response.user);
+      router.push('/todos');
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'パスワードの設定に失敗しました');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="auth-container">
+      <div className="auth-box">
+        <h1>パスワードの設定</h1>
+        <form onSubmit={handleSubmit}>
+          <div className="form-group">
+            <label htmlFor="password">パスワード</label>
+            <input
+              type="password"
+              id="password"
+              value={password}
+              onChange={(e) => setPassword(e.target.value)}
+              required
+            />
+          </div>
+          <div className="form-group">
+            <label htmlFor="confirmPassword">パスワード（確認）</label>
+            <input
+              type="password"
+              id="confirmPassword"
+              value={confirmPassword}
+              onChange={(e) => setConfirmPassword(e.target.value)}
+              required
+            />
+          </div>
+          {error && <div className="error">{error}</div>}
+          <button type="submit" className="btn btn-primary" disabled={loading || !token}>
+            {loading ? '設定中...' : '設定してログイン'}
+          </button>
+        </form>
+        <div className="link">
+          <Link href="/login">ログインページへ</Link>
+        </div>
+      </div>
+    </div>
+  );
+}
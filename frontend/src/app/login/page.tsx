@@ -30,7 +30,7 @@ export default function Login() {
       setUser(response.user);
       router.push('/todos');
     } catch (err: any) {
-      setError(err.response?.data || 'ログインに失敗しました');
+      setError(err.response?.data?.detail || 'ログインに失敗しました');
     } finally {
       setLoading(false);
     }
@@ -0,0 +1,158 @@
+'use client';
+
+import { useState, useEffect } from 'react';
+import { useRouter } from 'next/navigation';
+import { notificationAPI } from '@/lib/api';
+import { isAuthenticated, logout, getUser } from '@/lib/auth';
+import { Notification, NotificationPreferences } from '@/types';
+import Link from 'next/link';
+
+export default function Notifications() {
+  const [notifications, setNotifications] = useState<Notification[]>([]);
+  const [unreadCount, setUnreadCount] = useState(0);
+  const [prefs, setPrefs] = useState<NotificationPreferences | null>(null);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState('');
+  const router = useRouter();
+  const user = getUser();
+
+  useEffect(() => {
+    if (!isAuthenticated()) {
+      router.push('/login');
+      return;
+    }
+    fetchNotifications();
+  }, [router]);
+
+  const fetchNotifications = async () => {
+    try {
+      const data = await notificationAPI.getNotifications();
+      setNotifications(data.notifications);
+      setUnreadCount(data.unread_count);
+      setPrefs(await notificationAPI.getPreferences());
+    } catch (err) {
+      setError('通知の取得に失敗しました');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleMarkRead = async (notification: Notification) => {
+    try {
+      const updated = await notificationAPI.markRead(notification.id);
+      setNotifications(notifications.map((n) => (n.id === updated.id ? updated : n)));
+      setUnreadCount(Math.max(unreadCount - 1, 0));
+    } catch (err) {
+      setError('通知の更新に失敗しました');
+    }
+  };
+
+  const handleMarkAllRead = async () => {
+    try {
+      await notificationAPI.markAllRead();
+      fetchNotifications();
+    } catch (err) {
+      setError('通知の更新に失敗しました');
+    }
+  };
+
+  const handlePrefsChange = async (changed: Partial<NotificationPreferences>) => {
+    if (!prefs) return;
+    try {
+      setPrefs(await notificationAPI.updatePreferences({ ...prefs, ...changed }));
+    } catch (err) {
+      setError('通知設定の更新に失敗しました');
+    }
+  };
+
+  if (loading) {
+    return <div className="loading">読み込み中...</div>;
+  }
+
+  return (
+    <>
+      <nav className="navbar">
+        <div className="navbar-content">
+          <h1>通知</h1>
+          <div className="navbar-menu">
+            <span>{user?.email}</span>
+            <Link href="/todos">TODO一覧</Link>
+            <button onClick={logout} className="btn btn-secondary">
+              ログアウト
+            </button>
+          </div>
+        </div>
+      </nav>
+
+      <div className="container">
+        {error && <div className="error">{error}</div>}
+
+        {prefs && (
+          <div className="notification-prefs">
+            <label>
+              <input
+                type="checkbox"
+                checked={prefs.due_soon}
+                onChange={(e) => handlePrefsChange({ due_soon: e.target.checked })}
+              />
+              期限が近いTODOを通知
+            </label>
+            <select
+              value={prefs.due_soon_days}
+              onChange={(e) => handlePrefsChange({ due_soon_days: Number(e.target.value) })}
+              disabled={!prefs.due_soon}
+            >
+              {[0, 1, 2, 3, 7].map((days) => (
+                <option key={days} value={days}>
+                  {days === 0 ? '当日' : `${days}日前から`}
+                </option>
+              ))}
+            </select>
+            <label>
+              <input
+                type="checkbox"
+                checked={prefs.admin_actions}
+                onChange={(e) => handlePrefsChange({ admin_actions: e.target.checked })}
+              />
+              管理者によるアカウントの変更を通知
+            </label>
+          </div>
+        )}
+
+        <div className="notification-toolbar">
+          <span>未読 {unreadCount}件</span>
+          <button onClick={handleMarkAllRead} className="btn btn-secondary" disabled={unreadCount === 0}>
+            すべて既読にする
+          </button>
+        </div>
+
+        {notifications.length === 0 ? (
+          <div className="empty-state">
+            <p>通知はありません</p>
+          </div>
+        ) : (
+          <div className="notifications">
+            {notifications.map((notification) => (
+              <div
+                key={notification.id}
+                className={`notification ${notification.read_at ? '' : 'unread'}`}
+              >
+                <div>
+                  <p>{notification.message}</p>
+                  <span className="notification-meta">
+                    {new Date(notification.created_at).toLocaleString()}
+                  </span>
+                </div>
+                {!notification.read_at && (
+                  <button onClick={() => handleMarkRead(notification)} className="btn btn-secondary">
+                    既読にする
+                  </button>
+                )}
+              </div>
+            ))}
+          </div>
+        )}
+      </div>
+    </>
+  );
+}
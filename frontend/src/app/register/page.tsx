@@ -45,7 +45,7 @@ export default function Register() {
         router.push('/login');
       }, 2000);
     } catch (err: any) {
-      setError(err.response?.data || '登録に失敗しました');
+      setError(err.response?.data?.detail || '登録に失敗しました');
     } finally {
       setLoading(false);
     }
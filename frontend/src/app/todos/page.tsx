@@ -1,16 +1,40 @@
 'use client';
 
-import { useState, useEffect } from 'react';
+import { Fragment, useState, useEffect } from 'react';
 import { useRouter } from 'next/navigation';
-import { todoAPI } from '@/lib/api';
+import { attachmentAPI, authAPI, commentAPI, notificationAPI, todoAPI } from '@/lib/api';
 import { isAuthenticated, logout, getUser, isAdmin } from '@/lib/auth';
-import { Todo } from '@/types';
+import { Attachment, Comment, StorageUsage, Todo, TodoStatus, UserStats } from '@/types';
 import Link from 'next/link';
 
+const formatBytes = (bytes: number) => {
+  if (bytes < 1024) return `${bytes} B`;
+  if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+  return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+};
+
+const statusLabels: Record<TodoStatus, string> = {
+  todo: '未着手',
+  in_progress: '進行中',
+  blocked: 'ブロック中',
+  done: '完了',
+  cancelled: '中止',
+};
+
 export default function Todos() {
   const [todos, setTodos] = useState<Todo[]>([]);
   const [title, setTitle] = useState('');
   const [description, setDescription] = useState('');
+  const [dueDate, setDueDate] = useState('');
+  const [stats, setStats] = useState<UserStats | null>(null);
+  const [draggedId, setDraggedId] = useState<number | null>(null);
+  const [openCommentsId, setOpenCommentsId] = useState<number | null>(null);
+  const [comments, setComments] = useState<Comment[]>([]);
+  const [commentBody, setCommentBody] = useState('');
+  const [openAttachmentsId, setOpenAttachmentsId] = useState<number | null>(null);
+  const [attachments, setAttachments] = useState<Attachment[]>([]);
+  const [storage, setStorage] = useState<StorageUsage | null>(null);
+  const [unreadCount, setUnreadCount] = useState(0);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
   const router = useRouter();
@@ -26,8 +50,10 @@ export default function Todos() {
 
   const fetchTodos = async () => {
     try {
-      const data = await todoAPI.getTodos();
+      const data = await todoAPI.getTodos({ sort: 'position' });
       setTodos(data);
+      setStats(await todoAPI.getStats());
+      setUnreadCount((await notificationAPI.getNotifications({ unread: true, limit: 1 })).unread_count);
     } catch (err) {
       setError('TODOの取得に失敗しました');
     } finally {
@@ -35,6 +61,17 @@ export default function Todos() {
     }
   };
 
+  const browserTimezone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+
+  const handleUseBrowserTimezone = async () => {
+    try {
+      await authAPI.updateSettings({ timezone: browserTimezone });
+      setStats(await todoAPI.getStats());
+    } catch (err) {
+      setError('タイムゾーンの更新に失敗しました');
+    }
+  };
+
   const handleAddTodo = async (e: React.FormEvent) => {
     e.preventDefault();
     if (!title.trim()) return;
@@ -44,9 +81,11 @@ export default function Todos() {
         title,
         description,
         completed: false,
+        due_date: dueDate || null,
       });
       setTitle('');
       setDescription('');
+      setDueDate('');
       fetchTodos();
     } catch (err) {
       setError('TODOの追加に失敗しました');
@@ -56,8 +95,10 @@ export default function Todos() {
   const handleToggle = async (todo: Todo) => {
     try {
       await todoAPI.updateTodo(todo.id, {
-        ...todo,
+        title: todo.title,
+        description: todo.description,
         completed: !todo.completed,
+        due_date: todo.due_date,
       });
       fetchTodos();
     } catch (err) {
@@ -65,6 +106,44 @@ export default function Todos() {
     }
   };
 
+  const handleStatusChange = async (todo: Todo, status: TodoStatus) => {
+    try {
+      await todoAPI.updateTodo(todo.id, {
+        title: todo.title,
+        description: todo.description,
+        status,
+        completed: status === 'done',
+        due_date: todo.due_date,
+      });
+      fetchTodos();
+    } catch (err) {
+      setError('このステータスには変更できません');
+    }
+  };
+
+  const handleDrop = async (target: Todo) => {
+    const id = draggedId;
+    setDraggedId(null);
+    if (id === null || id === target.id) return;
+
+    // Dropping on a todo takes its place: items moved down go after it,
+    // items moved up go before it.
+    const from = todos.findIndex((t) => t.id === id);
+    const to = todos.findIndex((t) => t.id === target.id);
+    const anchors = from < to ? { after_id: target.id } : { before_id: target.id };
+
+    const reordered = todos.filter((t) => t.id !== id);
+    reordered.splice(to, 0, todos[from]);
+    setTodos(reordered);
+
+    try {
+      await todoAPI.moveTodo(id, anchors);
+    } catch (err) {
+      setError('TODOの並び替えに失敗しました');
+      fetchTodos();
+    }
+  };
+
   const handleDelete = async (id: number) => {
     if (!confirm('このTODOを削除してもよろしいですか？')) return;
 
@@ -76,6 +155,89 @@ export default function Todos() {
     }
   };
 
+  const setCommentCount = (todoId: number, count: number) => {
+    setTodos((todos) => todos.map((t) => (t.id === todoId ? { ...t, comment_count: count } : t)));
+  };
+
+  const handleToggleComments = async (todo: Todo) => {
+    if (openCommentsId === todo.id) {
+      setOpenCommentsId(null);
+      return;
+    }
+    try {
+      setComments(await commentAPI.getComments(todo.id));
+      setCommentBody('');
+      setOpenCommentsId(todo.id);
+    } catch (err) {
+      setError('コメントの取得に失敗しました');
+    }
+  };
+
+  const handleAddComment = async (e: React.FormEvent, todoId: number) => {
+    e.preventDefault();
+    try {
+      const created = await commentAPI.createComment(todoId, commentBody);
+      setComments([...comments, created]);
+      setCommentCount(todoId, comments.length + 1);
+      setCommentBody('');
+    } catch (err) {
+      setError('コメントの投稿に失敗しました');
+    }
+  };
+
+  const handleDeleteComment = async (comment: Comment) => {
+    if (!confirm('このコメントを削除してもよろしいですか？')) return;
+
+    try {
+      await commentAPI.deleteComment(comment.todo_id, comment.id);
+      setComments(comments.filter((c) => c.id !== comment.id));
+      setCommentCount(comment.todo_id, comments.length - 1);
+    } catch (err) {
+      setError('コメントの削除に失敗しました');
+    }
+  };
+
+  const handleToggleAttachments = async (todo: Todo) => {
+    if (openAttachmentsId === todo.id) {
+      setOpenAttachmentsId(null);
+      return;
+    }
+    try {
+      setAttachments(await attachmentAPI.getAttachments(todo.id));
+      setStorage(await attachmentAPI.getStorageUsage());
+      setOpenAttachmentsId(todo.id);
+    } catch (err) {
+      setError('添付ファイルの取得に失敗しました');
+    }
+  };
+
+  const handleUpload = async (e: React.ChangeEvent<HTMLInputElement>, todoId: number) => {
+    const input = e.target;
+    const file = input.files?.[0];
+    if (!file) return;
+    try {
+      const created = await attachmentAPI.uploadAttachment(todoId, file);
+      setAttachments([...attachments, created]);
+      setStorage(await attachmentAPI.getStorageUsage());
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'ファイルのアップロードに失敗しました');
+    } finally {
+      input.value = '';
+    }
+  };
+
+  const handleDeleteAttachment = async (attachment: Attachment) => {
+    if (!confirm(`${attachment.filename} を削除してもよろしいですか？`)) return;
+
+    try {
+      await attachmentAPI.deleteAttachment(attachment.todo_id, attachment.id);
+      setAttachments(attachments.filter((a) => a.id !== attachment.id));
+      setStorage(await attachmentAPI.getStorageUsage());
+    } catch (err) {
+      setError('添付ファイルの削除に失敗しました');
+    }
+  };
+
   if (loading) {
     return <div className="loading">読み込み中...</div>;
   }
@@ -87,6 +249,8 @@ export default function Todos() {
           <h1>TODO管理</h1>
           <div className="navbar-menu">
             <span>{user?.email}</span>
+            <Link href="/boards">ボード</Link>
+            <Link href="/notifications">通知{unreadCount > 0 && ` (${unreadCount})`}</Link>
             {isAdmin() && (
               <Link href="/admin">管理者ページ</Link>
             )}
@@ -119,6 +283,15 @@ export default function Todos() {
                 onChange={(e) => setDescription(e.target.value)}
               />
             </div>
+            <div className="form-group">
+              <label htmlFor="dueDate">期限</label>
+              <input
+                type="date"
+                id="dueDate"
+                value={dueDate}
+                onChange={(e) => setDueDate(e.target.value)}
+              />
+            </div>
             <button type="submit" className="btn btn-primary">
               追加
             </button>
@@ -127,6 +300,45 @@ export default function Todos() {
 
         {error && <div className="error">{error}</div>}
 
+        {stats && (
+          <>
+            <div className="stats-grid">
+              <div className="stat-card">
+                <span>連続達成日数（最長）</span>
+                <strong>
+                  {stats.streaks.current}日（{stats.streaks.longest}日）
+                </strong>
+              </div>
+              <div className="stat-card">
+                <span>未完了 / 期限切れ</span>
+                <strong>
+                  {stats.open} / {stats.overdue}
+                </strong>
+              </div>
+              <div className="stat-card">
+                <span>今日が期限</span>
+                <strong>{stats.due_today}</strong>
+              </div>
+              <div className="stat-card">
+                <span>完了までの平均時間</span>
+                <strong>
+                  {stats.average_completion_seconds === null
+                    ? '-'
+                    : `${(stats.average_completion_seconds / 3600).toFixed(1)}時間`}
+                </strong>
+              </div>
+            </div>
+            {stats.timezone !== browserTimezone && (
+              <p className="timezone-note">
+                統計は {stats.timezone} の日付で集計されています。
+                <button onClick={handleUseBrowserTimezone} className="btn btn-secondary">
+                  {browserTimezone} を使用
+                </button>
+              </p>
+            )}
+          </>
+        )}
+
         {todos.length === 0 ? (
           <div className="empty-state">
             <h3>TODOがありません</h3>
@@ -135,26 +347,113 @@ export default function Todos() {
         ) : (
           <div className="todo-list">
             {todos.map((todo) => (
-              <div key={todo.id} className={`todo-item ${todo.completed ? 'completed' : ''}`}>
-                <input
-                  type="checkbox"
-                  className="todo-checkbox"
-                  checked={todo.completed}
-                  onChange={() => handleToggle(todo)}
-                />
-                <div className={`todo-content ${todo.completed ? 'completed' : ''}`}>
-                  <h3>{todo.title}</h3>
-                  {todo.description && <p>{todo.description}</p>}
+              <Fragment key={todo.id}>
+                <div
+                  className={`todo-item ${todo.completed ? 'completed' : ''} ${draggedId === todo.id ? 'dragging' : ''}`}
+                  draggable
+                  onDragStart={() => setDraggedId(todo.id)}
+                  onDragEnd={() => setDraggedId(null)}
+                  onDragOver={(e) => e.preventDefault()}
+                  onDrop={() => handleDrop(todo)}
+                >
+                  <input
+                    type="checkbox"
+                    className="todo-checkbox"
+                    checked={todo.completed}
+                    onChange={() => handleToggle(todo)}
+                  />
+                  <div className={`todo-content ${todo.completed ? 'completed' : ''}`}>
+                    <h3>{todo.title}</h3>
+                    {todo.description && <p>{todo.description}</p>}
+                    {todo.due_date && <p>期限: {todo.due_date}</p>}
+                  </div>
+                  <div className="todo-actions">
+                    <select
+                      value={todo.status}
+                      onChange={(e) => handleStatusChange(todo, e.target.value as TodoStatus)}
+                    >
+                      {(Object.keys(statusLabels) as TodoStatus[]).map((status) => (
+                        <option key={status} value={status}>
+                          {statusLabels[status]}
+                        </option>
+                      ))}
+                    </select>
+                    <button
+                      onClick={() => handleToggleComments(todo)}
+                      className="btn btn-secondary"
+                    >
+                      コメント ({todo.comment_count})
+                    </button>
+                    <button
+                      onClick={() => handleToggleAttachments(todo)}
+                      className="btn btn-secondary"
+                    >
+                      添付
+                    </button>
+                    <button
+                      onClick={() => handleDelete(todo.id)}
+                      className="btn btn-danger"
+                    >
+                      削除
+                    </button>
+                  </div>
                 </div>
-                <div className="todo-actions">
-                  <button
-                    onClick={() => handleDelete(todo.id)}
-                    className="btn btn-danger"
-                  >
-                    削除
-                  </button>
-                </div>
-              </div>
+                {openCommentsId === todo.id && (
+                  <div className="comments">
+                    {comments.map((comment) => (
+                      <div key={comment.id} className="comment">
+                        <div className="comment-meta">
+                          <span>
+                            {comment.author_email ?? '削除されたユーザー'}
+                            {' · '}
+                            {new Date(comment.created_at).toLocaleString()}
+                            {comment.edited_at && '（編集済み）'}
+                          </span>
+                          <button onClick={() => handleDeleteComment(comment)} className="btn btn-danger">
+                            削除
+                          </button>
+                        </div>
+                        {/* body_html is escaped and sanitized by the server. */}
+                        <div className="comment-body" dangerouslySetInnerHTML={{ __html: comment.body_html }} />
+                      </div>
+                    ))}
+                    <form onSubmit={(e) => handleAddComment(e, todo.id)} className="comment-form">
+                      <textarea
+                        placeholder="コメント（Markdown、@メールアドレスでメンション）"
+                        value={commentBody}
+                        onChange={(e) => setCommentBody(e.target.value)}
+                        required
+                      />
+                      <button type="submit" className="btn btn-primary">
+                        投稿
+                      </button>
+                    </form>
+                  </div>
+                )}
+                {openAttachmentsId === todo.id && (
+                  <div className="attachments">
+                    {attachments.map((attachment) => (
+                      <div key={attachment.id} className="attachment">
+                        <a href={attachment.url} target="_blank" rel="noopener noreferrer">
+                          {attachment.filename}
+                        </a>
+                        <span className="attachment-meta">{formatBytes(attachment.size)}</span>
+                        <button onClick={() => handleDeleteAttachment(attachment)} className="btn btn-danger">
+                          削除
+                        </button>
+                      </div>
+                    ))}
+                    <div className="attachment-form">
+                      <input type="file" onChange={(e) => handleUpload(e, todo.id)} />
+                      {storage && (
+                        <span className="attachment-meta">
+                          使用容量 {formatBytes(storage.used_bytes)} / {formatBytes(storage.quota_bytes)}
+                        </span>
+                      )}
+                    </div>
+                  </div>
+                )}
+              </Fragment>
             ))}
           </div>
         )}
@@ -1,5 +1,27 @@
 import axios from 'axios';
-import { LoginRequest, RegisterRequest, TodoRequest, LoginResponse, User, Todo } from '@/types';
+import {
+  AdminStats,
+  UserSettings,
+  UserStats,
+  LoginRequest,
+  RegisterRequest,
+  TodoRequest,
+  LoginResponse,
+  User,
+  Todo,
+  TodoStatusChange,
+  Board,
+  BoardSummary,
+  Collaborator,
+  Comment,
+  CommentRevision,
+  MentionNotice,
+  Attachment,
+  StorageUsage,
+  Notification,
+  NotificationList,
+  NotificationPreferences,
+} from '@/types';
 
 const API_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080/api';
 
@@ -26,15 +48,30 @@ export const authAPI = {
     return response.data;
   },
 
+  acceptInvitation: async (token: string, password: string): Promise<LoginResponse> => {
+    const response = await api.post<LoginResponse>('/invitations/accept', { token, password });
+    return response.data;
+  },
+
   getCurrentUser: async (): Promise<User> => {
     const response = await api.get<User>('/me');
     return response.data;
   },
+
+  getSettings: async (): Promise<UserSettings> => {
+    const response = await api.get<UserSettings>('/me/settings');
+    return response.data;
+  },
+
+  updateSettings: async (settings: UserSettings): Promise<UserSettings> => {
+    const response = await api.put<UserSettings>('/me/settings', settings);
+    return response.data;
+  },
 };
 
 export const todoAPI = {
-  getTodos: async (): Promise<Todo[]> => {
-    const response = await api.get<Todo[]>('/todos');
+  getTodos: async (params?: { sort?: 'created_at' | 'position' }): Promise<Todo[]> => {
+    const response = await api.get<Todo[]>('/todos', { params });
     return response.data;
   },
 
@@ -56,6 +93,149 @@ export const todoAPI = {
   deleteTodo: async (id: number): Promise<void> => {
     await api.delete(`/todos/${id}`);
   },
+
+  moveTodo: async (id: number, anchors: { after_id?: number; before_id?: number }): Promise<Todo> => {
+    const response = await api.post<Todo>(`/todos/${id}/move`, anchors);
+    return response.data;
+  },
+
+  getHistory: async (id: number): Promise<TodoStatusChange[]> => {
+    const response = await api.get<TodoStatusChange[]>(`/todos/${id}/history`);
+    return response.data;
+  },
+
+  getStats: async (params?: { from?: string; to?: string; bucket?: string }): Promise<UserStats> => {
+    const response = await api.get<UserStats>('/me/stats', { params });
+    return response.data;
+  },
+};
+
+export const boardAPI = {
+  getBoards: async (): Promise<BoardSummary[]> => {
+    const response = await api.get<BoardSummary[]>('/boards');
+    return response.data;
+  },
+
+  createBoard: async (name: string): Promise<Board> => {
+    const response = await api.post<Board>('/boards', { name });
+    return response.data;
+  },
+
+  getBoard: async (id: number): Promise<Board> => {
+    const response = await api.get<Board>(`/boards/${id}`);
+    return response.data;
+  },
+
+  moveCard: async (
+    id: number,
+    todoId: number,
+    move: { column_id: number; after_id?: number; before_id?: number }
+  ): Promise<Board> => {
+    const response = await api.post<Board>(`/boards/${id}/cards/${todoId}/move`, move);
+    return response.data;
+  },
+};
+
+export const commentAPI = {
+  getComments: async (todoId: number): Promise<Comment[]> => {
+    const response = await api.get<Comment[]>(`/todos/${todoId}/comments`);
+    return response.data;
+  },
+
+  createComment: async (todoId: number, body: string): Promise<Comment> => {
+    const response = await api.post<Comment>(`/todos/${todoId}/comments`, { body });
+    return response.data;
+  },
+
+  updateComment: async (todoId: number, id: number, body: string): Promise<Comment> => {
+    const response = await api.put<Comment>(`/todos/${todoId}/comments/${id}`, { body });
+    return response.data;
+  },
+
+  deleteComment: async (todoId: number, id: number): Promise<void> => {
+    await api.delete(`/todos/${todoId}/comments/${id}`);
+  },
+
+  getRevisions: async (todoId: number, id: number): Promise<CommentRevision[]> => {
+    const response = await api.get<CommentRevision[]>(`/todos/${todoId}/comments/${id}/revisions`);
+    return response.data;
+  },
+
+  getMentions: async (limit?: number): Promise<MentionNotice[]> => {
+    const response = await api.get<MentionNotice[]>('/me/mentions', { params: { limit } });
+    return response.data;
+  },
+
+  getCollaborators: async (todoId: number): Promise<Collaborator[]> => {
+    const response = await api.get<Collaborator[]>(`/todos/${todoId}/collaborators`);
+    return response.data;
+  },
+
+  addCollaborator: async (todoId: number, email: string): Promise<Collaborator> => {
+    const response = await api.post<Collaborator>(`/todos/${todoId}/collaborators`, { email });
+    return response.data;
+  },
+
+  removeCollaborator: async (todoId: number, userId: number): Promise<void> => {
+    await api.delete(`/todos/${todoId}/collaborators/${userId}`);
+  },
+};
+
+export const attachmentAPI = {
+  getAttachments: async (todoId: number): Promise<Attachment[]> => {
+    const response = await api.get<Attachment[]>(`/todos/${todoId}/attachments`);
+    return response.data;
+  },
+
+  uploadAttachment: async (todoId: number, file: File): Promise<Attachment> => {
+    const form = new FormData();
+    form.append('file', file);
+    const response = await api.post<Attachment>(`/todos/${todoId}/attachments`, form);
+    return response.data;
+  },
+
+  deleteAttachment: async (todoId: number, id: number): Promise<void> => {
+    await api.delete(`/todos/${todoId}/attachments/${id}`);
+  },
+
+  getStorageUsage: async (): Promise<StorageUsage> => {
+    const response = await api.get<StorageUsage>('/me/storage');
+    return response.data;
+  },
+};
+
+export const notificationAPI = {
+  getNotifications: async (params?: { unread?: boolean; limit?: number }): Promise<NotificationList> => {
+    const response = await api.get<NotificationList>('/me/notifications', { params });
+    return response.data;
+  },
+
+  markRead: async (id: number): Promise<Notification> => {
+    const response = await api.post<Notification>(`/me/notifications/${id}/read`);
+    return response.data;
+  },
+
+  markAllRead: async (): Promise<void> => {
+    await api.post('/me/notifications/read');
+  },
+
+  getPreferences: async (): Promise<NotificationPreferences> => {
+    const response = await api.get<NotificationPreferences>('/me/notification-preferences');
+    return response.data;
+  },
+
+  updatePreferences: async (prefs: NotificationPreferences): Promise<NotificationPreferences> => {
+    const response = await api.put<NotificationPreferences>('/me/notification-preferences', prefs);
+    return response.data;
+  },
+};
+
+const confirm = async (action: string, userId: number): Promise<Record<string, string>> => {
+  const response = await api.post<{ confirmation_token: string }>('/admin/confirmations', {
+    action,
+    user_id: userId,
+  });
+  return { 'X-Confirmation-Token': response.data.confirmation_token };
 };
 
 export const adminAPI = {
@@ -70,11 +250,13 @@ export const adminAPI = {
   },
 
   deleteUser: async (id: number): Promise<void> => {
-    await api.delete(`/admin/users/${id}`);
+    const headers = await confirm('delete_user', id);
+    await api.delete(`/admin/users/${id}`, { headers });
   },
 
   updateUserRole: async (id: number, isAdmin: boolean): Promise<User> => {
-    const response = await api.put<User>(`/admin/users/${id}/role`, { is_admin: isAdmin });
+    const headers = isAdmin ? {} : await confirm('update_role', id);
+    const response = await api.put<User>(`/admin/users/${id}/role`, { is_admin: isAdmin }, { headers });
     return response.data;
   },
 
@@ -82,6 +264,11 @@ export const adminAPI = {
     const response = await api.get<Todo[]>(`/admin/users/${id}/todos`);
     return response.data;
   },
+
+  getStats: async (params?: { from?: string; to?: string; bucket?: string }): Promise<AdminStats> => {
+    const response = await api.get<AdminStats>('/admin/stats', { params });
+    return response.data;
+  },
 };
 
 export default api;
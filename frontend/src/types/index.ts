@@ -2,20 +2,77 @@ export interface User {
   id: number;
   email: string;
   is_admin: boolean;
+  suspended_at?: string | null;
   created_at: string;
   updated_at: string;
 }
 
+export type TodoStatus = 'todo' | 'in_progress' | 'blocked' | 'done' | 'cancelled';
+
 export interface Todo {
   id: number;
   user_id: number;
   title: string;
   description: string;
+  status: TodoStatus;
   completed: boolean;
+  due_date: string | null;
+  position: string | null;
+  comment_count: number;
+  completed_at: string | null;
   created_at: string;
   updated_at: string;
 }
 
+export interface UserSettings {
+  timezone: string;
+}
+
+export interface UserStats {
+  from: string;
+  to: string;
+  bucket: 'day' | 'week' | 'month';
+  timezone: string;
+  streaks: {
+    current: number;
+    longest: number;
+    last_completed_on: string | null;
+  };
+  average_completion_seconds: number | null;
+  open: number;
+  overdue: number;
+  due_today: number;
+  burndown: { start: string; created: number; completed: number; open: number }[];
+  weekdays: { weekday: string; created: number; completed: number }[];
+}
+
+export interface AdminStats {
+  from: string;
+  to: string;
+  bucket: 'day' | 'week' | 'month';
+  generated_at: string;
+  totals: {
+    users: number;
+    admins: number;
+    suspended_users: number;
+    todos: number;
+    completed_todos: number;
+    completion_rate: number;
+  };
+  active_users: {
+    last_7_days: number;
+    last_30_days: number;
+  };
+  series: {
+    start: string;
+    signups: number;
+    todos_created: number;
+    todos_completed: number;
+  }[];
+  todos_per_user: { label: string; min: number; max: number | null; users: number }[];
+  largest_accounts: { user_id: number; email: string; todos: number; completed_todos: number }[];
+}
+
 export interface LoginRequest {
   email: string;
   password: string;
@@ -34,5 +91,103 @@ export interface LoginResponse {
 export interface TodoRequest {
   title: string;
   description: string;
+  status?: TodoStatus | null;
   completed: boolean;
+  due_date?: string | null;
+}
+
+export interface TodoStatusChange {
+  id: number;
+  from_status: TodoStatus | null;
+  to_status: TodoStatus;
+  changed_at: string;
+}
+
+export interface BoardColumn {
+  id: number;
+  name: string;
+  status: TodoStatus;
+  wip_limit: number | null;
+  position: number;
+  cards: Todo[];
+}
+
+export interface Board {
+  id: number;
+  name: string;
+  columns: BoardColumn[];
+  created_at: string;
+  updated_at: string;
+}
+
+export type BoardSummary = Omit<Board, 'columns'>;
+
+export interface Mention {
+  user_id: number;
+  email: string;
+}
+
+export interface Comment {
+  id: number;
+  todo_id: number;
+  author_id: number | null;
+  author_email: string | null;
+  body: string;
+  body_html: string;
+  mentions: Mention[];
+  created_at: string;
+  edited_at: string | null;
+}
+
+export interface CommentRevision {
+  id: number;
+  body: string;
+  replaced_at: string;
+}
+
+export interface MentionNotice {
+  comment: Comment;
+  todo_title: string;
+}
+
+export interface Collaborator {
+  user_id: number;
+  email: string;
+  added_at: string;
+}
+
+export interface Attachment {
+  id: number;
+  todo_id: number;
+  filename: string;
+  content_type: string;
+  size: number;
+  created_at: string;
+  url: string;
+  url_expires_at: string;
+}
+
+export interface StorageUsage {
+  used_bytes: number;
+  quota_bytes: number;
+}
+
+export interface Notification {
+  id: number;
+  kind: string;
+  todo_id: number | null;
+  message: string;
+  read_at: string | null;
+  created_at: string;
+}
+
+export interface NotificationList {
+  notifications: Notification[];
+  unread_count: number;
+}
+
+export interface NotificationPreferences {
+  due_soon: boolean;
+  due_soon_days: number;
+  admin_actions: boolean;
 }